@@ -1,9 +1,21 @@
 package cache
 
+import (
+	"context"
+)
+
 type Cache interface {
 	Init(opts ...Option) error
 	Options() Options
 	Get(key string, resultPtr interface{}, opts ...ReadOption) error
 	Set(key string, value interface{}, opts ...WriteOption) error
 	Delete(key string, opts ...DeleteOption) error
-}
\ No newline at end of file
+}
+
+// ContextCache 支持 context 的缓存，命令作为 context 中 span 的子 span 记录
+type ContextCache interface {
+	Cache
+	GetContext(c context.Context, key string, resultPtr interface{}, opts ...ReadOption) error
+	SetContext(c context.Context, key string, value interface{}, opts ...WriteOption) error
+	DeleteContext(c context.Context, key string, opts ...DeleteOption) error
+}
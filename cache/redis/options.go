@@ -3,10 +3,12 @@ package redis
 import (
 	"context"
 	"github.com/xxxmicro/base/cache"
+	"github.com/xxxmicro/base/database/pool"
 )
 
 type addrsKey struct{}
 type passwordKey struct{}
+type poolKey struct{}
 
 func WithAddrs(addrs ...string) cache.Option {
 	return func(o *cache.Options) {
@@ -18,4 +20,11 @@ func WithPassword(password string) cache.Option {
 	return func(o *cache.Options) {
 		o.Context = context.WithValue(o.Context, passwordKey{}, password)
 	}
-}
\ No newline at end of file
+}
+
+// WithPool 连接池配置，未设置时使用 database/redis.DefaultPoolOptions
+func WithPool(o pool.Options) cache.Option {
+	return func(opts *cache.Options) {
+		opts.Context = context.WithValue(opts.Context, poolKey{}, o)
+	}
+}
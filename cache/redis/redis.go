@@ -3,9 +3,10 @@ package redis
 import(
 	"context"
 	"fmt"
-	"time"
 	"strings"
 	"github.com/xxxmicro/base/cache"
+	"github.com/xxxmicro/base/database/pool"
+	bredis "github.com/xxxmicro/base/database/redis"
 	"github.com/garyburd/redigo/redis"
 	"encoding/json"
 )
@@ -39,6 +40,7 @@ func (m *RedisCache) Init(opts ...cache.Option) error {
 		return err
 	}
 	m.r = r
+	bredis.RegisterPool(m.poolName(), r)
 	return nil
 }
 
@@ -47,37 +49,26 @@ func (m *RedisCache) Options() cache.Options {
 }
 
 func (m *RedisCache) connect() (*redis.Pool, error) {
-	addrs := m.options.Context.Value(addrsKey{}).([]string)
+	addrs, _ := m.options.Context.Value(addrsKey{}).([]string)
 	if len(addrs) == 0 {
 		addrs = []string{":6379"}
 	}
 
 	password, _ := m.options.Context.Value(passwordKey{}).(string)
 
-	pool := &redis.Pool{
-		MaxIdle:     3,
-		IdleTimeout: 240 * time.Second,
-		Dial: func() (redis.Conn, error) {
-			c, err := redis.Dial("tcp", strings.Join(addrs, ","))
-			if err != nil {
-				return nil, err
-			}
-
-			if password != "" {
-				if _, err := c.Do("AUTH", password); err != nil {
-					c.Close()
-					return nil, err
-				}
-			}
-			return c, err
-		},
-		TestOnBorrow: func(c redis.Conn, t time.Time) error {
-			_, err := c.Do("PING")
-			return err
-		},
+	poolOptions, ok := m.options.Context.Value(poolKey{}).(pool.Options)
+	if !ok {
+		poolOptions = bredis.DefaultPoolOptions()
 	}
+	if err := poolOptions.Validate(); err != nil {
+		return nil, err
+	}
+
+	return bredis.NewPool(strings.Join(addrs, ","), password, poolOptions), nil
+}
 
-	return pool, nil
+func (m *RedisCache) poolName() string {
+	return "cache:" + m.options.Prefix
 }
 
 func (m *RedisCache) prefix(key string) string {
@@ -89,6 +80,10 @@ func (m *RedisCache) String() string {
 }
 
 func (m *RedisCache) Get(key string, resultPtr interface{}, opts ...cache.ReadOption) error {
+	return m.GetContext(context.Background(), key, resultPtr, opts...)
+}
+
+func (m *RedisCache) GetContext(ctx context.Context, key string, resultPtr interface{}, opts ...cache.ReadOption) error {
 	readOpts := cache.ReadOptions{}
 	for _, o := range opts {
 		o(&readOpts)
@@ -98,7 +93,7 @@ func (m *RedisCache) Get(key string, resultPtr interface{}, opts ...cache.ReadOp
 
 	c := m.r.Get()
 	defer c.Close()
-	data, err := redis.Bytes(c.Do("GET", key))
+	data, err := redis.Bytes(bredis.Do(ctx, c, "cache", m.options.Prefix, "GET", key))
 	if err != nil {
 		return err
 	}
@@ -107,6 +102,10 @@ func (m *RedisCache) Get(key string, resultPtr interface{}, opts ...cache.ReadOp
 }
 
 func (m *RedisCache) Set(key string, value interface{}, opts ...cache.WriteOption) error {
+	return m.SetContext(context.Background(), key, value, opts...)
+}
+
+func (m *RedisCache) SetContext(ctx context.Context, key string, value interface{}, opts ...cache.WriteOption) error {
 	writeOpts := cache.WriteOptions{}
 	for _, o := range opts {
 		o(&writeOpts)
@@ -123,18 +122,23 @@ func (m *RedisCache) Set(key string, value interface{}, opts ...cache.WriteOptio
 	}
 
 	if writeOpts.Expiry > 0 {
-		_, err = c.Do("SETEX", key, writeOpts.Expiry.Seconds(), data)
+		_, err = bredis.Do(ctx, c, "cache", m.options.Prefix, "SETEX", key, writeOpts.Expiry.Seconds(), data)
 	} else {
-		_, err = c.Do("SET", key, data)
+		_, err = bredis.Do(ctx, c, "cache", m.options.Prefix, "SET", key, data)
 	}
 	return err
 }
 
 func (m *RedisCache) Close() error {
+	bredis.UnregisterPool(m.poolName())
 	return m.r.Close()
 }
 
 func (m *RedisCache) Delete(key string, opts ...cache.DeleteOption) error {
+	return m.DeleteContext(context.Background(), key, opts...)
+}
+
+func (m *RedisCache) DeleteContext(ctx context.Context, key string, opts ...cache.DeleteOption) error {
 	deleteOptions := cache.DeleteOptions{}
 	for _, o := range opts {
 		o(&deleteOptions)
@@ -145,6 +149,6 @@ func (m *RedisCache) Delete(key string, opts ...cache.DeleteOption) error {
 	c := m.r.Get()
 	defer c.Close()
 
-	_, err := c.Do("DEL", key)
+	_, err := bredis.Do(ctx, c, "cache", m.options.Prefix, "DEL", key)
 	return err
 }
\ No newline at end of file
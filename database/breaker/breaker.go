@@ -0,0 +1,293 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v2/logger"
+)
+
+// ErrCircuitOpen 熔断器打开或半开探测名额已满时直接返回，不访问数据库
+var ErrCircuitOpen = errors.New("ERR_DB_CIRCUIT_OPEN")
+
+type State int
+
+const (
+	State_CLOSED    State = 0 // 正常放行
+	State_HALF_OPEN State = 1 // 放行少量探测请求
+	State_OPEN      State = 2 // 全部拒绝
+)
+
+func (s State) String() string {
+	switch s {
+	case State_HALF_OPEN:
+		return "half-open"
+	case State_OPEN:
+		return "open"
+	}
+	return "closed"
+}
+
+type Options struct {
+	Window              time.Duration        // 统计失败率的窗口，窗口结束后清零
+	MinRequests         int                  // 窗口内请求数达到该值才按失败率熔断
+	FailureRatio        float64              // 失败率阈值，0 表示不按失败率熔断
+	ConsecutiveFailures int                  // 连续失败次数阈值，0 表示不按连续失败熔断
+	OpenTimeout         time.Duration        // 打开后经过该时长进入半开
+	HalfOpenRequests    int                  // 半开时放行的探测请求数，全部成功后关闭
+	IsFailure           func(err error) bool // 判断错误是否计为失败，默认非 nil 即失败
+	Logger              logger.Logger        // 状态变化日志
+}
+
+type Option func(o *Options)
+
+func Window(d time.Duration) Option {
+	return func(o *Options) {
+		o.Window = d
+	}
+}
+
+func MinRequests(n int) Option {
+	return func(o *Options) {
+		o.MinRequests = n
+	}
+}
+
+func FailureRatio(ratio float64) Option {
+	return func(o *Options) {
+		o.FailureRatio = ratio
+	}
+}
+
+func ConsecutiveFailures(n int) Option {
+	return func(o *Options) {
+		o.ConsecutiveFailures = n
+	}
+}
+
+func OpenTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.OpenTimeout = d
+	}
+}
+
+func HalfOpenRequests(n int) Option {
+	return func(o *Options) {
+		o.HalfOpenRequests = n
+	}
+}
+
+// FailureFunc 各仓库提供 IsFailure，排除未找到、取消等不代表数据库故障的错误
+func FailureFunc(fn func(err error) bool) Option {
+	return func(o *Options) {
+		o.IsFailure = fn
+	}
+}
+
+func WithLogger(l logger.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
+
+// Breaker 熔断器，按失败率或连续失败次数打开，超时后半开探测
+type Breaker struct {
+	name    string
+	options Options
+
+	l           sync.Mutex
+	state       State
+	generation  uint64 // 每次状态变化加一，忽略旧状态下发出的请求结果
+	windowStart time.Time
+	openedAt    time.Time
+	requests    int
+	failures    int
+	consecutive int
+	inFlight    int // 半开时正在执行的探测请求
+	successes   int // 半开时成功的探测请求
+}
+
+func New(name string, opts ...Option) *Breaker {
+	options := Options{
+		Window:              10 * time.Second,
+		MinRequests:         20,
+		FailureRatio:        0.5,
+		ConsecutiveFailures: 5,
+		OpenTimeout:         30 * time.Second,
+		HalfOpenRequests:    1,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	if options.IsFailure == nil {
+		options.IsFailure = func(err error) bool {
+			return err != nil
+		}
+	}
+	if options.HalfOpenRequests <= 0 {
+		options.HalfOpenRequests = 1
+	}
+
+	b := &Breaker{name: name, options: options, windowStart: time.Now()}
+	stateGauge.WithLabelValues(name).Set(float64(State_CLOSED))
+	return b
+}
+
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+func (b *Breaker) State() State {
+	b.l.Lock()
+	defer b.l.Unlock()
+	return b.current(time.Now())
+}
+
+// Execute 熔断器允许时执行 fn 并记录结果，否则返回 ErrCircuitOpen，b 为 nil 时直接执行
+func (b *Breaker) Execute(fn func() error) error {
+	if b == nil {
+		return fn()
+	}
+
+	generation, err := b.before()
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	b.after(generation, b.options.IsFailure(err))
+	return err
+}
+
+// Allow 熔断器允许时返回 done，操作结束后调用 done 记录是否失败，否则返回 ErrCircuitOpen
+// 用于由调用方判断失败的场景，如仓库只在访问数据库的部分经过熔断器，b 为 nil 时总是允许
+func (b *Breaker) Allow() (done func(failure bool), err error) {
+	if b == nil {
+		return func(bool) {}, nil
+	}
+
+	generation, err := b.before()
+	if err != nil {
+		return nil, err
+	}
+	return func(failure bool) {
+		b.after(generation, failure)
+	}, nil
+}
+
+func (b *Breaker) before() (uint64, error) {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	switch b.current(time.Now()) {
+	case State_OPEN:
+		rejected.WithLabelValues(b.name).Inc()
+		return 0, ErrCircuitOpen
+	case State_HALF_OPEN:
+		if b.inFlight >= b.options.HalfOpenRequests {
+			rejected.WithLabelValues(b.name).Inc()
+			return 0, ErrCircuitOpen
+		}
+		b.inFlight++
+	}
+	return b.generation, nil
+}
+
+func (b *Breaker) after(generation uint64, failure bool) {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	now := time.Now()
+	state := b.current(now)
+	if generation != b.generation {
+		return
+	}
+
+	switch state {
+	case State_CLOSED:
+		b.requests++
+		if !failure {
+			b.consecutive = 0
+			return
+		}
+		b.failures++
+		b.consecutive++
+		if b.shouldTrip() {
+			b.setState(State_OPEN, now)
+		}
+	case State_HALF_OPEN:
+		b.inFlight--
+		if failure {
+			b.setState(State_OPEN, now)
+			return
+		}
+		b.successes++
+		if b.successes >= b.options.HalfOpenRequests {
+			b.setState(State_CLOSED, now)
+		}
+	}
+}
+
+func (b *Breaker) shouldTrip() bool {
+	if b.options.ConsecutiveFailures > 0 && b.consecutive >= b.options.ConsecutiveFailures {
+		return true
+	}
+	if b.options.FailureRatio > 0 && b.requests >= b.options.MinRequests {
+		return float64(b.failures)/float64(b.requests) >= b.options.FailureRatio
+	}
+	return false
+}
+
+// current 根据时间推进状态：打开超时后进入半开，关闭状态的统计窗口到期后清零
+func (b *Breaker) current(now time.Time) State {
+	switch b.state {
+	case State_OPEN:
+		if now.Sub(b.openedAt) >= b.options.OpenTimeout {
+			b.setState(State_HALF_OPEN, now)
+		}
+	case State_CLOSED:
+		if b.options.Window > 0 && now.Sub(b.windowStart) >= b.options.Window {
+			b.reset(now)
+		}
+	}
+	return b.state
+}
+
+func (b *Breaker) reset(now time.Time) {
+	b.windowStart = now
+	b.requests = 0
+	b.failures = 0
+	b.consecutive = 0
+	b.inFlight = 0
+	b.successes = 0
+}
+
+func (b *Breaker) setState(state State, now time.Time) {
+	if b.state == state {
+		return
+	}
+	prev := b.state
+	b.state = state
+	b.generation++
+	b.reset(now)
+	if state == State_OPEN {
+		b.openedAt = now
+	}
+
+	stateGauge.WithLabelValues(b.name).Set(float64(state))
+	transitions.WithLabelValues(b.name, state.String()).Inc()
+
+	level := logger.InfoLevel
+	if state == State_OPEN {
+		level = logger.WarnLevel
+	}
+	b.logger().Logf(level, "circuit breaker %s: %s -> %s", b.name, prev, state)
+}
+
+func (b *Breaker) logger() logger.Logger {
+	if b.options.Logger != nil {
+		return b.options.Logger
+	}
+	return logger.DefaultLogger
+}
@@ -0,0 +1,85 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errDown = errors.New("connection refused")
+
+func TestConsecutiveFailures(t *testing.T) {
+	b := New("test_consecutive", ConsecutiveFailures(3), FailureRatio(0), OpenTimeout(20*time.Millisecond))
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, errDown, b.Execute(func() error { return errDown }))
+	}
+	assert.Equal(t, State_OPEN, b.State())
+
+	called := false
+	err := b.Execute(func() error {
+		called = true
+		return nil
+	})
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.False(t, called)
+
+	// 超时后半开，探测成功后关闭
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, State_HALF_OPEN, b.State())
+	assert.NoError(t, b.Execute(func() error { return nil }))
+	assert.Equal(t, State_CLOSED, b.State())
+}
+
+func TestFailureRatio(t *testing.T) {
+	b := New("test_ratio", ConsecutiveFailures(0), FailureRatio(0.5), MinRequests(4))
+
+	b.Execute(func() error { return nil })
+	b.Execute(func() error { return errDown })
+	b.Execute(func() error { return nil })
+	assert.Equal(t, State_CLOSED, b.State())
+
+	b.Execute(func() error { return errDown })
+	assert.Equal(t, State_OPEN, b.State())
+}
+
+func TestHalfOpenFailure(t *testing.T) {
+	notFound := errors.New("not found")
+	b := New("test_half_open", ConsecutiveFailures(1), OpenTimeout(10*time.Millisecond), FailureFunc(func(err error) bool {
+		return err != nil && err != notFound
+	}))
+
+	// 不计为失败的错误不会熔断
+	assert.Equal(t, notFound, b.Execute(func() error { return notFound }))
+	assert.Equal(t, State_CLOSED, b.State())
+
+	b.Execute(func() error { return errDown })
+	assert.Equal(t, State_OPEN, b.State())
+
+	time.Sleep(20 * time.Millisecond)
+	b.Execute(func() error { return errDown })
+	assert.Equal(t, State_OPEN, b.State())
+}
+
+func TestAllow(t *testing.T) {
+	b := New("test_allow", ConsecutiveFailures(2), FailureRatio(0))
+
+	for i := 0; i < 2; i++ {
+		done, err := b.Allow()
+		assert.NoError(t, err)
+		done(true)
+	}
+	assert.Equal(t, State_OPEN, b.State())
+
+	_, err := b.Allow()
+	assert.Equal(t, ErrCircuitOpen, err)
+
+	// 未配置熔断器时总是允许
+	var disabled *Breaker
+	done, err := disabled.Allow()
+	assert.NoError(t, err)
+	done(true)
+	assert.Equal(t, errDown, disabled.Execute(func() error { return errDown }))
+}
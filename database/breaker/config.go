@@ -0,0 +1,42 @@
+package breaker
+
+import (
+	"github.com/micro/go-micro/v2/config"
+)
+
+// FromConfig 按 <path>.breaker 的配置创建熔断器，默认不开启，breaker.enabled 为 true 时才创建，否则返回 nil，即不熔断
+func FromConfig(config config.Config, name string, path ...string) *Breaker {
+	enabled := append(append(append([]string{}, path...), "breaker"), "enabled")
+	if !config.Get(enabled...).Bool(false) {
+		return nil
+	}
+	return New(name, ConfigOptions(config, path...)...)
+}
+
+// ConfigOptions 读取 <path>.breaker 下的配置，例如 mongo.breaker.failure_ratio，未配置的项使用默认值
+func ConfigOptions(config config.Config, path ...string) []Option {
+	get := func(key string) []string {
+		return append(append(append([]string{}, path...), "breaker"), key)
+	}
+
+	var opts []Option
+	if v := config.Get(get("window")...).Duration(0); v > 0 {
+		opts = append(opts, Window(v))
+	}
+	if v := config.Get(get("min_requests")...).Int(0); v > 0 {
+		opts = append(opts, MinRequests(v))
+	}
+	if v := config.Get(get("failure_ratio")...).Float64(0); v > 0 {
+		opts = append(opts, FailureRatio(v))
+	}
+	if v := config.Get(get("consecutive_failures")...).Int(0); v > 0 {
+		opts = append(opts, ConsecutiveFailures(v))
+	}
+	if v := config.Get(get("open_timeout")...).Duration(0); v > 0 {
+		opts = append(opts, OpenTimeout(v))
+	}
+	if v := config.Get(get("half_open_requests")...).Int(0); v > 0 {
+		opts = append(opts, HalfOpenRequests(v))
+	}
+	return opts
+}
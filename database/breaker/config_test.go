@@ -0,0 +1,26 @@
+package breaker
+
+import (
+	"testing"
+
+	"github.com/micro/go-micro/v2/config"
+	"github.com/micro/go-micro/v2/config/source/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func loadConfig(t *testing.T, data string) config.Config {
+	conf, err := config.NewConfig()
+	assert.NoError(t, err)
+	assert.NoError(t, conf.Load(memory.NewSource(memory.WithJSON([]byte(data)))))
+	return conf
+}
+
+func TestFromConfig(t *testing.T) {
+	// 默认不开启
+	conf := loadConfig(t, `{"mongo": {"breaker": {"failure_ratio": 0.3}}}`)
+	assert.Nil(t, FromConfig(conf, "mongo", "mongo"))
+
+	conf = loadConfig(t, `{"mongo": {"sources": {"log": {"breaker": {"enabled": true}}}}}`)
+	assert.Nil(t, FromConfig(conf, "mongo", "mongo"))
+	assert.NotNil(t, FromConfig(conf, "mongo log", "mongo", "sources", "log"))
+}
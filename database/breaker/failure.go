@@ -0,0 +1,25 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+)
+
+// IsFailure 各数据源共用的失败判断，取消的请求不计为失败，超时、网络错误和连接被关闭计为失败
+// 其它错误交给 backend 按驱动的错误类型和错误码判断，backend 为 nil 时不计为失败
+func IsFailure(err error, backend func(err error) bool) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return true
+	}
+	return backend != nil && backend(err)
+}
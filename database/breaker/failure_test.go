@@ -0,0 +1,29 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsFailure(t *testing.T) {
+	backend := func(err error) bool {
+		return err.Error() == "server down"
+	}
+
+	assert.False(t, IsFailure(nil, backend))
+	assert.False(t, IsFailure(fmt.Errorf("query: %w", context.Canceled), backend))
+	// 参数校验等错误不访问数据库，由 backend 判断
+	assert.False(t, IsFailure(errors.New("ERR_MALFORMED_PARAMETERS"), backend))
+	assert.False(t, IsFailure(errors.New("server down"), nil))
+
+	assert.True(t, IsFailure(context.DeadlineExceeded, backend))
+	assert.True(t, IsFailure(io.ErrUnexpectedEOF, nil))
+	assert.True(t, IsFailure(&net.OpError{Op: "dial", Err: errors.New("connection refused")}, nil))
+	assert.True(t, IsFailure(errors.New("server down"), backend))
+}
@@ -0,0 +1,29 @@
+package breaker
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	stateGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "db",
+		Name:      "circuit_breaker_state",
+		Help:      "Circuit breaker state: 0 closed, 1 half-open, 2 open.",
+	}, []string{"name"})
+
+	transitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "db",
+		Name:      "circuit_breaker_transitions_total",
+		Help:      "Circuit breaker state changes by target state.",
+	}, []string{"name", "state"})
+
+	rejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "db",
+		Name:      "circuit_breaker_rejected_total",
+		Help:      "Operations rejected with ErrCircuitOpen.",
+	}, []string{"name"})
+)
+
+func init() {
+	prometheus.MustRegister(stateGauge, transitions, rejected)
+}
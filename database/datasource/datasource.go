@@ -0,0 +1,36 @@
+package datasource
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/micro/go-micro/v2/config"
+)
+
+// Default 直接配置在 <section> 下的数据源名称，兼容只有一个数据源的配置
+const Default = "default"
+
+var ErrNotFound = errors.New("ERR_DATASOURCE_NOT_FOUND")
+
+// Names 返回 <section>.sources 下配置的数据源名称，按名称排序
+// 例如 db.sources.primary、db.sources.reporting，<section> 下直接配置了 key 时同时包含 Default
+func Names(config config.Config, section string, key string) []string {
+	var names []string
+	for name := range config.Get(section, "sources").StringMap(nil) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(config.Get(section, key).String("")) > 0 || len(config.Get(section, key).StringSlice(nil)) > 0 {
+		names = append([]string{Default}, names...)
+	}
+	return names
+}
+
+// Path 数据源配置路径，Default 为 <section>，其它为 <section>.sources.<name>
+func Path(section string, name string) []string {
+	if name == Default {
+		return []string{section}
+	}
+	return []string{section, "sources", name}
+}
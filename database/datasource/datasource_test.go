@@ -0,0 +1,36 @@
+package datasource
+
+import (
+	"testing"
+
+	"github.com/micro/go-micro/v2/config"
+	"github.com/micro/go-micro/v2/config/source/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNames(t *testing.T) {
+	conf, err := config.NewConfig()
+	assert.NoError(t, err)
+	assert.NoError(t, conf.Load(memory.NewSource(memory.WithJSON([]byte(`{
+		"db": {
+			"driver": "mysql",
+			"connection_string": "root:123456@tcp(127.0.0.1:3306)/test",
+			"sources": {
+				"reporting": {"driver": "mysql"},
+				"analytics": {"driver": "mysql"}
+			}
+		},
+		"mongo": {
+			"sources": {
+				"main": {"database": "uim"}
+			}
+		}
+	}`)))))
+
+	assert.Equal(t, []string{Default, "analytics", "reporting"}, Names(conf, "db", "driver"))
+	assert.Equal(t, []string{"main"}, Names(conf, "mongo", "database"))
+	assert.Empty(t, Names(conf, "elastic", "addresses"))
+
+	assert.Equal(t, []string{"db"}, Path("db", Default))
+	assert.Equal(t, []string{"db", "sources", "reporting"}, Path("db", "reporting"))
+}
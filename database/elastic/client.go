@@ -0,0 +1,110 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	elasticsearch6 "github.com/elastic/go-elasticsearch/v6"
+	"github.com/elastic/go-elasticsearch/v6/esapi"
+	"github.com/xxxmicro/base/database/breaker"
+)
+
+// Client 兼容 6.x 和 7.x 集群的客户端
+// 7.x 移除了文档类型，仓库根据 Major 决定是否发送带类型的请求
+type Client struct {
+	*elasticsearch6.Client
+	Version string // 集群版本，如 7.10.2
+	Major   int    // 主版本号
+
+	transport *transport       // 配置热更新时替换，见 watchConfigChange
+	breaker   *breaker.Breaker // 集群的熔断器，未启用时为 nil
+	stopWatch func()
+}
+
+// Breaker 集群的熔断器，未启用时为 nil
+func (c *Client) Breaker() *breaker.Breaker {
+	return c.breaker
+}
+
+// Typeless 集群是否已移除文档类型
+func (c *Client) Typeless() bool {
+	return c.Major >= 7
+}
+
+// DocumentType 文档接口路径中的类型，6.x 沿用索引名作为类型，7.x 使用 _doc
+func (c *Client) DocumentType(index string) string {
+	if c.Typeless() {
+		return "_doc"
+	}
+	return index
+}
+
+// MappingType 创建索引和 bulk 时使用的类型，7.x 为空
+func (c *Client) MappingType(index string) string {
+	if c.Typeless() {
+		return ""
+	}
+	return index
+}
+
+// SearchTypes 查询接口的类型，7.x 为空
+func (c *Client) SearchTypes(index string) []string {
+	if c.Typeless() {
+		return nil
+	}
+	return []string{index}
+}
+
+// AtLeast 集群版本是否不低于 major.minor，未知版本时只比较主版本号
+func (c *Client) AtLeast(major int, minor int) bool {
+	if c.Major != major {
+		return c.Major > major
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(strings.TrimSpace(c.Version), "v"), ".", 3)
+	if len(parts) < 2 {
+		return minor == 0
+	}
+	n, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return minor == 0
+	}
+	return n >= minor
+}
+
+// detectVersion 通过 info 接口获取集群版本
+func detectVersion(c *elasticsearch6.Client) (string, error) {
+	req := esapi.InfoRequest{}
+	res, err := req.Do(context.Background(), c)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return "", errors.New(res.String())
+	}
+
+	var respData struct {
+		Version struct {
+			Number string `json:"number"`
+		} `json:"version"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&respData); err != nil {
+		return "", err
+	}
+	return respData.Version.Number, nil
+}
+
+// parseMajor 从 7、7.10、7.10.2 形式的版本号中解析主版本号
+func parseMajor(version string) (int, error) {
+	major := strings.SplitN(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".", 2)[0]
+	n, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, errors.New("invalid elastic version: " + version)
+	}
+	return n, nil
+}
@@ -2,30 +2,116 @@ package elastic
 
 import (
 	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
 	elasticsearch6 "github.com/elastic/go-elasticsearch/v6"
+	"github.com/elastic/go-elasticsearch/v6/esapi"
 	"github.com/micro/go-micro/v2/config"
+	"github.com/micro/go-micro/v2/logger"
+	"github.com/xxxmicro/base/database/breaker"
+	"github.com/xxxmicro/base/database/datasource"
+	"github.com/xxxmicro/base/database/pool"
+	"github.com/xxxmicro/base/database/reload"
 )
 
-func NewElasticProvider(config config.Config) (*elasticsearch6.Client, error) {
-	addresses := config.Get("elastic", "addresses").StringSlice(nil)
+func NewElasticProvider(config config.Config) (*Client, error) {
+	return NewElastic(config, datasource.Default)
+}
+
+// NewElastic 按数据源名称创建客户端，default 读取 elastic 下的配置，其它读取 elastic.sources.<name>
+func NewElastic(config config.Config, name string) (*Client, error) {
+	path := datasource.Path("elastic", name)
+	g, err := newGeneration(config, path...)
+	if err != nil {
+		return nil, err
+	}
+
+	// 未配置版本时启动时探测集群版本
+	version := config.Get(append(path, "version")...).String("")
+	if len(version) == 0 {
+		version, err = detectVersion(g.client)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	major, err := parseMajor(version)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := &transport{current: g}
+	client := &Client{
+		Client:    &elasticsearch6.Client{API: esapi.New(tp), Transport: tp},
+		Version:   version,
+		Major:     major,
+		transport: tp,
+		breaker:   breaker.FromConfig(config, "elastic "+name, path...),
+	}
+
+	client.stopWatch = watchConfigChange(config, client, name)
+
+	return client, nil
+}
+
+// Close 停止监听配置并关闭空闲连接
+func (c *Client) Close() {
+	if c.stopWatch != nil {
+		c.stopWatch()
+	}
+
+	c.transport.l.RLock()
+	defer c.transport.l.RUnlock()
+	c.transport.current.http.CloseIdleConnections()
+}
+
+func newGeneration(config config.Config, path ...string) (*generation, error) {
+	get := func(key string) []string {
+		return append(append([]string{}, path...), key)
+	}
+
+	addresses := config.Get(get("addresses")...).StringSlice(nil)
 	if len(addresses) == 0 {
 		return nil, errors.New("addresses is empty")
 	}
 
-	username := config.Get("elastic", "username").String("")
+	username := config.Get(get("username")...).String("")
 	if len(username) == 0 {
 		return nil, errors.New("username is empty")
 	}
 
-	password := config.Get("elastic", "password").String("")
+	password := config.Get(get("password")...).String("")
 	if len(password) == 0 {
 		return nil, errors.New("password is empty")
 	}
 
+	poolOptions, err := PoolOptions(config, path...)
+	if err != nil {
+		return nil, err
+	}
+
+	// 每代连接使用独立的 http.Transport，替换后可以关闭旧连接
+	httpTransport := http.DefaultTransport.(*http.Transport).Clone()
+	httpTransport.MaxConnsPerHost = poolOptions.MaxOpen
+	httpTransport.MaxIdleConnsPerHost = poolOptions.MaxIdle
+	httpTransport.IdleConnTimeout = poolOptions.IdleTimeout
+	httpTransport.ResponseHeaderTimeout = poolOptions.ReadTimeout
+	httpTransport.DialContext = (&net.Dialer{
+		Timeout:   poolOptions.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	if poolOptions.TLS != nil {
+		httpTransport.TLSClientConfig = poolOptions.TLS
+	}
+
 	elasticCfg := elasticsearch6.Config{
 		Addresses: addresses,
 		Username:  username,
 		Password:  password,
+		Transport: httpTransport,
 	}
 
 	elasticClient, err := elasticsearch6.NewClient(elasticCfg)
@@ -33,11 +119,90 @@ func NewElasticProvider(config config.Config) (*elasticsearch6.Client, error) {
 		return nil, err
 	}
 
-	go watchConfigChange(config, elasticClient)
+	return &generation{client: elasticClient, http: httpTransport}, nil
+}
+
+// PoolOptions 读取 <path>.pool 和 <path>.tls 配置
+// max_open、max_idle 按节点限制连接数，read_timeout 对应等待响应头的时间，连接数达到上限时总是等待
+func PoolOptions(config config.Config, path ...string) (pool.Options, error) {
+	defaults := pool.Options{
+		MaxIdle:        10,
+		IdleTimeout:    90 * time.Second,
+		ConnectTimeout: 5 * time.Second,
+	}
+	return pool.Config(config, defaults, path...)
+}
 
-	return elasticClient, nil
+// transport 可替换的传输层，Client 的请求都经过这里
+type transport struct {
+	l       sync.RWMutex
+	current *generation
 }
 
-func watchConfigChange(config config.Config, db *elasticsearch6.Client) {
-	// TODO
+// generation 一代连接，wg 统计正在执行的请求
+type generation struct {
+	client *elasticsearch6.Client
+	http   *http.Transport
+	wg     sync.WaitGroup
+}
+
+func (t *transport) Perform(req *http.Request) (*http.Response, error) {
+	t.l.RLock()
+	g := t.current
+	g.wg.Add(1)
+	t.l.RUnlock()
+	defer g.wg.Done()
+
+	return g.client.Perform(req)
+}
+
+// swap 替换为新的连接，旧请求结束或超过 drainTimeout 后关闭旧的空闲连接
+// 响应体未读完的连接归还后由 IdleConnTimeout 回收
+func (t *transport) swap(g *generation, drainTimeout time.Duration) {
+	t.l.Lock()
+	old := t.current
+	t.current = g
+	t.l.Unlock()
+
+	go func() {
+		if !reload.Drain(&old.wg, drainTimeout) {
+			logger.Warnf("elastic old transport not drained in %s, closing", drainTimeout)
+		}
+		old.http.CloseIdleConnections()
+	}()
+}
+
+// watchConfigChange 地址或认证变化时替换连接，集群主版本变化时请求格式不同，拒绝替换
+func watchConfigChange(config config.Config, db *Client, name string) (stop func()) {
+	path := datasource.Path("elastic", name)
+	get := func(key string) []string {
+		return append(append([]string{}, path...), key)
+	}
+
+	return reload.Watch(config, "elastic "+name, func() error {
+		g, err := newGeneration(config, path...)
+		if err != nil {
+			return err
+		}
+
+		version := config.Get(get("version")...).String("")
+		if len(version) == 0 {
+			if version, err = detectVersion(g.client); err != nil {
+				g.http.CloseIdleConnections()
+				return err
+			}
+		}
+		major, err := parseMajor(version)
+		if err != nil {
+			g.http.CloseIdleConnections()
+			return err
+		}
+		if major != db.Major {
+			g.http.CloseIdleConnections()
+			return errors.New("elastic major version changed from " + db.Version + " to " + version + ", restart required")
+		}
+
+		db.transport.swap(g, config.Get(get("drain_timeout")...).Duration(30*time.Second))
+		return nil
+	}, path...)
 }
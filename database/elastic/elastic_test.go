@@ -166,3 +166,36 @@ func TestElasticXPackSQLQuery(t *testing.T) {
 func TestElasticSearch(t *testing.T) {
 
 }
+
+func TestParseMajor(t *testing.T) {
+	for version, major := range map[string]int{"6": 6, "6.8.10": 6, "7.10.2": 7, "v7": 7} {
+		n, err := parseMajor(version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != major {
+			t.Fatalf("parseMajor(%s) = %d, want %d", version, n, major)
+		}
+	}
+
+	if _, err := parseMajor("latest"); err == nil {
+		t.Fatal("expected error for invalid version")
+	}
+
+	client := &Client{Major: 7}
+	if client.DocumentType("users") != "_doc" || client.SearchTypes("users") != nil {
+		t.Fatal("7.x should be typeless")
+	}
+}
+
+func TestAtLeast(t *testing.T) {
+	client := &Client{Version: "7.10.2", Major: 7}
+	if !client.AtLeast(7, 2) || !client.AtLeast(6, 8) || client.AtLeast(7, 11) {
+		t.Fatal("7.10.2 version compare failed")
+	}
+
+	client = &Client{Version: "7", Major: 7}
+	if !client.AtLeast(7, 0) || client.AtLeast(7, 2) {
+		t.Fatal("unknown minor should only satisfy x.0")
+	}
+}
@@ -0,0 +1,53 @@
+package elastic
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/micro/go-micro/v2/config"
+	"github.com/xxxmicro/base/database/datasource"
+)
+
+// Registry 按名称获取配置的数据源，例如 elastic.sources.logs 和 elastic.sources.search
+type Registry struct {
+	names   []string
+	clients map[string]*Client
+}
+
+// NewRegistryProvider 创建所有配置的数据源，任一失败时关闭已创建的连接并返回错误
+func NewRegistryProvider(config config.Config) (*Registry, error) {
+	names := datasource.Names(config, "elastic", "addresses")
+	if len(names) == 0 {
+		return nil, errors.New("no elastic source configured")
+	}
+
+	r := &Registry{names: names, clients: make(map[string]*Client, len(names))}
+	for _, name := range names {
+		client, err := NewElastic(config, name)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("elastic source %s: %w", name, err)
+		}
+		r.clients[name] = client
+	}
+	return r, nil
+}
+
+// Get 名称未配置时返回 datasource.ErrNotFound
+func (r *Registry) Get(name string) (*Client, error) {
+	client, ok := r.clients[name]
+	if !ok {
+		return nil, fmt.Errorf("%w elastic %s", datasource.ErrNotFound, name)
+	}
+	return client, nil
+}
+
+func (r *Registry) Names() []string {
+	return r.names
+}
+
+func (r *Registry) Close() {
+	for _, client := range r.clients {
+		client.Close()
+	}
+}
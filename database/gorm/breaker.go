@@ -0,0 +1,19 @@
+package gorm
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/xxxmicro/base/database/breaker"
+)
+
+const breakerGormKey = "xxxmicro:breaker"
+
+// Breaker NewDB 按 <path>.breaker 配置创建的熔断器，未启用或连接不是由 NewDB 创建时返回 nil
+// 熔断器保存在 db 的设置中，由 db 派生的查询和事务都能取到
+func Breaker(db *gorm.DB) *breaker.Breaker {
+	v, ok := db.Get(breakerGormKey)
+	if !ok {
+		return nil
+	}
+	b, _ := v.(*breaker.Breaker)
+	return b
+}
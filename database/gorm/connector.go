@@ -0,0 +1,257 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+)
+
+// connector 可替换连接串的 driver.Connector，gorm 持有的 *sql.DB 保持不变
+// 替换后新连接使用新连接串，旧连接在当前语句结束归还后，下次复用前由 ResetSession 丢弃
+type connector struct {
+	driver driver.Driver
+
+	l          sync.RWMutex
+	current    driver.Connector
+	generation uint64
+	stopWatch  func() // 停止监听配置，见 watchConfigChange
+}
+
+func newConnector(driverName string, dsn string) (*connector, error) {
+	// sql.Open 不建立连接，只用来取得注册的驱动
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	d := db.Driver()
+	db.Close()
+
+	c := &connector{driver: d}
+	if c.current, err = c.open(dsn); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *connector) open(dsn string) (driver.Connector, error) {
+	if dc, ok := c.driver.(driver.DriverContext); ok {
+		return dc.OpenConnector(dsn)
+	}
+	return &dsnConnector{dsn: dsn, driver: c.driver}, nil
+}
+
+// swap 先用新连接串建立一个连接验证可用，之后建立的连接使用新的连接串
+func (c *connector) swap(ctx context.Context, dsn string) error {
+	next, err := c.open(dsn)
+	if err != nil {
+		return err
+	}
+	dc, err := next.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	dc.Close()
+
+	c.l.Lock()
+	c.current = next
+	c.generation++
+	c.l.Unlock()
+	return nil
+}
+
+// Close 由 sql.DB.Close 调用，停止监听配置，gorm.DB.Close 时配置热更新随之停止
+func (c *connector) Close() error {
+	c.l.Lock()
+	stop := c.stopWatch
+	c.stopWatch = nil
+	c.l.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+	return nil
+}
+
+func (c *connector) stale(generation uint64) bool {
+	c.l.RLock()
+	defer c.l.RUnlock()
+	return generation != c.generation
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.l.RLock()
+	current, generation := c.current, c.generation
+	c.l.RUnlock()
+
+	dc, err := current.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: dc, generation: generation, connector: c}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c *dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// conn 记录连接所属的连接串版本，其它可选接口透传给驱动
+// jinzhu/gorm 执行语句时不传 context，事务通过 BeginTx 绑定 context 后，事务中不可取消的语句改用事务的 context，
+// context 结束时驱动中断执行中的语句
+type conn struct {
+	driver.Conn
+	generation uint64
+	connector  *connector
+	txContext  context.Context
+}
+
+// ResetSession 连接串已替换时返回 ErrBadConn，database/sql 会关闭该连接并重新建立
+func (c *conn) ResetSession(ctx context.Context) error {
+	if c.connector.stale(c.generation) {
+		return driver.ErrBadConn
+	}
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+// context 语句使用的 context，语句本身不可取消时使用所在事务的 context
+func (c *conn) context(ctx context.Context) context.Context {
+	if ctx.Done() == nil && c.txContext != nil {
+		return c.txContext
+	}
+	return ctx
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (dt driver.Tx, err error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		dt, err = b.BeginTx(ctx, opts)
+	} else {
+		dt, err = c.Conn.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Done() != nil {
+		c.txContext = ctx
+	}
+	return &tx{Tx: dt, conn: c}, nil
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (ds driver.Stmt, err error) {
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		ds, err = p.PrepareContext(c.context(ctx), query)
+	} else {
+		ds, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{Stmt: ds, conn: c}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := c.Conn.(driver.ExecerContext); ok {
+		return e.ExecContext(c.context(ctx), query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := c.Conn.(driver.QueryerContext); ok {
+		return q.QueryContext(c.context(ctx), query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// tx 事务结束后连接上的语句不再使用事务的 context
+type tx struct {
+	driver.Tx
+	conn *conn
+}
+
+func (t *tx) Commit() error {
+	t.conn.txContext = nil
+	return t.Tx.Commit()
+}
+
+func (t *tx) Rollback() error {
+	t.conn.txContext = nil
+	return t.Tx.Rollback()
+}
+
+// stmt 预编译语句，执行时同样使用事务的 context
+type stmt struct {
+	driver.Stmt
+	conn *conn
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return e.ExecContext(s.conn.context(ctx), args)
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Exec(values)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return q.QueryContext(s.conn.context(ctx), args)
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Query(values)
+}
+
+// CheckNamedValue 与 database/sql 的顺序一致，先使用语句的检查，再使用连接的检查
+func (s *stmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return s.conn.CheckNamedValue(nv)
+}
+
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if len(arg.Name) > 0 {
+			return nil, errors.New("sql: driver does not support the use of Named Parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
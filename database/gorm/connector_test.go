@@ -0,0 +1,114 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	return &fakeConn{dsn: dsn}, nil
+}
+
+type fakeConn struct {
+	driver.Conn
+	dsn string
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func TestConnectorSwap(t *testing.T) {
+	c := &connector{driver: fakeDriver{}}
+	c.current, _ = c.open("a")
+
+	old, err := c.Connect(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, old.(driver.SessionResetter).ResetSession(context.Background()))
+
+	assert.NoError(t, c.swap(context.Background(), "b"))
+	assert.Equal(t, driver.ErrBadConn, old.(driver.SessionResetter).ResetSession(context.Background()))
+
+	next, err := c.Connect(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "b", next.(*conn).Conn.(*fakeConn).dsn)
+	assert.NoError(t, next.(driver.SessionResetter).ResetSession(context.Background()))
+}
+
+func TestConnectorClose(t *testing.T) {
+	c := &connector{driver: fakeDriver{}}
+	c.current, _ = c.open("a")
+
+	stopped := 0
+	c.stopWatch = func() { stopped++ }
+
+	// 关闭 *sql.DB 时停止监听配置
+	db := sql.OpenDB(c)
+	assert.NoError(t, db.Close())
+	assert.Equal(t, 1, stopped)
+	assert.NoError(t, c.Close())
+	assert.Equal(t, 1, stopped)
+}
+
+type blockingDriver struct{}
+
+func (blockingDriver) Open(dsn string) (driver.Conn, error) {
+	return &blockingConn{}, nil
+}
+
+// blockingConn 语句一直执行到 context 结束
+type blockingConn struct {
+	driver.Conn
+}
+
+func (c *blockingConn) Close() error {
+	return nil
+}
+
+func (c *blockingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return blockingTx{}, nil
+}
+
+func (c *blockingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+type blockingTx struct{}
+
+func (blockingTx) Commit() error   { return nil }
+func (blockingTx) Rollback() error { return nil }
+
+func TestConnectorTxContext(t *testing.T) {
+	c := &connector{driver: blockingDriver{}}
+	c.current, _ = c.open("a")
+	db := sql.OpenDB(c)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	tx, err := db.BeginTx(ctx, nil)
+	assert.NoError(t, err)
+
+	// gorm 执行语句时不传 context，事务的 context 结束时语句同样被中断
+	done := make(chan error, 1)
+	go func() {
+		_, err := tx.Exec("SELECT SLEEP(10)")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		assert.Equal(t, context.DeadlineExceeded, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("statement not canceled with transaction context")
+	}
+	tx.Rollback()
+}
@@ -1,17 +1,34 @@
 package gorm
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jinzhu/gorm"
 	"github.com/micro/go-micro/v2/config"
+	"github.com/xxxmicro/base/database/breaker"
+	"github.com/xxxmicro/base/database/datasource"
+	"github.com/xxxmicro/base/database/reload"
 	"github.com/xxxmicro/base/database/gorm/opentracing"
+	"github.com/xxxmicro/base/database/gorm/prometheus"
+	"github.com/xxxmicro/base/database/gorm/slowlog"
 	"time"
 )
 
 func NewDbProvider(config config.Config) (*gorm.DB, error) {
-	driver := config.Get("db", "driver").String("")
-	connectionString := config.Get("db", "connection_string").String("")
+	return NewDB(config, datasource.Default)
+}
+
+// NewDB 按数据源名称创建连接，default 读取 db 下的配置，其它读取 db.sources.<name>
+func NewDB(config config.Config, name string) (*gorm.DB, error) {
+	path := datasource.Path("db", name)
+	get := func(key string) []string {
+		return append(append([]string{}, path...), key)
+	}
+
+	driver := config.Get(get("driver")...).String("")
+	connectionString := config.Get(get("connection_string")...).String("")
 
 	if len(driver) == 0 {
 		return nil, errors.New("driver is empty")
@@ -21,23 +38,107 @@ func NewDbProvider(config config.Config) (*gorm.DB, error) {
 		return nil, errors.New("connection_string is empty")
 	}
 
-	db, err := gorm.Open(driver, connectionString)
+	poolOpts, err := poolOptions(config, path...)
+	if err != nil {
+		return nil, err
+	}
+
+	dsn, err := dataSourceName(driver, connectionString, name, poolOpts)
 	if err != nil {
 		return nil, err
 	}
 
+	c, err := newConnector(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// 通过可替换的 connector 打开，配置热更新时 *gorm.DB 不变
+	sqlDB := sql.OpenDB(c)
+	db, err := gorm.Open(driver, sqlDB)
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
 	// defer db.Close()
-	db.LogMode(true)
-	db.DB().SetMaxIdleConns(10)
-	db.DB().SetConnMaxLifetime(3 * time.Minute)
+	// 全量 SQL 日志改为只记录慢查询和出错的语句，见 slowlog
+	db.LogMode(false)
+	applyPool(db.DB(), poolOpts)
 
 	addAutoCallbacks(db)
 
+	slowThreshold := config.Get(get("slow_threshold")...).Duration(200 * time.Millisecond)
+
 	opentracing.AddGormCallbacks(db)
+	prometheus.AddGormCallbacks(db, prometheus.Name(name), prometheus.SlowThreshold(slowThreshold))
+	slowlog.AddGormCallbacks(db, slowlogOptions(config, slowThreshold, path...)...)
+
+	if b := breaker.FromConfig(config, "gorm "+name, path...); b != nil {
+		db = db.Set(breakerGormKey, b)
+	}
+
+	c.stopWatch = watchConfigChange(config, db, c, name, dsn)
 
 	return db, nil
 }
 
+// watchConfigChange 连接池配置变化时直接生效，连接串变化时替换连接串并清空空闲连接，驱动变化需要重启
+func watchConfigChange(config config.Config, db *gorm.DB, c *connector, name string, dsn string) (stop func()) {
+	path := datasource.Path("db", name)
+	get := func(key string) []string {
+		return append(append([]string{}, path...), key)
+	}
+	driver := config.Get(get("driver")...).String("")
+
+	return reload.Watch(config, "gorm "+name, func() error {
+		if d := config.Get(get("driver")...).String(""); d != driver {
+			return errors.New("driver changed from " + driver + " to " + d + ", restart required")
+		}
+		connectionString := config.Get(get("connection_string")...).String("")
+		if len(connectionString) == 0 {
+			return errors.New("connection_string is empty")
+		}
+		poolOpts, err := poolOptions(config, path...)
+		if err != nil {
+			return err
+		}
+		next, err := dataSourceName(driver, connectionString, name, poolOpts)
+		if err != nil {
+			return err
+		}
+
+		if next != dsn {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := c.swap(ctx, next); err != nil {
+				return err
+			}
+			dsn = next
+
+			// 关闭旧连接串的空闲连接，使用中的连接归还后丢弃
+			db.DB().SetMaxIdleConns(0)
+		}
+		applyPool(db.DB(), poolOpts)
+		return nil
+	}, path...)
+}
+
+// slowlogOptions redact_args 为 true 时隐藏全部参数，redact_columns 指定需要隐藏的列
+func slowlogOptions(config config.Config, slowThreshold time.Duration, path ...string) []slowlog.Option {
+	get := func(key string) []string {
+		return append(append([]string{}, path...), key)
+	}
+
+	opts := []slowlog.Option{slowlog.SlowThreshold(slowThreshold)}
+	if config.Get(get("redact_args")...).Bool(false) {
+		opts = append(opts, slowlog.Redact(slowlog.RedactAll()))
+	} else if columns := config.Get(get("redact_columns")...).StringSlice(nil); len(columns) > 0 {
+		opts = append(opts, slowlog.Redact(slowlog.RedactColumns(columns...)))
+	}
+	return opts
+}
+
 func addAutoCallbacks(db *gorm.DB) {
 	// 替换替换默认的钩子
 	db.Callback().Create().Replace("gorm:update_time_stamp", updateTimeForCreateCallback)
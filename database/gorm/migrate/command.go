@@ -0,0 +1,104 @@
+package migrate
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/micro/cli/v2"
+)
+
+// Command 返回 migrate 命令，包含 up、down 和 status 子命令，newMigrator 在命令执行时创建 Migrator
+// 例如 app.Commands = append(app.Commands, migrate.Command(func(c *cli.Context, opts ...migrate.Option) (*migrate.Migrator, error) {...}))
+func Command(newMigrator func(c *cli.Context, opts ...Option) (*Migrator, error)) *cli.Command {
+	dryRun := &cli.BoolFlag{Name: "dry-run", Usage: "print pending migrations without applying them"}
+
+	return &cli.Command{
+		Name:  "migrate",
+		Usage: "Run database schema migrations",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "up",
+				Usage: "Apply all pending migrations",
+				Flags: []cli.Flag{dryRun},
+				Action: func(c *cli.Context) error {
+					m, err := newMigrator(c, DryRun(c.Bool("dry-run")))
+					if err != nil {
+						return err
+					}
+					migrations, err := m.Up(c.Context)
+					printMigrations(os.Stdout, "up", migrations, c.Bool("dry-run"))
+					return err
+				},
+			},
+			{
+				Name:  "down",
+				Usage: "Roll back the most recent migrations",
+				Flags: []cli.Flag{
+					dryRun,
+					&cli.IntFlag{Name: "steps", Value: 1, Usage: "number of migrations to roll back"},
+				},
+				Action: func(c *cli.Context) error {
+					m, err := newMigrator(c, DryRun(c.Bool("dry-run")))
+					if err != nil {
+						return err
+					}
+					migrations, err := m.Down(c.Context, c.Int("steps"))
+					printMigrations(os.Stdout, "down", migrations, c.Bool("dry-run"))
+					return err
+				},
+			},
+			{
+				Name:  "status",
+				Usage: "Show applied and pending migrations",
+				Action: func(c *cli.Context) error {
+					m, err := newMigrator(c)
+					if err != nil {
+						return err
+					}
+					status, err := m.Status(c.Context)
+					if err != nil {
+						return err
+					}
+					PrintStatus(os.Stdout, status)
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// PrintStatus 以表格输出迁移状态
+func PrintStatus(w io.Writer, status []*Status) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range status {
+		state, appliedAt := "pending", ""
+		if s.Applied {
+			state, appliedAt = "applied", s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		if s.Modified {
+			state = "modified"
+		}
+		if s.Missing {
+			state = "missing"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	tw.Flush()
+}
+
+func printMigrations(w io.Writer, action string, migrations []*Migration, dryRun bool) {
+	if len(migrations) == 0 {
+		fmt.Fprintln(w, "no migrations to "+action)
+		return
+	}
+	for _, migration := range migrations {
+		if dryRun {
+			fmt.Fprintf(w, "would %s %s\n", action, migration)
+		} else {
+			fmt.Fprintf(w, "%s %s\n", action, migration)
+		}
+	}
+}
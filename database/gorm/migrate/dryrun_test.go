@@ -0,0 +1,108 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordDriver 记录执行的语句，除 SELECT DATABASE() 外的查询都返回空结果，表示迁移记录表不存在
+type recordDriver struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+func (d *recordDriver) Open(name string) (driver.Conn, error) {
+	return &recordConn{d: d}, nil
+}
+
+func (d *recordDriver) contains(s string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, q := range d.queries {
+		if strings.Contains(q, s) {
+			return true
+		}
+	}
+	return false
+}
+
+type recordConn struct {
+	d *recordDriver
+}
+
+func (c *recordConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.mu.Lock()
+	c.d.queries = append(c.d.queries, query)
+	c.d.mu.Unlock()
+	return &recordStmt{query: query}, nil
+}
+
+func (c *recordConn) Close() error              { return nil }
+func (c *recordConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *recordConn) Commit() error             { return nil }
+func (c *recordConn) Rollback() error           { return nil }
+
+type recordStmt struct {
+	query string
+}
+
+func (s *recordStmt) Close() error  { return nil }
+func (s *recordStmt) NumInput() int { return -1 }
+
+func (s *recordStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+func (s *recordStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &recordRows{done: !strings.Contains(s.query, "DATABASE()")}, nil
+}
+
+type recordRows struct {
+	done bool
+}
+
+func (r *recordRows) Columns() []string { return []string{"v"} }
+func (r *recordRows) Close() error      { return nil }
+
+func (r *recordRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = "test"
+	return nil
+}
+
+func TestDryRunReadOnly(t *testing.T) {
+	d := &recordDriver{}
+	sql.Register("migrate_record", d)
+	sqlDB, err := sql.Open("migrate_record", "")
+	assert.NoError(t, err)
+	db, err := gorm.Open("mysql", sqlDB)
+	assert.NoError(t, err)
+
+	m := New(db, DryRun(true))
+	assert.NoError(t, m.Add(&Migration{Version: "1", Name: "a", UpSQL: "CREATE TABLE a (id INT)"}))
+
+	// 迁移记录表不存在时视为没有已执行的迁移
+	status, err := m.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, status, 1)
+	assert.False(t, status[0].Applied)
+
+	migrations, err := m.Up(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, migrations, 1)
+
+	assert.False(t, d.contains("CREATE TABLE"))
+	assert.False(t, d.contains("GET_LOCK"))
+	assert.False(t, d.contains("FROM `schema_migrations`"))
+}
@@ -0,0 +1,126 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"hash/fnv"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+var ErrLockTimeout = errors.New("ERR_MIGRATION_LOCK_TIMEOUT")
+
+// locker 跨实例的迁移锁，Lock 在 context 结束前拿不到锁时返回 ErrLockTimeout
+type locker interface {
+	Lock(c context.Context) (unlock func() error, err error)
+}
+
+// newLocker mysql 和 postgres 使用会话级的咨询锁，连接断开时自动释放，其它数据库使用锁表
+func newLocker(db *gorm.DB, name string) locker {
+	switch db.Dialect().GetName() {
+	case "mysql":
+		return &sessionLocker{
+			db:     db.DB(),
+			lock:   "SELECT GET_LOCK(?, 0)",
+			unlock: "SELECT RELEASE_LOCK(?)",
+			key:    name,
+		}
+	case "postgres":
+		h := fnv.New64a()
+		h.Write([]byte(name))
+		return &sessionLocker{
+			db:     db.DB(),
+			lock:   "SELECT pg_try_advisory_lock($1)",
+			unlock: "SELECT pg_advisory_unlock($1)",
+			key:    int64(h.Sum64()),
+		}
+	}
+	return &tableLocker{db: db, table: name + "_lock"}
+}
+
+// sessionLocker 占用一个连接直到释放锁
+type sessionLocker struct {
+	db     *sql.DB
+	lock   string
+	unlock string
+	key    interface{}
+}
+
+func (l *sessionLocker) Lock(c context.Context) (func() error, error) {
+	conn, err := l.db.Conn(c)
+	if err != nil {
+		return nil, err
+	}
+
+	err = retry(c, func() (bool, error) {
+		var locked bool
+		if err := conn.QueryRowContext(c, l.lock, l.key).Scan(&locked); err != nil {
+			return false, err
+		}
+		return locked, nil
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return func() error {
+		defer conn.Close()
+		_, err := conn.ExecContext(context.Background(), l.unlock, l.key)
+		return err
+	}, nil
+}
+
+// lockRecord 锁表中只有一行，插入成功即拿到锁
+type lockRecord struct {
+	ID       int `gorm:"primary_key;auto_increment:false"`
+	LockedAt time.Time
+}
+
+// tableLocker 进程异常退出时锁不会释放，需要手动删除锁表中的记录
+type tableLocker struct {
+	db    *gorm.DB
+	table string
+}
+
+func (l *tableLocker) Lock(c context.Context) (func() error, error) {
+	if err := l.db.Table(l.table).AutoMigrate(&lockRecord{}).Error; err != nil && !l.db.HasTable(l.table) {
+		return nil, err
+	}
+
+	err := retry(c, func() (bool, error) {
+		err := l.db.Table(l.table).Create(&lockRecord{ID: 1, LockedAt: time.Now()}).Error
+		return err == nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		return l.db.Table(l.table).Where("id = ?", 1).Delete(&lockRecord{}).Error
+	}, nil
+}
+
+// retry 每秒尝试一次，直到拿到锁或 context 结束
+func retry(c context.Context, try func() (bool, error)) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		locked, err := try()
+		if err != nil {
+			return err
+		}
+		if locked {
+			return nil
+		}
+
+		select {
+		case <-c.Done():
+			return ErrLockTimeout
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/micro/go-micro/v2/logger"
+)
+
+var (
+	ErrChecksumMismatch = errors.New("ERR_MIGRATION_CHECKSUM_MISMATCH")
+	ErrDuplicateVersion = errors.New("ERR_MIGRATION_DUPLICATE_VERSION")
+	ErrNoDown           = errors.New("ERR_MIGRATION_NO_DOWN")
+	ErrUnknownApplied   = errors.New("ERR_MIGRATION_UNKNOWN_APPLIED")
+)
+
+// Migration 一次迁移，Go 迁移设置 Up/Down，SQL 迁移设置 UpSQL/DownSQL，见 LoadDir
+type Migration struct {
+	Version string // 按字典序执行，建议使用时间戳如 20200801120000
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+	UpSQL   string
+	DownSQL string
+}
+
+// Checksum SQL 迁移为 UpSQL 的 sha256，Go 迁移无法计算代码内容，使用版本和名称
+func (m *Migration) Checksum() string {
+	content := m.UpSQL
+	if m.Up != nil {
+		content = "go:" + m.Version + "_" + m.Name
+	}
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m *Migration) up(tx *gorm.DB) error {
+	if m.Up != nil {
+		return m.Up(tx)
+	}
+	return execSQL(tx, m.UpSQL)
+}
+
+func (m *Migration) down(tx *gorm.DB) error {
+	if m.Down != nil {
+		return m.Down(tx)
+	}
+	if len(m.DownSQL) == 0 {
+		return fmt.Errorf("%w %s", ErrNoDown, m.Version)
+	}
+	return execSQL(tx, m.DownSQL)
+}
+
+func (m *Migration) String() string {
+	return m.Version + "_" + m.Name
+}
+
+// record schema_migrations 表中的一条记录
+type record struct {
+	Version   string `gorm:"primary_key;size:191"`
+	Name      string `gorm:"size:255"`
+	Checksum  string `gorm:"size:64"`
+	AppliedAt time.Time
+}
+
+// Status 迁移状态，Missing 表示数据库中已执行但当前代码中不存在
+type Status struct {
+	Version   string
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Modified  bool // 已执行后内容发生变化
+	Missing   bool
+}
+
+type Options struct {
+	TableName   string        // 迁移记录表，默认 schema_migrations
+	LockTimeout time.Duration // 等待其它实例释放迁移锁的时间，默认 1 分钟
+	DryRun      bool          // 只输出将要执行的迁移，不修改数据库
+	Logger      logger.Logger
+}
+
+type Option func(o *Options)
+
+func TableName(name string) Option {
+	return func(o *Options) {
+		o.TableName = name
+	}
+}
+
+func LockTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.LockTimeout = d
+	}
+}
+
+func DryRun(dryRun bool) Option {
+	return func(o *Options) {
+		o.DryRun = dryRun
+	}
+}
+
+func WithLogger(l logger.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
+
+// Migrator 按版本顺序执行迁移，执行期间持有数据库锁，多个副本同时启动时只有一个执行
+// 服务启动时使用：m := migrate.New(db); m.AddDir("migrations"); m.Up(ctx)，命令行使用见 Command
+type Migrator struct {
+	db         *gorm.DB
+	options    Options
+	migrations map[string]*Migration
+}
+
+func New(db *gorm.DB, opts ...Option) *Migrator {
+	options := Options{
+		TableName:   "schema_migrations",
+		LockTimeout: time.Minute,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	if options.Logger == nil {
+		options.Logger = logger.DefaultLogger
+	}
+
+	return &Migrator{
+		db:         db,
+		options:    options,
+		migrations: make(map[string]*Migration),
+	}
+}
+
+// Add 注册迁移，版本号重复时返回 ErrDuplicateVersion
+func (m *Migrator) Add(migrations ...*Migration) error {
+	for _, migration := range migrations {
+		if _, ok := m.migrations[migration.Version]; ok {
+			return fmt.Errorf("%w %s", ErrDuplicateVersion, migration.Version)
+		}
+		m.migrations[migration.Version] = migration
+	}
+	return nil
+}
+
+// AddDir 注册目录下的 SQL 迁移，见 LoadDir
+func (m *Migrator) AddDir(dir string) error {
+	migrations, err := LoadDir(dir)
+	if err != nil {
+		return err
+	}
+	return m.Add(migrations...)
+}
+
+// Status 所有迁移的执行状态，按版本排序，只读取迁移记录，不建表也不加锁
+func (m *Migrator) Status(c context.Context) ([]*Status, error) {
+	applied, err := m.applied(c)
+	if err != nil {
+		return nil, err
+	}
+	return status(m.sorted(), applied), nil
+}
+
+// Up 执行全部未执行的迁移，返回执行(DryRun 时为将要执行)的迁移
+// 已执行的迁移内容变化时返回 ErrChecksumMismatch，不执行任何迁移
+func (m *Migrator) Up(c context.Context) (migrations []*Migration, err error) {
+	err = m.locked(c, func() error {
+		applied, err := m.applied(c)
+		if err != nil {
+			return err
+		}
+		migrations, err = pending(m.sorted(), applied)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			if err = m.run(c, migration, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// Down 按版本倒序回滚最近执行的 steps 个迁移
+func (m *Migrator) Down(c context.Context, steps int) (migrations []*Migration, err error) {
+	err = m.locked(c, func() error {
+		applied, err := m.applied(c)
+		if err != nil {
+			return err
+		}
+		migrations, err = rollback(m.sorted(), applied, steps)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			if err = m.run(c, migration, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return
+}
+
+func (m *Migrator) run(c context.Context, migration *Migration, up bool) error {
+	action, sql := "up", migration.UpSQL
+	if !up {
+		action, sql = "down", migration.DownSQL
+	}
+	if m.options.DryRun {
+		m.options.Logger.Fields(map[string]interface{}{"sql": sql}).Logf(logger.InfoLevel, "migrate %s %s (dry run)", action, migration)
+		return nil
+	}
+
+	start := time.Now()
+	if err := m.transaction(c, func(tx *gorm.DB) error {
+		if up {
+			if err := migration.up(tx); err != nil {
+				return err
+			}
+			return tx.Table(m.options.TableName).Create(&record{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				Checksum:  migration.Checksum(),
+				AppliedAt: time.Now(),
+			}).Error
+		}
+
+		if err := migration.down(tx); err != nil {
+			return err
+		}
+		return tx.Table(m.options.TableName).Where("version = ?", migration.Version).Delete(&record{}).Error
+	}); err != nil {
+		return fmt.Errorf("migrate %s %s: %w", action, migration, err)
+	}
+
+	m.options.Logger.Logf(logger.InfoLevel, "migrate %s %s in %s", action, migration, time.Since(start))
+	return nil
+}
+
+// transaction 每个迁移和它的记录在同一事务中，MySQL 的 DDL 会隐式提交，失败时可能只执行了部分语句
+func (m *Migrator) transaction(c context.Context, fn func(tx *gorm.DB) error) (err error) {
+	tx := m.db.BeginTx(c, nil)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// locked 持有迁移锁执行 fn，锁内创建迁移记录表，避免多个副本同时建表
+// DryRun 不修改数据库，不加锁也不建表，直接执行 fn
+func (m *Migrator) locked(c context.Context, fn func() error) error {
+	if m.options.DryRun {
+		return fn()
+	}
+
+	lockContext, cancel := context.WithTimeout(c, m.options.LockTimeout)
+	unlock, err := newLocker(m.db, m.options.TableName).Lock(lockContext)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := unlock(); err != nil {
+			m.options.Logger.Logf(logger.WarnLevel, "migrate unlock: %v", err)
+		}
+	}()
+
+	if err := m.ensureTable(); err != nil {
+		return err
+	}
+	return fn()
+}
+
+func (m *Migrator) ensureTable() error {
+	return m.db.Table(m.options.TableName).AutoMigrate(&record{}).Error
+}
+
+// applied 已执行的迁移，迁移记录表不存在时(从未执行过 Up)视为没有已执行的迁移
+func (m *Migrator) applied(c context.Context) (map[string]*record, error) {
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	if !m.db.HasTable(m.options.TableName) {
+		return map[string]*record{}, nil
+	}
+
+	var records []*record
+	if err := m.db.Table(m.options.TableName).Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[string]*record, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+func (m *Migrator) sorted() []*Migration {
+	migrations := make([]*Migration, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations
+}
+
+// pending 未执行的迁移，低于已执行最高版本的迁移(如合并分支带来的)同样执行
+func pending(migrations []*Migration, applied map[string]*record) ([]*Migration, error) {
+	var result []*Migration
+	for _, migration := range migrations {
+		r, ok := applied[migration.Version]
+		if !ok {
+			result = append(result, migration)
+			continue
+		}
+		if r.Checksum != migration.Checksum() {
+			return nil, fmt.Errorf("%w %s", ErrChecksumMismatch, migration)
+		}
+	}
+	return result, nil
+}
+
+// rollback 最近执行的 steps 个迁移，按版本倒序
+func rollback(migrations []*Migration, applied map[string]*record, steps int) ([]*Migration, error) {
+	known := make(map[string]*Migration, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Version] = migration
+	}
+
+	versions := make([]string, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(versions)))
+
+	var result []*Migration
+	for _, version := range versions {
+		if len(result) >= steps {
+			break
+		}
+		migration, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("%w %s", ErrUnknownApplied, version)
+		}
+		result = append(result, migration)
+	}
+	return result, nil
+}
+
+func status(migrations []*Migration, applied map[string]*record) []*Status {
+	result := make([]*Status, 0, len(migrations))
+	known := make(map[string]bool, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Version] = true
+		s := &Status{Version: migration.Version, Name: migration.Name}
+		if r, ok := applied[migration.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = r.AppliedAt
+			s.Modified = r.Checksum != migration.Checksum()
+		}
+		result = append(result, s)
+	}
+
+	for version, r := range applied {
+		if !known[version] {
+			result = append(result, &Status{Version: version, Name: r.Name, Applied: true, AppliedAt: r.AppliedAt, Missing: true})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+	return result
+}
@@ -0,0 +1,131 @@
+package migrate
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitStatements(t *testing.T) {
+	sql := `
+-- create users; with comment
+CREATE TABLE users (id INT, name VARCHAR(32) DEFAULT 'a;b');
+INSERT INTO users VALUES (1, 'it\'s;');
+UPDATE ` + "`users`" + ` SET name = "x;y"
+`
+	statements := splitStatements(sql)
+	assert.Equal(t, []string{
+		"CREATE TABLE users (id INT, name VARCHAR(32) DEFAULT 'a;b')",
+		`INSERT INTO users VALUES (1, 'it\'s;')`,
+		"UPDATE `users` SET name = \"x;y\"",
+	}, statements)
+}
+
+func TestSplitStatementsBlockComment(t *testing.T) {
+	sql := `
+/* drop old; tables */
+DROP TABLE old;
+/*!40101 SET NAMES utf8mb4 */;
+SELECT /*+ MAX_EXECUTION_TIME(1000) */ id FROM users /* a; b */ WHERE id = 1;
+`
+	statements := splitStatements(sql)
+	assert.Equal(t, []string{
+		"DROP TABLE old",
+		"/*!40101 SET NAMES utf8mb4 */",
+		"SELECT /*+ MAX_EXECUTION_TIME(1000) */ id FROM users   WHERE id = 1",
+	}, statements)
+}
+
+func TestSplitStatementsDollarQuote(t *testing.T) {
+	sql := `
+CREATE FUNCTION touch() RETURNS trigger AS $$
+BEGIN
+  NEW.updated_at = now();
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+DO $body$ BEGIN PERFORM 'x;$$'; END $body$;
+PREPARE q AS SELECT * FROM users WHERE id = $1;
+`
+	statements := splitStatements(sql)
+	assert.Equal(t, []string{
+		"CREATE FUNCTION touch() RETURNS trigger AS $$\nBEGIN\n  NEW.updated_at = now();\n  RETURN NEW;\nEND;\n$$ LANGUAGE plpgsql",
+		"DO $body$ BEGIN PERFORM 'x;$$'; END $body$",
+		"PREPARE q AS SELECT * FROM users WHERE id = $1",
+	}, statements)
+}
+
+func TestLoadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"20200801000000_create_users.up.sql":   "CREATE TABLE users (id INT);",
+		"20200801000000_create_users.down.sql": "DROP TABLE users;",
+		"20200802000000_add_name.up.sql":       "ALTER TABLE users ADD name VARCHAR(32);",
+		"README.md":                            "ignored",
+	}
+	for name, content := range files {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	m := New(nil)
+	assert.NoError(t, m.AddDir(dir))
+	migrations := m.sorted()
+	assert.Len(t, migrations, 2)
+	assert.Equal(t, "20200801000000_create_users", migrations[0].String())
+	assert.Equal(t, "DROP TABLE users;", migrations[0].DownSQL)
+	assert.Empty(t, migrations[1].DownSQL)
+
+	err = m.AddDir(dir)
+	assert.True(t, errors.Is(err, ErrDuplicateVersion))
+}
+
+func TestPlan(t *testing.T) {
+	noop := func(tx *gorm.DB) error { return nil }
+	m1 := &Migration{Version: "1", Name: "a", UpSQL: "CREATE TABLE a (id INT)"}
+	m2 := &Migration{Version: "2", Name: "b", Up: noop, Down: noop}
+	m3 := &Migration{Version: "3", Name: "c", UpSQL: "CREATE TABLE c (id INT)"}
+	migrations := []*Migration{m1, m2, m3}
+
+	applied := map[string]*record{
+		"1": {Version: "1", Checksum: m1.Checksum()},
+		"3": {Version: "3", Checksum: m3.Checksum()},
+	}
+
+	result, err := pending(migrations, applied)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Migration{m2}, result)
+
+	result, err = rollback(migrations, applied, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Migration{m3, m1}, result)
+
+	applied["3"].Checksum = "changed"
+	_, err = pending(migrations, applied)
+	assert.True(t, errors.Is(err, ErrChecksumMismatch))
+
+	applied["4"] = &record{Version: "4", Name: "d", AppliedAt: time.Now()}
+	_, err = rollback(migrations, applied, 1)
+	assert.True(t, errors.Is(err, ErrUnknownApplied))
+
+	s := status(migrations, applied)
+	assert.Len(t, s, 4)
+	assert.True(t, s[0].Applied)
+	assert.False(t, s[1].Applied)
+	assert.True(t, s[2].Modified)
+	assert.True(t, s[3].Missing)
+
+	var buf bytes.Buffer
+	PrintStatus(&buf, s)
+	assert.Contains(t, buf.String(), "pending")
+	assert.Contains(t, buf.String(), "missing")
+}
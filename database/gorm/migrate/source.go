@@ -0,0 +1,181 @@
+package migrate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 文件名格式 <version>_<name>.up.sql 和 <version>_<name>.down.sql
+var fileRegexp = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// LoadDir 读取目录下的 SQL 迁移，down 文件可以不存在
+func LoadDir(dir string) ([]*Migration, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[string]*Migration)
+	var migrations []*Migration
+	for _, file := range files {
+		m := fileRegexp.FindStringSubmatch(file.Name())
+		if file.IsDir() || m == nil {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		version, name, direction := m[1], m[2], m[3]
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+			migrations = append(migrations, migration)
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("%w %s: %s and %s", ErrDuplicateVersion, version, migration.Name, name)
+		}
+
+		if direction == "up" {
+			migration.UpSQL = string(data)
+		} else {
+			migration.DownSQL = string(data)
+		}
+	}
+
+	for _, migration := range migrations {
+		if len(migration.UpSQL) == 0 {
+			return nil, fmt.Errorf("ERR_MIGRATION_NO_UP %s", migration)
+		}
+	}
+	return migrations, nil
+}
+
+// execSQL 逐条执行，驱动默认不支持一次执行多条语句
+func execSQL(tx *gorm.DB, sql string) error {
+	for _, statement := range splitStatements(sql) {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitStatements 按分号拆分语句，跳过引号、/* */ 注释和 postgres $$ / $tag$ 字符串内的分号，去掉 -- 注释
+// 普通 /* */ 注释按空白处理，mysql 的 /*! */ 条件注释和 /*+ */ 优化器提示会被执行，原样保留
+func splitStatements(sql string) []string {
+	var (
+		statements []string
+		current    strings.Builder
+		quote      rune
+		dollar     string // 当前 $tag$ 字符串的定界符
+		comment    bool
+		block      bool
+		keep       bool // 保留当前 /* */ 注释
+	)
+
+	flush := func() {
+		if s := strings.TrimSpace(current.String()); len(s) > 0 {
+			statements = append(statements, s)
+		}
+		current.Reset()
+	}
+
+	runes := []rune(sql)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case comment:
+			if r == '\n' {
+				comment = false
+				current.WriteRune(r)
+			}
+			continue
+		case block:
+			if r == '*' && i+1 < len(runes) && runes[i+1] == '/' {
+				block = false
+				i++
+				if keep {
+					current.WriteString("*/")
+				} else {
+					current.WriteRune(' ')
+				}
+				continue
+			}
+			if !keep {
+				continue
+			}
+		case dollar != "":
+			if strings.HasPrefix(string(runes[i:]), dollar) {
+				current.WriteString(dollar)
+				i += len([]rune(dollar)) - 1
+				dollar = ""
+				continue
+			}
+		case quote != 0:
+			if r == '\\' && quote != '`' && i+1 < len(runes) {
+				current.WriteRune(r)
+				i++
+				r = runes[i]
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			comment = true
+			continue
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			block = true
+			keep = i+2 < len(runes) && (runes[i+2] == '!' || runes[i+2] == '+')
+			i++
+			if keep {
+				current.WriteString("/*")
+			}
+			continue
+		case r == '$' && (i == 0 || !isIdentRune(runes[i-1])):
+			if tag := dollarTag(runes[i:]); tag != "" {
+				dollar = tag
+				current.WriteString(tag)
+				i += len([]rune(tag)) - 1
+				continue
+			}
+		case r == ';':
+			flush()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	flush()
+
+	return statements
+}
+
+// dollarTag 匹配 postgres 的 $$ 或 $tag$ 定界符，tag 不能以数字开头，$1 这样的参数不是定界符
+func dollarTag(runes []rune) string {
+	for i := 1; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '$':
+			return string(runes[:i+1])
+		case r >= '0' && r <= '9':
+			if i == 1 {
+				return ""
+			}
+		case !isIdentRune(r):
+			return ""
+		}
+	}
+	return ""
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r > 127
+}
@@ -26,6 +26,17 @@ func SetSpanToGorm(ctx context.Context, db *gorm.DB) *gorm.DB {
 	return db.Set(parentSpanGormKey, parentSpan)
 }
 
+// SpanFromScope returns the sql span of the statement, or the span set by SetSpanToGorm before it starts
+func SpanFromScope(scope *gorm.Scope) opentracing.Span {
+	if val, ok := scope.Get(spanGormKey); ok {
+		return val.(opentracing.Span)
+	}
+	if val, ok := scope.Get(parentSpanGormKey); ok {
+		return val.(opentracing.Span)
+	}
+	return nil
+}
+
 // AddGormCallbacks adds callbacks for tracing, you should call SetSpanToGorm to make them work
 func AddGormCallbacks(db *gorm.DB) {
 	callbacks := newCallbacks()
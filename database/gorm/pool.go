@@ -0,0 +1,70 @@
+package gorm
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/micro/go-micro/v2/config"
+	"github.com/xxxmicro/base/database/pool"
+)
+
+// defaultPoolOptions 数据库连接池默认配置
+// database/sql 在连接数达到上限时总是等待，wait 和 wait_timeout 不生效，等待时间由 context 控制
+var defaultPoolOptions = pool.Options{
+	MaxOpen:        100,
+	MaxIdle:        10,
+	MaxLifetime:    3 * time.Minute,
+	ConnectTimeout: 5 * time.Second,
+}
+
+// poolOptions 读取 db.pool 和 db.tls 配置
+// database/sql 不会预先建立连接，配置 min_idle 时报错，避免误以为生效
+func poolOptions(config config.Config, path ...string) (pool.Options, error) {
+	o, err := pool.Config(config, defaultPoolOptions, path...)
+	if err != nil {
+		return o, err
+	}
+	if o.MinIdle > 0 {
+		return o, errors.New("ERR_POOL_INVALID min_idle is not supported by database/sql")
+	}
+	return o, nil
+}
+
+// applyPool 设置 database/sql 连接池参数，配置热更新时也会调用
+func applyPool(db *sql.DB, o pool.Options) {
+	db.SetMaxOpenConns(o.MaxOpen)
+	db.SetMaxIdleConns(o.MaxIdle)
+	db.SetConnMaxLifetime(o.MaxLifetime)
+	db.SetConnMaxIdleTime(o.IdleTimeout)
+}
+
+// dataSourceName 超时和 TLS 需要写入连接串，目前只支持 mysql，连接串中已指定的参数优先
+// name 用于注册 mysql 的 TLS 配置
+func dataSourceName(driver string, dsn string, name string, o pool.Options) (string, error) {
+	if driver != "mysql" {
+		return dsn, nil
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = o.ConnectTimeout
+	}
+	if cfg.ReadTimeout == 0 {
+		cfg.ReadTimeout = o.ReadTimeout
+	}
+	if cfg.WriteTimeout == 0 {
+		cfg.WriteTimeout = o.WriteTimeout
+	}
+	if o.TLS != nil && len(cfg.TLSConfig) == 0 {
+		if err = mysql.RegisterTLSConfig(name, o.TLS); err != nil {
+			return "", err
+		}
+		cfg.TLSConfig = name
+	}
+	return cfg.FormatDSN(), nil
+}
@@ -0,0 +1,44 @@
+package gorm
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v2/config"
+	"github.com/micro/go-micro/v2/config/source/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/xxxmicro/base/database/pool"
+)
+
+func TestDataSourceName(t *testing.T) {
+	o := pool.Options{ConnectTimeout: 5 * time.Second, ReadTimeout: 30 * time.Second}
+
+	dsn, err := dataSourceName("mysql", "root:123456@tcp(127.0.0.1:3306)/test?timeout=1s", "test", o)
+	assert.NoError(t, err)
+	assert.Contains(t, dsn, "timeout=1s")
+	assert.Contains(t, dsn, "readTimeout=30s")
+
+	o.TLS = &tls.Config{ServerName: "db.local"}
+	dsn, err = dataSourceName("mysql", "root:123456@tcp(127.0.0.1:3306)/test", "test", o)
+	assert.NoError(t, err)
+	assert.Contains(t, dsn, "tls=test")
+
+	dsn, err = dataSourceName("postgres", "host=localhost", "test", o)
+	assert.NoError(t, err)
+	assert.Equal(t, "host=localhost", dsn)
+}
+
+func TestPoolOptions(t *testing.T) {
+	conf, err := config.NewConfig()
+	assert.NoError(t, err)
+	assert.NoError(t, conf.Load(memory.NewSource(memory.WithJSON([]byte(`{"db": {"pool": {"idle_timeout": "5m"}}}`)))))
+
+	o, err := poolOptions(conf, "db")
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, o.IdleTimeout)
+
+	assert.NoError(t, conf.Load(memory.NewSource(memory.WithJSON([]byte(`{"db": {"pool": {"min_idle": 2}}}`)))))
+	_, err = poolOptions(conf, "db")
+	assert.Error(t, err)
+}
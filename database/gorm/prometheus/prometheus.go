@@ -0,0 +1,196 @@
+package prometheus
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const startTimeGormKey = "prometheusStartTime"
+
+// 注册在 prometheus.DefaultRegisterer 上，与 jaeger 的 prometheus.New() 使用同一个 registry
+var (
+	queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "gorm",
+		Name:      "query_duration_seconds",
+		Help:      "SQL statement latency in seconds.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"db", "table", "operation"})
+
+	queryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "gorm",
+		Name:      "query_errors_total",
+		Help:      "SQL statements that returned an error, record not found excluded.",
+	}, []string{"db", "table", "operation"})
+
+	slowQueries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "gorm",
+		Name:      "slow_queries_total",
+		Help:      "SQL statements slower than the slow threshold.",
+	}, []string{"db", "table", "operation"})
+
+	dbStats = &dbStatsCollector{
+		dbs:          make(map[string]*sql.DB),
+		maxOpen:      prometheus.NewDesc("gorm_db_max_open_connections", "Maximum number of open connections.", []string{"db"}, nil),
+		open:         prometheus.NewDesc("gorm_db_open_connections", "Established connections, in use and idle.", []string{"db"}, nil),
+		inUse:        prometheus.NewDesc("gorm_db_in_use_connections", "Connections currently in use.", []string{"db"}, nil),
+		idle:         prometheus.NewDesc("gorm_db_idle_connections", "Idle connections.", []string{"db"}, nil),
+		waitCount:    prometheus.NewDesc("gorm_db_wait_count_total", "Connections waited for.", []string{"db"}, nil),
+		waitDuration: prometheus.NewDesc("gorm_db_wait_duration_seconds_total", "Time blocked waiting for a new connection.", []string{"db"}, nil),
+	}
+)
+
+func init() {
+	prometheus.MustRegister(queryDuration, queryErrors, slowQueries, dbStats)
+}
+
+type Options struct {
+	Name          string        // 数据源名称，作为 db 标签，默认 default
+	SlowThreshold time.Duration // 超过该耗时计为慢查询，默认 500ms
+}
+
+type Option func(o *Options)
+
+func Name(name string) Option {
+	return func(o *Options) {
+		o.Name = name
+	}
+}
+
+func SlowThreshold(d time.Duration) Option {
+	return func(o *Options) {
+		o.SlowThreshold = d
+	}
+}
+
+// AddGormCallbacks 记录每条语句的耗时、错误和慢查询，并采集连接池状态
+func AddGormCallbacks(db *gorm.DB, opts ...Option) {
+	options := Options{
+		Name:          "default",
+		SlowThreshold: 500 * time.Millisecond,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	c := &callbacks{options: options}
+	registerCallbacks(db, "create", c)
+	registerCallbacks(db, "query", c)
+	registerCallbacks(db, "update", c)
+	registerCallbacks(db, "delete", c)
+	registerCallbacks(db, "row_query", c)
+
+	RegisterDBStats(options.Name, db.DB())
+}
+
+// RegisterDBStats 采集 sql.DBStats，同名的数据源会被替换
+func RegisterDBStats(name string, db *sql.DB) {
+	dbStats.l.Lock()
+	defer dbStats.l.Unlock()
+	dbStats.dbs[name] = db
+}
+
+// UnregisterDBStats 数据源关闭后不再采集
+func UnregisterDBStats(name string) {
+	dbStats.l.Lock()
+	defer dbStats.l.Unlock()
+	delete(dbStats.dbs, name)
+}
+
+type callbacks struct {
+	options Options
+}
+
+func (c *callbacks) before(scope *gorm.Scope) {
+	scope.Set(startTimeGormKey, time.Now())
+}
+
+func (c *callbacks) afterCreate(scope *gorm.Scope)   { c.after(scope, "INSERT") }
+func (c *callbacks) afterQuery(scope *gorm.Scope)    { c.after(scope, "SELECT") }
+func (c *callbacks) afterUpdate(scope *gorm.Scope)   { c.after(scope, "UPDATE") }
+func (c *callbacks) afterDelete(scope *gorm.Scope)   { c.after(scope, "DELETE") }
+func (c *callbacks) afterRowQuery(scope *gorm.Scope) { c.after(scope, "") }
+
+func (c *callbacks) after(scope *gorm.Scope, operation string) {
+	val, ok := scope.Get(startTimeGormKey)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(val.(time.Time))
+
+	if operation == "" {
+		operation = strings.ToUpper(strings.Split(strings.TrimSpace(scope.SQL), " ")[0])
+	}
+	labels := []string{c.options.Name, scope.TableName(), operation}
+
+	queryDuration.WithLabelValues(labels...).Observe(elapsed.Seconds())
+	if scope.HasError() && !gorm.IsRecordNotFoundError(scope.DB().Error) {
+		queryErrors.WithLabelValues(labels...).Inc()
+	}
+	if c.options.SlowThreshold > 0 && elapsed >= c.options.SlowThreshold {
+		slowQueries.WithLabelValues(labels...).Inc()
+	}
+}
+
+func registerCallbacks(db *gorm.DB, name string, c *callbacks) {
+	beforeName := fmt.Sprintf("prometheus:%v_before", name)
+	afterName := fmt.Sprintf("prometheus:%v_after", name)
+	gormCallbackName := fmt.Sprintf("gorm:%v", name)
+	switch name {
+	case "create":
+		db.Callback().Create().Before(gormCallbackName).Register(beforeName, c.before)
+		db.Callback().Create().After(gormCallbackName).Register(afterName, c.afterCreate)
+	case "query":
+		db.Callback().Query().Before(gormCallbackName).Register(beforeName, c.before)
+		db.Callback().Query().After(gormCallbackName).Register(afterName, c.afterQuery)
+	case "update":
+		db.Callback().Update().Before(gormCallbackName).Register(beforeName, c.before)
+		db.Callback().Update().After(gormCallbackName).Register(afterName, c.afterUpdate)
+	case "delete":
+		db.Callback().Delete().Before(gormCallbackName).Register(beforeName, c.before)
+		db.Callback().Delete().After(gormCallbackName).Register(afterName, c.afterDelete)
+	case "row_query":
+		db.Callback().RowQuery().Before(gormCallbackName).Register(beforeName, c.before)
+		db.Callback().RowQuery().After(gormCallbackName).Register(afterName, c.afterRowQuery)
+	}
+}
+
+type dbStatsCollector struct {
+	l            sync.RWMutex
+	dbs          map[string]*sql.DB
+	maxOpen      *prometheus.Desc
+	open         *prometheus.Desc
+	inUse        *prometheus.Desc
+	idle         *prometheus.Desc
+	waitCount    *prometheus.Desc
+	waitDuration *prometheus.Desc
+}
+
+func (c *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+}
+
+func (c *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.l.RLock()
+	defer c.l.RUnlock()
+
+	for name, db := range c.dbs {
+		stats := db.Stats()
+		ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections), name)
+		ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stats.OpenConnections), name)
+		ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse), name)
+		ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle), name)
+		ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount), name)
+		ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds(), name)
+	}
+}
@@ -0,0 +1,33 @@
+package prometheus
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDBStatsCollector(t *testing.T) {
+	// sql.Open 不建立连接
+	db, err := sql.Open("mysql", "root:123456@tcp(127.0.0.1:3306)/test")
+	assert.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(20)
+
+	RegisterDBStats("test", db)
+	defer UnregisterDBStats("test")
+
+	expected := `
+# HELP gorm_db_max_open_connections Maximum number of open connections.
+# TYPE gorm_db_max_open_connections gauge
+gorm_db_max_open_connections{db="test"} 20
+# HELP gorm_db_open_connections Established connections, in use and idle.
+# TYPE gorm_db_open_connections gauge
+gorm_db_open_connections{db="test"} 0
+`
+	err = testutil.CollectAndCompare(dbStats, strings.NewReader(expected), "gorm_db_max_open_connections", "gorm_db_open_connections")
+	assert.NoError(t, err)
+}
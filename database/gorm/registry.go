@@ -0,0 +1,58 @@
+package gorm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+	"github.com/micro/go-micro/v2/config"
+	"github.com/xxxmicro/base/database/datasource"
+)
+
+// Registry 按名称获取配置的数据源，例如 db.sources.primary 和 db.sources.reporting
+type Registry struct {
+	names []string
+	dbs   map[string]*gorm.DB
+}
+
+// NewRegistryProvider 创建所有配置的数据源，任一失败时关闭已创建的连接并返回错误
+func NewRegistryProvider(config config.Config) (*Registry, error) {
+	names := datasource.Names(config, "db", "driver")
+	if len(names) == 0 {
+		return nil, errors.New("no db source configured")
+	}
+
+	r := &Registry{names: names, dbs: make(map[string]*gorm.DB, len(names))}
+	for _, name := range names {
+		db, err := NewDB(config, name)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("db source %s: %w", name, err)
+		}
+		r.dbs[name] = db
+	}
+	return r, nil
+}
+
+// Get 名称未配置时返回 datasource.ErrNotFound
+func (r *Registry) Get(name string) (*gorm.DB, error) {
+	db, ok := r.dbs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w db %s", datasource.ErrNotFound, name)
+	}
+	return db, nil
+}
+
+func (r *Registry) Names() []string {
+	return r.names
+}
+
+func (r *Registry) Close() error {
+	var err error
+	for _, db := range r.dbs {
+		if e := db.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
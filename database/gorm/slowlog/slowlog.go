@@ -0,0 +1,247 @@
+package slowlog
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/micro/go-micro/v2/logger"
+	"github.com/uber/jaeger-client-go"
+	"github.com/xxxmicro/base/database/gorm/opentracing"
+)
+
+const startTimeGormKey = "slowlogStartTime"
+
+// RedactRule 处理一个绑定参数，column 为参数对应的列名(无法识别时为空)，返回 true 表示已处理
+type RedactRule func(column string, arg interface{}) (interface{}, bool)
+
+// RedactColumns 指定列的参数替换为 ***，列名不区分大小写
+func RedactColumns(columns ...string) RedactRule {
+	set := make(map[string]struct{}, len(columns))
+	for _, column := range columns {
+		set[strings.ToLower(column)] = struct{}{}
+	}
+	return func(column string, arg interface{}) (interface{}, bool) {
+		if _, ok := set[strings.ToLower(column)]; ok {
+			return "***", true
+		}
+		return nil, false
+	}
+}
+
+// RedactAll 所有参数替换为 ***
+func RedactAll() RedactRule {
+	return func(column string, arg interface{}) (interface{}, bool) {
+		return "***", true
+	}
+}
+
+// TruncateStrings 超过 max 的字符串和 []byte 截断，避免大字段撑爆日志
+func TruncateStrings(max int) RedactRule {
+	return func(column string, arg interface{}) (interface{}, bool) {
+		switch v := arg.(type) {
+		case string:
+			if len(v) > max {
+				return v[:max] + "...", true
+			}
+		case []byte:
+			if len(v) > max {
+				return fmt.Sprintf("<%d bytes>", len(v)), true
+			}
+			return string(v), true
+		}
+		return nil, false
+	}
+}
+
+type Options struct {
+	SlowThreshold time.Duration // 超过该耗时的语句写日志，默认 200ms
+	Redact        []RedactRule  // 参数脱敏规则，按顺序匹配第一个，最后兜底截断长字符串
+	Logger        logger.Logger // 结构化日志，默认 logger.DefaultLogger
+}
+
+type Option func(o *Options)
+
+func SlowThreshold(d time.Duration) Option {
+	return func(o *Options) {
+		o.SlowThreshold = d
+	}
+}
+
+func Redact(rules ...RedactRule) Option {
+	return func(o *Options) {
+		o.Redact = append(o.Redact, rules...)
+	}
+}
+
+func WithLogger(l logger.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
+
+// AddGormCallbacks 记录超过慢查询阈值的语句和执行出错的语句(记录不存在除外)，代替 LogMode(true) 的全量日志
+// 需要调用 opentracing.SetSpanToGorm 才能带上 trace_id
+func AddGormCallbacks(db *gorm.DB, opts ...Option) {
+	options := Options{
+		SlowThreshold: 200 * time.Millisecond,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	options.Redact = append(options.Redact, TruncateStrings(256))
+	if options.Logger == nil {
+		options.Logger = logger.DefaultLogger
+	}
+
+	c := &callbacks{options: options}
+	db.Callback().Create().Before("gorm:create").Register("slowlog:create_before", c.before)
+	db.Callback().Create().After("gorm:create").Register("slowlog:create_after", c.after)
+	db.Callback().Query().Before("gorm:query").Register("slowlog:query_before", c.before)
+	db.Callback().Query().After("gorm:query").Register("slowlog:query_after", c.after)
+	db.Callback().Update().Before("gorm:update").Register("slowlog:update_before", c.before)
+	db.Callback().Update().After("gorm:update").Register("slowlog:update_after", c.after)
+	db.Callback().Delete().Before("gorm:delete").Register("slowlog:delete_before", c.before)
+	db.Callback().Delete().After("gorm:delete").Register("slowlog:delete_after", c.after)
+	db.Callback().RowQuery().Before("gorm:row_query").Register("slowlog:row_query_before", c.before)
+	db.Callback().RowQuery().After("gorm:row_query").Register("slowlog:row_query_after", c.after)
+}
+
+type callbacks struct {
+	options Options
+}
+
+func (c *callbacks) before(scope *gorm.Scope) {
+	scope.Set(startTimeGormKey, time.Now())
+}
+
+func (c *callbacks) after(scope *gorm.Scope) {
+	val, ok := scope.Get(startTimeGormKey)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(val.(time.Time))
+	// LogMode(false) 同时关闭了 gorm 的错误日志，出错的语句不论耗时都记录
+	failed := scope.HasError() && !gorm.IsRecordNotFoundError(scope.DB().Error)
+	if !failed && elapsed < c.options.SlowThreshold {
+		return
+	}
+
+	fields := map[string]interface{}{
+		"sql":      scope.SQL,
+		"args":     redactArgs(scope.SQL, scope.SQLVars, c.options.Redact),
+		"rows":     scope.DB().RowsAffected,
+		"duration": elapsed.String(),
+		"table":    scope.TableName(),
+		"caller":   caller(),
+	}
+	if scope.HasError() {
+		fields["error"] = scope.DB().Error.Error()
+	}
+	if sp := opentracing.SpanFromScope(scope); sp != nil {
+		if sc, ok := sp.Context().(jaeger.SpanContext); ok {
+			fields["trace_id"] = sc.TraceID().String()
+			fields["span_id"] = sc.SpanID().String()
+		}
+	}
+
+	if failed {
+		c.options.Logger.Fields(fields).Logf(logger.ErrorLevel, "sql error %s", elapsed)
+		return
+	}
+	c.options.Logger.Fields(fields).Logf(logger.WarnLevel, "slow sql %s", elapsed)
+}
+
+// redactArgs 按规则处理绑定参数，没有规则匹配的参数原样输出
+func redactArgs(sql string, args []interface{}, rules []RedactRule) []interface{} {
+	if len(args) == 0 {
+		return args
+	}
+
+	columns := argColumns(sql)
+	result := make([]interface{}, len(args))
+	for i, arg := range args {
+		column := ""
+		if i < len(columns) {
+			column = columns[i]
+		}
+		result[i] = arg
+		for _, rule := range rules {
+			if v, ok := rule(column, arg); ok {
+				result[i] = v
+				break
+			}
+		}
+	}
+	return result
+}
+
+var (
+	placeholderRegexp = regexp.MustCompile(`\?|\$\d+`)
+	// 占位符前的 列名 = / <> / LIKE / IN ( 等比较
+	comparisonRegexp = regexp.MustCompile("(?i)([\\w\"`]+)\\s*(=|<>|!=|>=|<=|>|<|\\bLIKE|\\bIN\\s*\\()\\s*$")
+	insertRegexp     = regexp.MustCompile("(?is)^\\s*INSERT\\s+INTO\\s+\\S+\\s*\\(([^)]*)\\)\\s*VALUES")
+)
+
+// argColumns 从 SQL 中推断每个占位符对应的列名，INSERT 按列清单对应，其它按占位符前的比较或赋值识别
+func argColumns(sql string) []string {
+	locs := placeholderRegexp.FindAllStringIndex(sql, -1)
+	columns := make([]string, len(locs))
+
+	if m := insertRegexp.FindStringSubmatch(sql); m != nil {
+		names := strings.Split(m[1], ",")
+		for i := range columns {
+			if i < len(names) {
+				columns[i] = trimIdentifier(names[i])
+			}
+		}
+		return columns
+	}
+
+	last := ""
+	for i, loc := range locs {
+		prefix := sql[:loc[0]]
+		if m := comparisonRegexp.FindStringSubmatch(prefix); m != nil {
+			last = trimIdentifier(m[1])
+			columns[i] = last
+		} else if strings.HasSuffix(strings.TrimSpace(prefix), ",") && last != "" {
+			// IN (?,?,?) 后续的参数沿用同一列
+			columns[i] = last
+		}
+	}
+	return columns
+}
+
+func trimIdentifier(name string) string {
+	name = strings.TrimSpace(name)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.Trim(name, "`\"")
+}
+
+// caller 跳过 gorm 和本库数据库相关的调用栈，返回业务代码位置
+func caller() string {
+	fallback := ""
+	for i := 2; i < 30; i++ {
+		_, file, line, ok := runtime.Caller(i)
+		if !ok {
+			break
+		}
+		if strings.Contains(file, "jinzhu/gorm") {
+			continue
+		}
+		location := fmt.Sprintf("%s:%d", file, line)
+		if strings.Contains(file, "/database/gorm/") || strings.Contains(file, "/domain/repository/gorm/") {
+			if fallback == "" {
+				fallback = location
+			}
+			continue
+		}
+		return location
+	}
+	return fallback
+}
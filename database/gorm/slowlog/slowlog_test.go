@@ -0,0 +1,94 @@
+package slowlog
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/micro/go-micro/v2/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArgColumns(t *testing.T) {
+	assert.Equal(t, []string{"name", "password", "id"},
+		argColumns("UPDATE `user` SET `name` = ?, `password` = ? WHERE `user`.`id` = ?"))
+	assert.Equal(t, []string{"name", "password"},
+		argColumns("INSERT INTO `user` (`name`,`password`) VALUES (?,?)"))
+	assert.Equal(t, []string{"age", "id", "id", "id", ""},
+		argColumns("SELECT * FROM `user` WHERE (age >= ? AND id IN (?,?,?)) LIMIT ?"))
+	assert.Equal(t, []string{"name"},
+		argColumns(`SELECT * FROM "user" WHERE "name" LIKE $1`))
+}
+
+func TestRedactArgs(t *testing.T) {
+	sql := "UPDATE `user` SET `name` = ?, `password` = ? WHERE `id` = ?"
+	rules := []RedactRule{RedactColumns("Password"), TruncateStrings(4)}
+
+	args := redactArgs(sql, []interface{}{"abcdefg", "secret", 1}, rules)
+	assert.Equal(t, []interface{}{"abcd...", "***", 1}, args)
+
+	args = redactArgs(sql, []interface{}{"abc", []byte(strings.Repeat("x", 10)), 1}, []RedactRule{RedactAll()})
+	assert.Equal(t, []interface{}{"***", "***", "***"}, args)
+}
+
+// recordLogger 记录日志级别和内容
+type recordLogger struct {
+	logger.Logger
+	levels   []logger.Level
+	messages []string
+}
+
+func (l *recordLogger) Fields(fields map[string]interface{}) logger.Logger { return l }
+
+func (l *recordLogger) Logf(level logger.Level, format string, v ...interface{}) {
+	l.levels = append(l.levels, level)
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+type nopDriver struct{}
+
+func (nopDriver) Open(name string) (driver.Conn, error) { return nopConn{}, nil }
+
+type nopConn struct{}
+
+func (nopConn) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (nopConn) Close() error                              { return nil }
+func (nopConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type user struct {
+	ID int
+}
+
+func TestAfterLogsErrors(t *testing.T) {
+	sql.Register("slowlog_nop", nopDriver{})
+	sqlDB, err := sql.Open("slowlog_nop", "")
+	assert.NoError(t, err)
+	db, err := gorm.Open("mysql", sqlDB)
+	assert.NoError(t, err)
+
+	l := &recordLogger{}
+	c := &callbacks{options: Options{SlowThreshold: time.Hour, Logger: l}}
+	run := func(err error) {
+		scope := db.NewScope(&user{})
+		scope.SQL = "SELECT * FROM `users`"
+		c.before(scope)
+		if err != nil {
+			scope.Err(err)
+		}
+		c.after(scope)
+	}
+
+	// 未超过阈值且没有出错，记录不存在不算出错
+	run(nil)
+	run(gorm.ErrRecordNotFound)
+	assert.Empty(t, l.levels)
+
+	run(errors.New("Error 1146: Table 'test.users' doesn't exist"))
+	assert.Equal(t, []logger.Level{logger.ErrorLevel}, l.levels)
+	assert.True(t, strings.HasPrefix(l.messages[0], "sql error"))
+}
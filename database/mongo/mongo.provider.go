@@ -1,82 +1,218 @@
 package mongo
 
-import(
-	"time"
+import (
+	"crypto/tls"
 	"errors"
-	"gopkg.in/mgo.v2"
+	"net"
+	"sync"
+	"time"
+
 	"github.com/micro/go-micro/v2/config"
+	"github.com/micro/go-micro/v2/logger"
+	"github.com/xxxmicro/base/database/breaker"
+	"github.com/xxxmicro/base/database/datasource"
+	"github.com/xxxmicro/base/database/pool"
+	"github.com/xxxmicro/base/database/reload"
+	"gopkg.in/mgo.v2"
 )
 
 // 主要为了 apollo 动态替换内部实现
+// 配置变化时重建 session 并替换，旧 session 上的操作结束后关闭
 type DB struct {
+	// Deprecated: 热更新时被替换，读取不加锁且可能拿到已关闭的 session，使用 Acquire
 	Name string
+	// Deprecated: 同 Name，使用 Acquire
 	Session *mgo.Session
+
+	l         sync.RWMutex
+	current   *generation
+	breaker   *breaker.Breaker // 替换连接时保留
+	stopWatch func()
+}
+
+// generation 一代连接，wg 统计正在使用它的操作
+type generation struct {
+	name    string
+	session *mgo.Session
+	wg      sync.WaitGroup
+}
+
+func NewDB(name string, session *mgo.Session) *DB {
+	return &DB{Name: name, Session: session, current: &generation{name: name, session: session}}
+}
+
+// Acquire 返回当前的全局 session 和数据库名，使用完毕后调用 release，替换连接时会等待 release
+func (db *DB) Acquire() (session *mgo.Session, name string, release func()) {
+	db.l.RLock()
+	g := db.current
+	g.wg.Add(1)
+	db.l.RUnlock()
+	return g.session, g.name, g.wg.Done
+}
+
+// Breaker 数据源的熔断器，未启用时为 nil
+func (db *DB) Breaker() *breaker.Breaker {
+	return db.breaker
+}
+
+// DatabaseName 当前配置的数据库名
+func (db *DB) DatabaseName() string {
+	db.l.RLock()
+	defer db.l.RUnlock()
+	return db.current.name
+}
+
+// Swap 替换为新的 session，旧 session 在操作结束或超过 drainTimeout 后关闭
+func (db *DB) Swap(name string, session *mgo.Session, drainTimeout time.Duration) {
+	db.l.Lock()
+	old := db.current
+	db.current = &generation{name: name, session: session}
+	db.Name, db.Session = name, session
+	db.l.Unlock()
+
+	go func() {
+		if !reload.Drain(&old.wg, drainTimeout) {
+			logger.Warnf("mongo old session not drained in %s, closing", drainTimeout)
+		}
+		old.session.Close()
+	}()
+}
+
+// Close 停止监听配置并关闭当前 session
+func (db *DB) Close() {
+	if db.stopWatch != nil {
+		db.stopWatch()
+	}
+
+	db.l.RLock()
+	defer db.l.RUnlock()
+	db.current.session.Close()
 }
 
 func NewMongoProvider(config config.Config) (*DB, error) {
-	addrs := config.Get("mongo", "addrs").StringSlice(nil)
+	return NewMongo(config, datasource.Default)
+}
+
+// NewMongo 按数据源名称创建连接，default 读取 mongo 下的配置，其它读取 mongo.sources.<name>
+func NewMongo(config config.Config, name string) (*DB, error) {
+	path := datasource.Path("mongo", name)
+	session, database, err := dial(config, path...)
+	if err != nil {
+		return nil, err
+	}
+
+	db := NewDB(database, session)
+	db.breaker = breaker.FromConfig(config, "mongo "+name, path...)
+
+	db.stopWatch = watchConfigChange(config, db, name)
+
+	return db, nil
+}
+
+func dial(config config.Config, path ...string) (*mgo.Session, string, error) {
+	get := func(key string) []string {
+		return append(append([]string{}, path...), key)
+	}
+
+	addrs := config.Get(get("addrs")...).StringSlice(nil)
 	if len(addrs) == 0 {
-		return nil, errors.New("addrs must be set")
+		return nil, "", errors.New("addrs must be set")
 	}
 
-	database := config.Get("mongo", "database").String("")
+	database := config.Get(get("database")...).String("")
 	if len(database) == 0 {
-		return nil, errors.New("database must be set")
+		return nil, "", errors.New("database must be set")
+	}
+
+	poolOptions, err := PoolOptions(config, path...)
+	if err != nil {
+		return nil, "", err
 	}
 
-	poolLimit := config.Get("mongo", "pool_limit").Int(20)
-	
-	timeout := config.Get("mongo", "timeout").Duration(time.Second * 5)
-	mode := config.Get("mongo", "mode").Int(0)
+	mode := config.Get(get("mode")...).Int(0)
 
 	dialInfo := &mgo.DialInfo{
-		Addrs: addrs,
-		Database: database,
-		PoolLimit: poolLimit,
-		Timeout: timeout,
+		Addrs:     addrs,
+		Database:  database,
+		PoolLimit: poolOptions.MaxOpen,
+		Timeout:   poolOptions.ConnectTimeout,
+	}
+
+	if poolOptions.TLS != nil {
+		dialInfo.DialServer = func(addr *mgo.ServerAddr) (net.Conn, error) {
+			dialer := &net.Dialer{Timeout: poolOptions.ConnectTimeout}
+			return tls.DialWithDialer(dialer, "tcp", addr.String(), poolOptions.TLS)
+		}
 	}
 
-	replicaSetName := config.Get("mongo", "replica_set_name").String("")
+	replicaSetName := config.Get(get("replica_set_name")...).String("")
 	if len(replicaSetName) > 0 {
 		dialInfo.ReplicaSetName = replicaSetName
 	}
 
-	username := config.Get("mongo", "username").String("")
+	username := config.Get(get("username")...).String("")
 	if len(username) > 0 {
 		dialInfo.Username = username
 	}
 
-	password := config.Get("mongo", "password").String("")
+	password := config.Get(get("password")...).String("")
 	if len(password) > 0 {
 		dialInfo.Password = password
 	}
 
-	source := config.Get("mongo", "source").String("")
+	source := config.Get(get("source")...).String("")
 	if len(source) >= 0 {
 		dialInfo.Source = source
 	}
 
 	globalSession, err := mgo.DialWithInfo(dialInfo)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	globalSession.SetSocketTimeout(time.Second * 10)
-	globalSession.SetSyncTimeout(time.Second * 20)
-	
+	globalSession.SetSocketTimeout(poolOptions.ReadTimeout)
+	globalSession.SetSyncTimeout(poolOptions.WaitTimeout)
+
 	if mode <= 0 {
 		mode = int(mgo.Primary)
 	}
 	globalSession.SetMode(mgo.Mode(mode), true)
 
+	return globalSession, database, nil
+}
 
-	db := &DB{ Name: database, Session: globalSession }	
+// PoolOptions 读取 <path>.pool 和 <path>.tls 配置，兼容原有的 pool_limit 和 timeout
+// mgo 只支持部分参数：max_open 对应 PoolLimit，read_timeout 对应 socket 超时，wait_timeout 对应等待可用节点的 sync 超时
+func PoolOptions(config config.Config, path ...string) (pool.Options, error) {
+	get := func(key string) []string {
+		return append(append([]string{}, path...), key)
+	}
 
-	go watchConfigChange(config, db)
-	
-	return db, nil
+	defaults := pool.Options{
+		MaxOpen:        config.Get(get("pool_limit")...).Int(20),
+		ConnectTimeout: config.Get(get("timeout")...).Duration(5 * time.Second),
+		ReadTimeout:    10 * time.Second,
+		WaitTimeout:    20 * time.Second,
+	}
+	o, err := pool.Config(config, defaults, path...)
+	if err != nil {
+		return o, err
+	}
+	if o.MaxOpen == 0 {
+		return o, errors.New("ERR_POOL_INVALID mongo max_open must be set")
+	}
+	return o, nil
 }
 
-func watchConfigChange(config config.Config, db *DB) {
-	// TODO
+func watchConfigChange(config config.Config, db *DB, name string) (stop func()) {
+	path := datasource.Path("mongo", name)
+
+	return reload.Watch(config, "mongo "+name, func() error {
+		session, database, err := dial(config, path...)
+		if err != nil {
+			return err
+		}
+		db.Swap(database, session, config.Get(append(path, "drain_timeout")...).Duration(30*time.Second))
+		return nil
+	}, path...)
 }
@@ -0,0 +1,230 @@
+package mongodriver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v2/config"
+	"github.com/micro/go-micro/v2/logger"
+	"github.com/xxxmicro/base/database/breaker"
+	"github.com/xxxmicro/base/database/datasource"
+	"github.com/xxxmicro/base/database/pool"
+	"github.com/xxxmicro/base/database/reload"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"gopkg.in/mgo.v2"
+)
+
+// DB 基于官方驱动的 mongo 连接，配置与 database/mongo 相同，可直接替换
+// 配置变化时重建 client 并替换，旧 client 断开时等待正在使用的连接归还
+type DB struct {
+	// Deprecated: 热更新时被替换，直接读取不加锁，使用 DatabaseName
+	Name string
+	// Deprecated: 热更新时被替换，直接读取不加锁且可能拿到已断开的 client，使用 CurrentClient 或 Database
+	Client *mongo.Client
+
+	l         sync.RWMutex
+	breaker   *breaker.Breaker // 替换连接时保留
+	stopWatch func()
+}
+
+func NewDB(name string, client *mongo.Client) *DB {
+	return &DB{Name: name, Client: client}
+}
+
+// Breaker 数据源的熔断器，未启用时为 nil
+func (db *DB) Breaker() *breaker.Breaker {
+	return db.breaker
+}
+
+// DatabaseName 当前配置的数据库名
+func (db *DB) DatabaseName() string {
+	db.l.RLock()
+	defer db.l.RUnlock()
+	return db.Name
+}
+
+// CurrentClient 当前的 client，配置热更新后会变化，不要长期持有
+func (db *DB) CurrentClient() *mongo.Client {
+	db.l.RLock()
+	defer db.l.RUnlock()
+	return db.Client
+}
+
+// Database 返回配置的数据库
+func (db *DB) Database() *mongo.Database {
+	db.l.RLock()
+	defer db.l.RUnlock()
+	return db.Client.Database(db.Name)
+}
+
+// Swap 替换为新的 client，旧 client 最多等待 drainTimeout 后断开
+func (db *DB) Swap(name string, client *mongo.Client, drainTimeout time.Duration) {
+	db.l.Lock()
+	old := db.Client
+	db.Name, db.Client = name, client
+	db.l.Unlock()
+
+	go func() {
+		c, cancel := context.WithTimeout(context.Background(), drainTimeout)
+		defer cancel()
+		if err := old.Disconnect(c); err != nil {
+			logger.Warnf("mongodriver old client disconnect: %v", err)
+		}
+	}()
+}
+
+func NewMongoDriverProvider(config config.Config) (*DB, error) {
+	return NewMongoDriver(config, datasource.Default)
+}
+
+// NewMongoDriver 按数据源名称创建连接，default 读取 mongo 下的配置，其它读取 mongo.sources.<name>
+func NewMongoDriver(config config.Config, name string) (*DB, error) {
+	path := datasource.Path("mongo", name)
+	client, database, err := connect(config, path...)
+	if err != nil {
+		return nil, err
+	}
+
+	db := NewDB(database, client)
+	db.breaker = breaker.FromConfig(config, "mongodriver "+name, path...)
+
+	db.stopWatch = watchConfigChange(config, db, name)
+
+	return db, nil
+}
+
+// Close 停止监听配置并断开连接，等待使用中的连接归还
+func (db *DB) Close(c context.Context) error {
+	if db.stopWatch != nil {
+		db.stopWatch()
+	}
+	return db.CurrentClient().Disconnect(c)
+}
+
+func connect(config config.Config, path ...string) (*mongo.Client, string, error) {
+	get := func(key string) []string {
+		return append(append([]string{}, path...), key)
+	}
+
+	database := config.Get(get("database")...).String("")
+	if len(database) == 0 {
+		return nil, "", errors.New("database must be set")
+	}
+
+	opts := options.Client()
+
+	// 优先使用连接串，支持 mongodb+srv 和各种认证方式
+	uri := config.Get(get("uri")...).String("")
+	if len(uri) > 0 {
+		opts.ApplyURI(uri)
+	} else {
+		addrs := config.Get(get("addrs")...).StringSlice(nil)
+		if len(addrs) == 0 {
+			return nil, "", errors.New("addrs must be set")
+		}
+		opts.SetHosts(addrs)
+	}
+
+	poolOptions, err := PoolOptions(config, path...)
+	if err != nil {
+		return nil, "", err
+	}
+	opts.SetMaxPoolSize(uint64(poolOptions.MaxOpen))
+	opts.SetMinPoolSize(uint64(poolOptions.MinIdle))
+	opts.SetMaxConnIdleTime(poolOptions.IdleTimeout)
+	opts.SetConnectTimeout(poolOptions.ConnectTimeout)
+	opts.SetServerSelectionTimeout(poolOptions.WaitTimeout)
+	opts.SetSocketTimeout(poolOptions.ReadTimeout)
+	if poolOptions.TLS != nil {
+		opts.SetTLSConfig(poolOptions.TLS)
+	}
+	timeout := poolOptions.ConnectTimeout
+
+	replicaSetName := config.Get(get("replica_set_name")...).String("")
+	if len(replicaSetName) > 0 {
+		opts.SetReplicaSet(replicaSetName)
+	}
+
+	username := config.Get(get("username")...).String("")
+	if len(username) > 0 {
+		opts.SetAuth(options.Credential{
+			AuthMechanism: config.Get(get("auth_mechanism")...).String(""),
+			AuthSource:    config.Get(get("source")...).String(""),
+			Username:      username,
+			Password:      config.Get(get("password")...).String(""),
+		})
+	}
+
+	// 与 database/mongo 一致，未配置时读主节点
+	mode := config.Get(get("mode")...).Int(0)
+	if mode <= 0 {
+		mode = int(mgo.Primary)
+	}
+	opts.SetReadPreference(ReadPreference(mgo.Mode(mode)))
+
+	c, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client, err := mongo.Connect(c, opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err = client.Ping(c, readpref.Primary()); err != nil {
+		client.Disconnect(context.Background())
+		return nil, "", err
+	}
+
+	return client, database, nil
+}
+
+// ReadPreference mgo 的读模式对应的读偏好，配置和按查询设置的读模式都按此转换
+// Eventual 和 Monotonic 没有对应的模式，Eventual 按 Nearest，Monotonic 先读从节点按 SecondaryPreferred 处理
+func ReadPreference(mode mgo.Mode) *readpref.ReadPref {
+	switch mode {
+	case mgo.Eventual, mgo.Nearest:
+		return readpref.Nearest()
+	case mgo.Monotonic, mgo.SecondaryPreferred:
+		return readpref.SecondaryPreferred()
+	case mgo.PrimaryPreferred:
+		return readpref.PrimaryPreferred()
+	case mgo.Secondary:
+		return readpref.Secondary()
+	default: // Primary, Strong
+		return readpref.Primary()
+	}
+}
+
+// PoolOptions 读取 <path>.pool 和 <path>.tls 配置，兼容原有的 pool_limit 和 timeout
+// max_open 和 min_idle 对应连接池大小，read_timeout 对应 socket 超时，wait_timeout 对应选择节点的超时
+func PoolOptions(config config.Config, path ...string) (pool.Options, error) {
+	get := func(key string) []string {
+		return append(append([]string{}, path...), key)
+	}
+
+	timeout := config.Get(get("timeout")...).Duration(5 * time.Second)
+	defaults := pool.Options{
+		MaxOpen:        config.Get(get("pool_limit")...).Int(20),
+		ConnectTimeout: timeout,
+		ReadTimeout:    10 * time.Second,
+		WaitTimeout:    timeout,
+	}
+	return pool.Config(config, defaults, path...)
+}
+
+func watchConfigChange(config config.Config, db *DB, name string) (stop func()) {
+	path := datasource.Path("mongo", name)
+
+	return reload.Watch(config, "mongodriver "+name, func() error {
+		client, database, err := connect(config, path...)
+		if err != nil {
+			return err
+		}
+		db.Swap(database, client, config.Get(append(path, "drain_timeout")...).Duration(30*time.Second))
+		return nil
+	}, path...)
+}
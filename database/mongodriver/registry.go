@@ -0,0 +1,58 @@
+package mongodriver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/micro/go-micro/v2/config"
+	"github.com/xxxmicro/base/database/datasource"
+)
+
+// Registry 按名称获取配置的数据源，例如 mongo.sources.main 和 mongo.sources.archive
+type Registry struct {
+	names []string
+	dbs   map[string]*DB
+}
+
+// NewRegistryProvider 创建所有配置的数据源，任一失败时关闭已创建的连接并返回错误
+func NewRegistryProvider(config config.Config) (*Registry, error) {
+	names := datasource.Names(config, "mongo", "database")
+	if len(names) == 0 {
+		return nil, errors.New("no mongo source configured")
+	}
+
+	r := &Registry{names: names, dbs: make(map[string]*DB, len(names))}
+	for _, name := range names {
+		db, err := NewMongoDriver(config, name)
+		if err != nil {
+			r.Close(context.Background())
+			return nil, fmt.Errorf("mongo source %s: %w", name, err)
+		}
+		r.dbs[name] = db
+	}
+	return r, nil
+}
+
+// Get 名称未配置时返回 datasource.ErrNotFound
+func (r *Registry) Get(name string) (*DB, error) {
+	db, ok := r.dbs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w mongo %s", datasource.ErrNotFound, name)
+	}
+	return db, nil
+}
+
+func (r *Registry) Names() []string {
+	return r.names
+}
+
+func (r *Registry) Close(c context.Context) error {
+	var err error
+	for _, db := range r.dbs {
+		if e := db.Close(c); e != nil {
+			err = e
+		}
+	}
+	return err
+}
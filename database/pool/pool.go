@@ -0,0 +1,122 @@
+package pool
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/micro/go-micro/v2/config"
+)
+
+// Options 连接池配置，各 provider 只使用自己支持的项
+type Options struct {
+	MaxOpen        int           // 最大连接数，0 表示不限制
+	MaxIdle        int           // 最大空闲连接数
+	MinIdle        int           // 最少保持的连接数
+	MaxLifetime    time.Duration // 连接最长使用时间，0 表示不限制
+	IdleTimeout    time.Duration // 空闲连接关闭时间，0 表示不关闭
+	ConnectTimeout time.Duration // 建立连接超时
+	ReadTimeout    time.Duration // 读超时
+	WriteTimeout   time.Duration // 写超时
+	Wait           bool          // 连接数达到 MaxOpen 时等待，否则直接返回错误
+	WaitTimeout    time.Duration // 等待可用连接的时间
+	TLS            *tls.Config   // 为 nil 时不使用 TLS
+}
+
+// Validate 检查配置取值，provider 启动时调用
+func (o Options) Validate() error {
+	if o.MaxOpen < 0 || o.MaxIdle < 0 || o.MinIdle < 0 {
+		return errors.New("ERR_POOL_INVALID pool sizes must not be negative")
+	}
+	if o.MaxOpen > 0 && o.MaxIdle > o.MaxOpen {
+		return fmt.Errorf("ERR_POOL_INVALID max_idle %d exceeds max_open %d", o.MaxIdle, o.MaxOpen)
+	}
+	if o.MaxOpen > 0 && o.MinIdle > o.MaxOpen {
+		return fmt.Errorf("ERR_POOL_INVALID min_idle %d exceeds max_open %d", o.MinIdle, o.MaxOpen)
+	}
+	if o.Wait && o.MaxOpen == 0 {
+		return errors.New("ERR_POOL_INVALID wait requires max_open")
+	}
+	for name, d := range map[string]time.Duration{
+		"max_lifetime":    o.MaxLifetime,
+		"idle_timeout":    o.IdleTimeout,
+		"connect_timeout": o.ConnectTimeout,
+		"read_timeout":    o.ReadTimeout,
+		"write_timeout":   o.WriteTimeout,
+		"wait_timeout":    o.WaitTimeout,
+	} {
+		if d < 0 {
+			return fmt.Errorf("ERR_POOL_INVALID %s must not be negative", name)
+		}
+	}
+	return nil
+}
+
+// Config 读取 <path>.pool 和 <path>.tls 下的配置并校验，未配置的项使用 defaults
+// 例如 redis.pool.max_open、redis.tls.ca_file
+func Config(config config.Config, defaults Options, path ...string) (Options, error) {
+	get := func(section string, key string) []string {
+		return append(append(append([]string{}, path...), section), key)
+	}
+
+	o := defaults
+	o.MaxOpen = config.Get(get("pool", "max_open")...).Int(o.MaxOpen)
+	o.MaxIdle = config.Get(get("pool", "max_idle")...).Int(o.MaxIdle)
+	o.MinIdle = config.Get(get("pool", "min_idle")...).Int(o.MinIdle)
+	o.MaxLifetime = config.Get(get("pool", "max_lifetime")...).Duration(o.MaxLifetime)
+	o.IdleTimeout = config.Get(get("pool", "idle_timeout")...).Duration(o.IdleTimeout)
+	o.ConnectTimeout = config.Get(get("pool", "connect_timeout")...).Duration(o.ConnectTimeout)
+	o.ReadTimeout = config.Get(get("pool", "read_timeout")...).Duration(o.ReadTimeout)
+	o.WriteTimeout = config.Get(get("pool", "write_timeout")...).Duration(o.WriteTimeout)
+	o.Wait = config.Get(get("pool", "wait")...).Bool(o.Wait)
+	o.WaitTimeout = config.Get(get("pool", "wait_timeout")...).Duration(o.WaitTimeout)
+
+	if config.Get(get("tls", "enabled")...).Bool(false) {
+		tlsConfig, err := TLSConfig(
+			config.Get(get("tls", "ca_file")...).String(""),
+			config.Get(get("tls", "cert_file")...).String(""),
+			config.Get(get("tls", "key_file")...).String(""),
+		)
+		if err != nil {
+			return o, err
+		}
+		tlsConfig.ServerName = config.Get(get("tls", "server_name")...).String("")
+		tlsConfig.InsecureSkipVerify = config.Get(get("tls", "insecure_skip_verify")...).Bool(false)
+		o.TLS = tlsConfig
+	}
+
+	return o, o.Validate()
+}
+
+// TLSConfig caFile 为空时使用系统根证书，certFile 和 keyFile 用于双向认证
+func TLSConfig(caFile string, certFile string, keyFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if len(caFile) > 0 {
+		data, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New("ERR_POOL_INVALID no certificate in " + caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(certFile) > 0 || len(keyFile) > 0 {
+		if len(certFile) == 0 || len(keyFile) == 0 {
+			return nil, errors.New("ERR_POOL_INVALID cert_file and key_file must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,64 @@
+package pool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v2/config"
+	"github.com/micro/go-micro/v2/config/source/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func loadConfig(t *testing.T, data string) config.Config {
+	conf, err := config.NewConfig()
+	assert.NoError(t, err)
+	assert.NoError(t, conf.Load(memory.NewSource(memory.WithJSON([]byte(data)))))
+	return conf
+}
+
+func TestConfig(t *testing.T) {
+	conf := loadConfig(t, `{
+		"redis": {
+			"pool": {
+				"max_open": 50,
+				"read_timeout": "2s",
+				"wait": true
+			}
+		}
+	}`)
+
+	o, err := Config(conf, Options{MaxIdle: 10, ConnectTimeout: 5 * time.Second}, "redis")
+	assert.NoError(t, err)
+	assert.Equal(t, 50, o.MaxOpen)
+	assert.Equal(t, 10, o.MaxIdle)
+	assert.Equal(t, 2*time.Second, o.ReadTimeout)
+	assert.Equal(t, 5*time.Second, o.ConnectTimeout)
+	assert.True(t, o.Wait)
+	assert.Nil(t, o.TLS)
+}
+
+func TestConfigInvalid(t *testing.T) {
+	conf := loadConfig(t, `{"redis": {"pool": {"max_open": 5, "max_idle": 10}}}`)
+	_, err := Config(conf, Options{}, "redis")
+	assert.Error(t, err)
+
+	conf = loadConfig(t, `{"redis": {"tls": {"enabled": true, "ca_file": "/not/exist.pem"}}}`)
+	_, err = Config(conf, Options{}, "redis")
+	assert.Error(t, err)
+
+	conf = loadConfig(t, `{"redis": {"tls": {"enabled": true, "server_name": "redis.local"}}}`)
+	o, err := Config(conf, Options{}, "redis")
+	assert.NoError(t, err)
+	assert.Equal(t, "redis.local", o.TLS.ServerName)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Options{MaxOpen: 10, MaxIdle: 10, Wait: true}.Validate())
+	assert.Error(t, Options{Wait: true}.Validate())
+	assert.Error(t, Options{MaxIdle: -1}.Validate())
+	assert.Error(t, Options{ReadTimeout: -time.Second}.Validate())
+	assert.Error(t, Options{MaxOpen: 5, MinIdle: 6}.Validate())
+
+	_, err := TLSConfig("", "cert.pem", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,111 @@
+package redis
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	xxxmicro_opentracing "github.com/xxxmicro/base/opentracing"
+)
+
+// 缓存和存储共用的 redis 指标，component 区分 cache 和 store
+var (
+	commandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "redis",
+		Name:      "command_duration_seconds",
+		Help:      "Redis command latency in seconds.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"component", "command"})
+
+	commandErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "redis",
+		Name:      "command_errors_total",
+		Help:      "Redis commands that returned an error.",
+	}, []string{"component", "command"})
+
+	cacheHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "redis",
+		Name:      "hits_total",
+		Help:      "GET commands that found the key.",
+	}, []string{"component"})
+
+	cacheMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "redis",
+		Name:      "misses_total",
+		Help:      "GET commands that did not find the key.",
+	}, []string{"component"})
+
+	pools = &poolCollector{
+		pools:  make(map[string]*redis.Pool),
+		active: prometheus.NewDesc("redis_pool_active_connections", "Connections in the pool, idle and in use.", []string{"pool"}, nil),
+		idle:   prometheus.NewDesc("redis_pool_idle_connections", "Idle connections in the pool.", []string{"pool"}, nil),
+	}
+)
+
+func init() {
+	prometheus.MustRegister(commandDuration, commandErrors, cacheHits, cacheMisses, pools)
+}
+
+// Do 执行一条命令，以 context 中的 span 为父 span 记录命令名、key 前缀、命中情况和错误，并统计耗时
+// 参数值不进入 trace，GET 返回空时记为未命中
+func Do(c context.Context, conn redis.Conn, component string, prefix string, command string, args ...interface{}) (interface{}, error) {
+	sp := xxxmicro_opentracing.StartDBSpan(c, "redis", command, prefix)
+	sp.SetTag("redis.component", component)
+
+	start := time.Now()
+	reply, err := conn.Do(command, args...)
+	commandDuration.WithLabelValues(component, command).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		commandErrors.WithLabelValues(component, command).Inc()
+	} else if command == "GET" {
+		hit := reply != nil
+		if hit {
+			cacheHits.WithLabelValues(component).Inc()
+		} else {
+			cacheMisses.WithLabelValues(component).Inc()
+		}
+		sp.SetTag("cache.hit", hit)
+	}
+
+	return reply, sp.Finish(err)
+}
+
+// RegisterPool 采集连接池的连接数，同名的连接池会被替换
+func RegisterPool(name string, pool *redis.Pool) {
+	pools.l.Lock()
+	defer pools.l.Unlock()
+	pools.pools[name] = pool
+}
+
+// UnregisterPool 连接池关闭后不再采集
+func UnregisterPool(name string) {
+	pools.l.Lock()
+	defer pools.l.Unlock()
+	delete(pools.pools, name)
+}
+
+type poolCollector struct {
+	l      sync.RWMutex
+	pools  map[string]*redis.Pool
+	active *prometheus.Desc
+	idle   *prometheus.Desc
+}
+
+func (p *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.active
+	ch <- p.idle
+}
+
+func (p *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	p.l.RLock()
+	defer p.l.RUnlock()
+
+	for name, pool := range p.pools {
+		stats := pool.Stats()
+		ch <- prometheus.MustNewConstMetric(p.active, prometheus.GaugeValue, float64(stats.ActiveCount), name)
+		ch <- prometheus.MustNewConstMetric(p.idle, prometheus.GaugeValue, float64(stats.IdleCount), name)
+	}
+}
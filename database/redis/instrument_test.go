@@ -0,0 +1,47 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeConn struct {
+	replies map[string]interface{}
+}
+
+func (c *fakeConn) Close() error { return nil }
+func (c *fakeConn) Err() error   { return nil }
+
+func (c *fakeConn) Do(command string, args ...interface{}) (interface{}, error) {
+	if command == "BOOM" {
+		return nil, errors.New("boom")
+	}
+	return c.replies[args[0].(string)], nil
+}
+
+func (c *fakeConn) Send(command string, args ...interface{}) error { return nil }
+func (c *fakeConn) Flush() error                                   { return nil }
+func (c *fakeConn) Receive() (interface{}, error)                  { return nil, nil }
+
+func TestDo(t *testing.T) {
+	conn := &fakeConn{replies: map[string]interface{}{"app:hello": []byte("world")}}
+
+	reply, err := Do(context.Background(), conn, "test", "app", "GET", "app:hello")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("world"), reply)
+
+	reply, err = Do(context.Background(), conn, "test", "app", "GET", "app:missing")
+	assert.NoError(t, err)
+	assert.Nil(t, reply)
+
+	_, err = Do(context.Background(), conn, "test", "app", "BOOM", "app:hello")
+	assert.EqualError(t, err, "boom")
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(cacheHits.WithLabelValues("test")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(cacheMisses.WithLabelValues("test")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(commandErrors.WithLabelValues("test", "BOOM")))
+}
@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/xxxmicro/base/database/pool"
+)
+
+// DefaultPoolOptions 缓存和存储连接池的默认配置
+// 默认不等待：redigo 的 Get 不接受 context，Wait 为 true 时连接池耗尽会无限期阻塞调用方
+func DefaultPoolOptions() pool.Options {
+	return pool.Options{
+		MaxOpen:        100,
+		MaxIdle:        10,
+		IdleTimeout:    240 * time.Second,
+		ConnectTimeout: 5 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		WriteTimeout:   3 * time.Second,
+	}
+}
+
+// NewPool 按连接池配置创建 redis 连接池，Wait 为 false 时连接数达到 MaxOpen 返回 redis.ErrPoolExhausted
+// 当前 redigo 版本不支持等待超时，WaitTimeout 不生效，Wait 为 true 时一直等待到有连接归还
+func NewPool(addr string, password string, o pool.Options) *redis.Pool {
+	dialOptions := []redis.DialOption{
+		redis.DialConnectTimeout(o.ConnectTimeout),
+		redis.DialReadTimeout(o.ReadTimeout),
+		redis.DialWriteTimeout(o.WriteTimeout),
+	}
+	if len(password) > 0 {
+		dialOptions = append(dialOptions, redis.DialPassword(password))
+	}
+	if o.TLS != nil {
+		dialOptions = append(dialOptions,
+			redis.DialUseTLS(true),
+			redis.DialTLSConfig(o.TLS),
+			redis.DialTLSSkipVerify(o.TLS.InsecureSkipVerify),
+		)
+	}
+
+	return &redis.Pool{
+		MaxIdle:         o.MaxIdle,
+		MaxActive:       o.MaxOpen,
+		IdleTimeout:     o.IdleTimeout,
+		MaxConnLifetime: o.MaxLifetime,
+		Wait:            o.Wait,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr, dialOptions...)
+		},
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package reload
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v2/config"
+	"github.com/micro/go-micro/v2/logger"
+)
+
+// Watch 在后台监听 path 下的配置，内容变化时调用 fn 重建连接，fn 返回错误时保留旧连接
+// apollo 等配置源推送任意配置变化都会触发 watcher，这里只在本段配置变化时重建
+// 返回的 stop 停止监听，关闭连接时调用，可重复调用
+func Watch(config config.Config, name string, fn func() error, path ...string) (stop func()) {
+	w, err := config.Watch(path...)
+	if err != nil {
+		logger.Errorf("%s config watch failed: %v", name, err)
+		return func() {}
+	}
+
+	var once sync.Once
+	stopped := make(chan struct{})
+	stop = func() {
+		once.Do(func() {
+			close(stopped)
+			w.Stop()
+		})
+	}
+
+	last := config.Get(path...).Bytes()
+	go func() {
+		defer stop()
+		for {
+			v, err := w.Next()
+			select {
+			case <-stopped:
+				return
+			default:
+			}
+			if err != nil {
+				logger.Errorf("%s config watch stopped: %v", name, err)
+				return
+			}
+
+			current := config.Get(path...).Bytes()
+			if v == nil || bytes.Equal(last, current) {
+				continue
+			}
+
+			start := time.Now()
+			if err := fn(); err != nil {
+				logger.Errorf("%s reload failed, keep the old connection: %v", name, err)
+				continue
+			}
+			last = current
+			logger.Infof("%s reloaded in %s", name, time.Since(start))
+		}
+	}()
+	return stop
+}
+
+// Drain 等待旧连接上的操作结束，超时返回 false
+func Drain(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,77 @@
+package reload
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v2/config"
+	"github.com/micro/go-micro/v2/config/source"
+	"github.com/micro/go-micro/v2/config/source/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatch(t *testing.T) {
+	conf, err := config.NewConfig()
+	assert.NoError(t, err)
+
+	src := memory.NewSource(memory.WithJSON([]byte(`{"db": {"dsn": "a"}, "other": 1}`)))
+	assert.NoError(t, conf.Load(src))
+
+	reloaded := make(chan string, 10)
+	stop := Watch(conf, "test", func() error {
+		reloaded <- conf.Get("db", "dsn").String("")
+		return nil
+	}, "db")
+	defer stop()
+	time.Sleep(100 * time.Millisecond)
+
+	// 其它配置变化不触发重建
+	assert.NoError(t, src.Write(&source.ChangeSet{Data: []byte(`{"db": {"dsn": "a"}, "other": 2}`), Format: "json"}))
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, src.Write(&source.ChangeSet{Data: []byte(`{"db": {"dsn": "b"}, "other": 2}`), Format: "json"}))
+
+	select {
+	case dsn := <-reloaded:
+		assert.Equal(t, "b", dsn)
+	case <-time.After(3 * time.Second):
+		t.Fatal("reload not triggered")
+	}
+	assert.Len(t, reloaded, 0)
+}
+
+func TestWatchStop(t *testing.T) {
+	conf, err := config.NewConfig()
+	assert.NoError(t, err)
+
+	src := memory.NewSource(memory.WithJSON([]byte(`{"db": {"dsn": "a"}}`)))
+	assert.NoError(t, conf.Load(src))
+
+	reloaded := make(chan string, 10)
+	stop := Watch(conf, "test", func() error {
+		reloaded <- conf.Get("db", "dsn").String("")
+		return nil
+	}, "db")
+	stop()
+	stop()
+
+	// 停止后配置变化不再重建
+	assert.NoError(t, src.Write(&source.ChangeSet{Data: []byte(`{"db": {"dsn": "b"}}`), Format: "json"}))
+	select {
+	case dsn := <-reloaded:
+		t.Fatalf("reloaded after stop: %s", dsn)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestDrain(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	assert.False(t, Drain(&wg, 10*time.Millisecond))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		wg.Done()
+	}()
+	assert.True(t, Drain(&wg, time.Second))
+}
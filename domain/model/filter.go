@@ -14,6 +14,7 @@ const (
 	FilterType_LIKE     FilterType = "LIKE"     //like
 	FilterType_NOT_LIKE FilterType = "NOT_LIKE" //not like
 	FilterType_MATCH    FilterType = "MATCH"    //匹配
+	FilterType_TEXT     FilterType = "TEXT"     //全文检索，mongo 需建文本索引
 	FilterType_BETWEEN  FilterType = "BETWEEN"  //匹配
 	FilterType_IS_NULL  FilterType = "IS_NULL"  //为空
 	FilterType_NOT_NULL FilterType = "NOT_NULL" //不为空
@@ -21,6 +22,15 @@ const (
 	FilterType_OR       FilterType = "OR"       //OR
 	FilterType_NOR      FilterType = "NOR"      //NOR
 
+	FilterType_ANY        FilterType = "ANY"        // 数组包含任意一个值
+	FilterType_ALL        FilterType = "ALL"        // 数组包含全部值
+	FilterType_SIZE       FilterType = "SIZE"       // 数组长度
+	FilterType_ELEM_MATCH FilterType = "ELEM_MATCH" // 对象数组中存在满足全部子条件的元素，值为 {子字段: 条件}
+
+	FilterType_GEO_NEAR           FilterType = "GEO_NEAR"           // 距离范围内，值为 GeoNear
+	FilterType_GEO_WITHIN_BOX     FilterType = "GEO_WITHIN_BOX"     // 矩形范围内，值为 GeoBox
+	FilterType_GEO_WITHIN_POLYGON FilterType = "GEO_WITHIN_POLYGON" // 多边形范围内，值为顶点数组 [[lng, lat], ...]
+
 	FilterType_ES_EQ            FilterType = "EQ"            // 等于
 	FilterType_ES_NE            FilterType = "NE"            //不相等
 	FilterType_ES_OR            FilterType = "OR"            //
@@ -57,8 +67,12 @@ const (
 	SortType_DSC     SortType = "DSC" // 降序
 )
 
+// SortProperty_SCORE 按全文检索的相关度排序
+const SortProperty_SCORE = "_score"
+
 type SortSpec struct {
-	Property   string   `json:"property"`   // 属性名
-	Type       SortType `json:"type"`       // 排序类型
-	IgnoreCase bool     `json:"ignoreCase"` // 忽略大小写
+	Property   string    `json:"property"`       // 属性名
+	Type       SortType  `json:"type"`           // 排序类型
+	IgnoreCase bool      `json:"ignoreCase"`     // 忽略大小写
+	Near       *GeoPoint `json:"near,omitempty"` // 不为空时按与该点的距离排序，属性需为地理坐标字段
 }
@@ -0,0 +1,146 @@
+package model
+
+import (
+	"errors"
+)
+
+// GeoPoint 地理坐标，经度在前
+// 请求参数中可以写成 [lng, lat] 或 {"lng": 116.4, "lat": 39.9}
+type GeoPoint struct {
+	Lng float64 `json:"lng"` // 经度
+	Lat float64 `json:"lat"` // 纬度
+}
+
+// GeoNear 距离条件，例如 {"point": [116.4, 39.9], "maxDistance": 5000}
+type GeoNear struct {
+	Point       GeoPoint `json:"point"`
+	MaxDistance float64  `json:"maxDistance"` // 最大距离(米)，0 表示不限制
+	MinDistance float64  `json:"minDistance"` // 最小距离(米)
+}
+
+// GeoBox 矩形范围，例如 {"topLeft": [116.3, 40.0], "bottomRight": [116.5, 39.8]}
+type GeoBox struct {
+	TopLeft     GeoPoint `json:"topLeft"`
+	BottomRight GeoPoint `json:"bottomRight"`
+}
+
+var ErrMalformedGeo = errors.New("ERR_MALFORMED_GEO_PARAMETERS")
+
+func ParseGeoPoint(value interface{}) (GeoPoint, error) {
+	switch v := value.(type) {
+	case GeoPoint:
+		return v, nil
+	case *GeoPoint:
+		if v != nil {
+			return *v, nil
+		}
+	case []float64:
+		if len(v) == 2 {
+			return GeoPoint{Lng: v[0], Lat: v[1]}, nil
+		}
+	case []interface{}:
+		if len(v) == 2 {
+			lng, ok1 := toFloat(v[0])
+			lat, ok2 := toFloat(v[1])
+			if ok1 && ok2 {
+				return GeoPoint{Lng: lng, Lat: lat}, nil
+			}
+		}
+	case map[string]interface{}:
+		lng, ok1 := toFloat(v["lng"])
+		lat, ok2 := toFloat(v["lat"])
+		if ok1 && ok2 {
+			return GeoPoint{Lng: lng, Lat: lat}, nil
+		}
+	}
+	return GeoPoint{}, ErrMalformedGeo
+}
+
+func ParseGeoNear(value interface{}) (*GeoNear, error) {
+	switch v := value.(type) {
+	case GeoNear:
+		return &v, nil
+	case *GeoNear:
+		if v != nil {
+			return v, nil
+		}
+	case map[string]interface{}:
+		point, err := ParseGeoPoint(v["point"])
+		if err != nil {
+			return nil, err
+		}
+		near := &GeoNear{Point: point}
+		if max, ok := v["maxDistance"]; ok {
+			if near.MaxDistance, ok = toFloat(max); !ok {
+				return nil, ErrMalformedGeo
+			}
+		}
+		if min, ok := v["minDistance"]; ok {
+			if near.MinDistance, ok = toFloat(min); !ok {
+				return nil, ErrMalformedGeo
+			}
+		}
+		return near, nil
+	}
+	return nil, ErrMalformedGeo
+}
+
+func ParseGeoBox(value interface{}) (*GeoBox, error) {
+	switch v := value.(type) {
+	case GeoBox:
+		return &v, nil
+	case *GeoBox:
+		if v != nil {
+			return v, nil
+		}
+	case map[string]interface{}:
+		topLeft, err := ParseGeoPoint(v["topLeft"])
+		if err != nil {
+			return nil, err
+		}
+		bottomRight, err := ParseGeoPoint(v["bottomRight"])
+		if err != nil {
+			return nil, err
+		}
+		return &GeoBox{TopLeft: topLeft, BottomRight: bottomRight}, nil
+	}
+	return nil, ErrMalformedGeo
+}
+
+// ParseGeoPolygon 解析多边形顶点，至少 3 个点，首尾不需要重复
+func ParseGeoPolygon(value interface{}) ([]GeoPoint, error) {
+	var points []GeoPoint
+	switch v := value.(type) {
+	case []GeoPoint:
+		points = v
+	case []interface{}:
+		for _, item := range v {
+			point, err := ParseGeoPoint(item)
+			if err != nil {
+				return nil, err
+			}
+			points = append(points, point)
+		}
+	}
+
+	if len(points) < 3 {
+		return nil, ErrMalformedGeo
+	}
+	return points, nil
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,79 @@
+package elastic
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/xxxmicro/base/domain/model"
+)
+
+// buildArrayQuery 数组条件，字符串数组需使用 keyword 类型
+// ELEM_MATCH 要求字段为 nested 类型，否则对象数组会被展平，无法保证子条件落在同一元素上
+// ALL 的值不是数组、ELEM_MATCH 的值不是对象时返回 ERR_MALFORMED_PARAMETERS，避免生成匹配全部文档的空条件；
+// ALL 空数组与 mongo 的 $all: [] 一致，不匹配任何文档
+// SIZE 通过 doc values 计数，keyword 字段的 doc values 会去重，数组中有重复值时按去重后的个数比较
+func buildArrayQuery(column string, filterType model.FilterType, value interface{}) (interface{}, error) {
+	switch filterType {
+	case model.FilterType_ANY:
+		return map[string]interface{}{
+			"terms": map[string]interface{}{
+				column: value,
+			},
+		}, nil
+	case model.FilterType_ALL:
+		rv := reflect.ValueOf(value)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return nil, errors.New("ERR_MALFORMED_PARAMETERS")
+		}
+		if rv.Len() == 0 {
+			return map[string]interface{}{
+				"match_none": map[string]interface{}{},
+			}, nil
+		}
+		terms := make([]interface{}, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			terms = append(terms, map[string]interface{}{
+				"term": map[string]interface{}{
+					column: rv.Index(i).Interface(),
+				},
+			})
+		}
+		return map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": terms,
+			},
+		}, nil
+	case model.FilterType_SIZE:
+		return map[string]interface{}{
+			"script": map[string]interface{}{
+				"script": map[string]interface{}{
+					"source": "doc[params.field].size() == params.size",
+					"params": map[string]interface{}{
+						"field": column,
+						"size":  value,
+					},
+				},
+			},
+		}, nil
+	case model.FilterType_ELEM_MATCH:
+		subFilters, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("ERR_MALFORMED_PARAMETERS")
+		}
+		nestedFilters := make(map[string]interface{}, len(subFilters))
+		for k, v := range subFilters {
+			nestedFilters[column+"."+k] = v
+		}
+		query, err := buildQuery(nestedFilters)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"nested": map[string]interface{}{
+				"path":  column,
+				"query": query,
+			},
+		}, nil
+	}
+	return nil, errors.New("ERR_MALFORMED_FILTER_TYPE")
+}
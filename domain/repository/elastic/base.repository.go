@@ -6,21 +6,76 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	elasticsearch6 "github.com/elastic/go-elasticsearch/v6"
 	"github.com/elastic/go-elasticsearch/v6/esapi"
+	"github.com/micro/go-micro/v2/logger"
+	"github.com/xxxmicro/base/database/elastic"
 	"github.com/xxxmicro/base/domain/model"
 	"github.com/xxxmicro/base/domain/repository"
 	breflect2 "github.com/xxxmicro/base/domain/repository/elastic/reflect"
+	xxxmicro_opentracing "github.com/xxxmicro/base/opentracing"
 	breflect "github.com/xxxmicro/base/reflect"
 	"gopkg.in/mgo.v2/bson"
 )
 
 type BaseRepository struct {
-	DB *elasticsearch6.Client
+	DB      *elastic.Client
+	options Options
+	gates   writeGates
 }
 
-func NewBaseRepository(db *elasticsearch6.Client) repository.BaseRepository {
-	return &BaseRepository{db}
+func NewBaseRepository(db *elastic.Client, opts ...Option) *BaseRepository {
+	r := &BaseRepository{DB: db}
+	for _, o := range opts {
+		o(&r.options)
+	}
+	return r
+}
+
+// NewNamedRepository 使用 registry 中名为 name 的数据源
+func NewNamedRepository(registry *elastic.Registry, name string, opts ...Option) (*BaseRepository, error) {
+	db, err := registry.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewBaseRepository(db, opts...), nil
+}
+
+// tiebreaker 游标查询的第二排序字段
+func (r *BaseRepository) tiebreaker() string {
+	if len(r.options.Tiebreaker) > 0 {
+		return r.options.Tiebreaker
+	}
+	return "_id"
+}
+
+func (r *BaseRepository) logger() logger.Logger {
+	if r.options.Logger != nil {
+		return r.options.Logger
+	}
+	return logger.DefaultLogger
+}
+
+// do 经过集群的熔断器，使用请求的 context 发送，context 结束导致的失败返回 repository.ContextError
+// 只有连接错误、超时和 5xx、429 响应计入熔断，见 IsFailure
+func (r *BaseRepository) do(c context.Context, req esapi.Request) (*esapi.Response, error) {
+	done, err := r.DB.Breaker().Allow()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := req.Do(c, r.DB)
+	if err != nil {
+		err = repository.WrapContextError(c, err)
+		done(IsFailure(err))
+		return nil, err
+	}
+	done(IsFailure(&Error{Status: res.StatusCode}))
+	return res, nil
+}
+
+// startSpan 记录一次索引操作，见 opentracing.StartDBSpan
+func startSpan(c context.Context, method string, index string) *xxxmicro_opentracing.DBSpan {
+	return xxxmicro_opentracing.StartDBSpan(c, "elasticsearch", method, index)
 }
 
 // todo 时间要加时区
@@ -35,34 +90,52 @@ func (r *BaseRepository) Create(c context.Context, m model.Model) error {
 		idRefValue.SetString(bson.NewObjectId().Hex())
 	}
 
+	sp := startSpan(c, "Create", index)
+	target, err := r.targetIndex(c, m, index, "", true)
+	if err != nil {
+		return sp.Finish(err)
+	}
+
+	return sp.Finish(r.guardWrite(index, idRefValue.String(), func() error {
+		return r.create(c, index, target, idRefValue.String(), m)
+	}))
+}
+
+// create index 为模型索引名，target 为实际写入的索引
+func (r *BaseRepository) create(c context.Context, index string, target string, id string, m model.Model) error {
 	jsonBody, err := json.Marshal(m)
 	if err != nil {
 		return err
 	}
 
-	req := esapi.CreateRequest{
-		Index:        index,
-		DocumentType: index,
-		DocumentID:   idRefValue.String(),
-		Body:         bytes.NewReader(jsonBody),
-	}
-	res, err := req.Do(c, r.DB)
+	req := r.createRequest(target, r.DB.DocumentType(index), id, bytes.NewReader(jsonBody))
+	res, err := r.do(c, req)
 	if err != nil {
 		return err
 	}
 	defer res.Body.Close()
-	fmt.Println(res.String())
+
+	if err = decodeError(res); err != nil {
+		return err
+	}
+	r.logger().Logf(logger.DebugLevel, "elastic create %s/%s: %s", target, id, res.Status())
 	return nil
 }
 
 func (r *BaseRepository) Exists(c context.Context, index string, documentID string) (bool, error) {
+	sp := startSpan(c, "Exists", index)
+	exist, err := r.exists(c, index, r.DB.DocumentType(index), documentID)
+	return exist, sp.Finish(err)
+}
+
+func (r *BaseRepository) exists(c context.Context, index string, docType string, documentID string) (bool, error) {
 	req := esapi.ExistsRequest{
 		Index:        index,
-		DocumentType: index,
+		DocumentType: docType,
 		DocumentID:   documentID,
 	}
 
-	res, err := req.Do(c, r.DB)
+	res, err := r.do(c, req)
 	if err != nil {
 		return false, err
 	}
@@ -71,64 +144,77 @@ func (r *BaseRepository) Exists(c context.Context, index string, documentID stri
 	if res.StatusCode == 200 {
 		return true, nil
 	}
-	return false, nil
+	if res.StatusCode == 404 {
+		return false, nil
+	}
+	return false, decodeError(res)
 }
 
-func (r *BaseRepository) Upsert(c context.Context, m model.Model) (*repository.ChangeInfo, error) {
+func (r *BaseRepository) Upsert(c context.Context, m model.Model) (change *repository.ChangeInfo, err error) {
 	index, idRefValue, err := getModelInfo(m)
 	if err != nil {
 		return nil, err
 	}
 
+	sp := startSpan(c, "Upsert", index)
+	defer func() { sp.Finish(err) }()
+
 	if idRefValue.String() == "" {
 		idRefValue.SetString(bson.NewObjectId().Hex())
-		err = r.Create(c, m)
+		var target string
+		target, err = r.targetIndex(c, m, index, "", true)
 		if err != nil {
 			return nil, err
 		}
-		change := &repository.ChangeInfo{
+		err = r.guardWrite(index, idRefValue.String(), func() error {
+			return r.create(c, index, target, idRefValue.String(), m)
+		})
+		if err != nil {
+			return nil, err
+		}
+		change = &repository.ChangeInfo{
 			UpsertedId: idRefValue.String(),
 		}
 		return change, nil
 	}
 
-	exist, err := r.Exists(c, index, idRefValue.String())
+	target, err := r.targetIndex(c, m, index, idRefValue.String(), true)
 	if err != nil {
 		return nil, err
 	}
 
-	if !exist {
-		err = r.Create(c, m)
+	err = r.guardWrite(index, idRefValue.String(), func() error {
+		exist, err := r.exists(c, target, r.DB.DocumentType(index), idRefValue.String())
 		if err != nil {
-			return nil, err
+			return err
 		}
-		change := &repository.ChangeInfo{
-			UpsertedId: idRefValue.String(),
+
+		if !exist {
+			return r.create(c, index, target, idRefValue.String(), m)
 		}
-		return change, nil
-	}
 
-	reqBody := map[string]interface{}{
-		"doc": m,
-	}
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, err
-	}
+		reqBody := map[string]interface{}{
+			"doc": m,
+		}
+		jsonBody, err := json.Marshal(reqBody)
+		if err != nil {
+			return err
+		}
 
-	req := esapi.UpdateRequest{
-		Index:        index,
-		DocumentType: index,
-		DocumentID:   idRefValue.String(),
-		Body:         bytes.NewReader(jsonBody),
-	}
-	res, err := req.Do(c, r.DB)
+		req := r.updateRequest(target, r.DB.DocumentType(index), idRefValue.String(), bytes.NewReader(jsonBody))
+		res, err := r.do(c, req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+
+		return decodeError(res)
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
 
-	change := &repository.ChangeInfo{
+	change = &repository.ChangeInfo{
 		UpsertedId: idRefValue.String(),
 	}
 	return change, nil
@@ -148,54 +234,60 @@ func (r *BaseRepository) Update(c context.Context, m model.Model, data interface
 		return err
 	}
 
-	req := esapi.UpdateRequest{
-		Index:        index,
-		DocumentType: index,
-		DocumentID:   idRefValue.String(),
-		Body:         bytes.NewReader(jsonBody),
-	}
-	res, err := req.Do(c, r.DB)
+	sp := startSpan(c, "Update", index)
+	target, err := r.targetIndex(c, m, index, idRefValue.String(), false)
 	if err != nil {
-		return err
+		return sp.Finish(err)
 	}
-	res.Body.Close()
 
-	if res.StatusCode != 200 {
-		return errors.New(res.Status())
-	}
+	return sp.Finish(r.guardWrite(index, idRefValue.String(), func() error {
+		req := r.updateRequest(target, r.DB.DocumentType(index), idRefValue.String(), bytes.NewReader(jsonBody))
+		res, err := r.do(c, req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
 
-	return nil
+		return decodeError(res)
+	}))
 }
 
-func (r *BaseRepository) FindOne(c context.Context, m model.Model) error {
+func (r *BaseRepository) FindOne(c context.Context, m model.Model) (err error) {
 	index, idRefValue, err := getModelInfoAndCheckID(m)
 	if err != nil {
 		return err
 	}
 
+	sp := startSpan(c, "FindOne", index)
+	defer func() { sp.Finish(err) }()
+
+	target, err := r.targetIndex(c, m, index, idRefValue.String(), false)
+	if err != nil {
+		return err
+	}
+
 	req := esapi.GetRequest{
-		Index:        index,
-		DocumentType: index,
+		Index:        target,
+		DocumentType: r.DB.DocumentType(index),
 		DocumentID:   idRefValue.String(),
 		FilterPath:   []string{"_source"},
 	}
-	res, err := req.Do(c, r.DB)
+	res, err := r.do(c, req)
 	if err != nil {
 		return err
 	}
 	defer res.Body.Close()
 
-	fmt.Println(res.String())
+	if err = decodeError(res); err != nil {
+		return err
+	}
+
 	var respData map[string]interface{}
 	err = json.NewDecoder(res.Body).Decode(&respData)
 	if err != nil {
 		return err
 	}
 
-	if res.StatusCode != 200 {
-		return errors.New("not found")
-	}
-
 	return breflect.CastStruct(respData["_source"], m)
 }
 
@@ -205,27 +297,39 @@ func (r *BaseRepository) Delete(c context.Context, m model.Model) error {
 		return err
 	}
 
-	req := esapi.DeleteRequest{
-		Index:        index,
-		DocumentType: index,
-		DocumentID:   idRefValue.String(),
-	}
-	res, err := req.Do(c, r.DB)
+	sp := startSpan(c, "Delete", index)
+	target, err := r.targetIndex(c, m, index, idRefValue.String(), false)
 	if err != nil {
-		return err
+		return sp.Finish(err)
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode == 200 {
-		return nil
-	}
+	return sp.Finish(r.guardWrite(index, idRefValue.String(), func() error {
+		req := esapi.DeleteRequest{
+			Index:        target,
+			DocumentType: r.DB.DocumentType(index),
+			DocumentID:   idRefValue.String(),
+		}
+		res, err := r.do(c, req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
 
-	var respData map[string]interface{}
-	if err = json.NewDecoder(res.Body).Decode(&respData); err != nil {
+		return decodeError(res)
+	}))
+}
+
+// guardWrite 写操作经过索引的写闸门，重建索引期间记录被写入的文档ID
+func (r *BaseRepository) guardWrite(index string, id string, fn func() error) error {
+	gate := r.gates.get(index)
+	gate.l.RLock()
+	defer gate.l.RUnlock()
+
+	if err := fn(); err != nil {
 		return err
 	}
-
-	return errors.New(respData["result"].(string))
+	gate.record(id)
+	return nil
 }
 
 // Page 多个条件 and , 每个条件的话， 支持 gt gte lt lte eq  like ne in 这几个即可
@@ -235,15 +339,26 @@ func (r *BaseRepository) Page(c context.Context, m model.Model, query *model.Pag
 		return
 	}
 
-	queryMap := buildPageSearch(query)
+	queryMap, err := buildPageSearch(query)
+	if err != nil {
+		return
+	}
+
+	sp := startSpan(c, "Page", index)
+	sp.SetStatement(queryMap["query"], queryMap["sort"])
+	defer func() {
+		sp.SetCount(pageCount)
+		sp.Finish(err)
+	}()
+
 	jsonBody, err := json.Marshal(queryMap)
 	if err != nil {
 		return
 	}
 
 	req := esapi.SearchRequest{
-		Index:        []string{index},
-		DocumentType: []string{index},
+		Index:        []string{readIndex(m, index)},
+		DocumentType: r.DB.SearchTypes(index),
 		Body:         bytes.NewReader(jsonBody),
 		FilterPath:   []string{"hits.hits._source", "hits.total"},
 	}
@@ -252,7 +367,7 @@ func (r *BaseRepository) Page(c context.Context, m model.Model, query *model.Pag
 		return
 	}
 
-	total = respData.Hits.Total
+	total = int(respData.Hits.Total)
 
 	var sources []interface{}
 	for _, v := range respData.Hits.Hits {
@@ -278,18 +393,38 @@ func (r *BaseRepository) Cursor(c context.Context, query *model.CursorQuery, m m
 	}
 	index := TheNamingStrategy.Table(ms.Name)
 
-	// 构造查询语句
-	queryMap := buildCursorSearch(query)
+	size := query.Size
+	if size > 1000 {
+		size = 1000
+	} else if size <= 0 {
+		size = 20
+	}
+
+	// 构造查询语句，多取一个，用于判断是否有更多数据
+	queryMap, reverse, err := buildCursorSearch(query, size+1, r.tiebreaker())
+	if err != nil {
+		return
+	}
+
+	sp := startSpan(c, "Cursor", index)
+	sp.SetStatement(queryMap["query"], queryMap["sort"])
+	defer func() {
+		if extra != nil {
+			sp.SetCount(extra.Size)
+		}
+		sp.Finish(err)
+	}()
+
 	jsonBody, err := json.Marshal(queryMap)
 	if err != nil {
 		return
 	}
 
 	req := esapi.SearchRequest{
-		Index:        []string{index},
-		DocumentType: []string{index},
+		Index:        []string{readIndex(m, index)},
+		DocumentType: r.DB.SearchTypes(index),
 		Body:         bytes.NewReader(jsonBody),
-		FilterPath:   []string{"hits.hits._source", "hits.total"},
+		FilterPath:   []string{"hits.hits._id", "hits.hits._source", "hits.hits.sort", "hits.total"},
 	}
 
 	respData, err := r.getHitsResult(c, req)
@@ -297,17 +432,23 @@ func (r *BaseRepository) Cursor(c context.Context, query *model.CursorQuery, m m
 		return
 	}
 
-	var sources []interface{}
-	if query.Direction == 0 {
-		for i := len(respData.Hits.Hits) - 1; i > -1; i-- {
-			sources = append(sources, respData.Hits.Hits[i].Source)
-		}
-	} else {
-		for _, v := range respData.Hits.Hits {
-			sources = append(sources, v.Source)
+	hits := respData.Hits.Hits
+	hasMore := len(hits) > size
+	if hasMore {
+		hits = hits[:size]
+	}
+
+	if reverse {
+		for i, j := 0, len(hits)-1; i < j; i, j = i+1, j-1 {
+			hits[i], hits[j] = hits[j], hits[i]
 		}
 	}
 
+	sources := make([]interface{}, 0, len(hits))
+	for _, v := range hits {
+		sources = append(sources, v.Source)
+	}
+
 	err = breflect.MapSlice2StructSlice(sources, resultPtr)
 	if err != nil {
 		return
@@ -315,18 +456,14 @@ func (r *BaseRepository) Cursor(c context.Context, query *model.CursorQuery, m m
 
 	extra = &model.CursorExtra{
 		Direction: query.Direction,
-		Size:      len(respData.Hits.Hits),
-		HasMore:   respData.Hits.Total > query.Size,
-	}
-
-	minCursor, ok := sources[0].(map[string]interface{})
-	if ok {
-		extra.MinCursor = minCursor[query.CursorSort.Property]
+		Size:      len(hits),
+		HasMore:   hasMore,
 	}
 
-	maxCursor, ok := sources[len(sources)-1].(map[string]interface{})
-	if ok {
-		extra.MaxCursor = maxCursor[query.CursorSort.Property]
+	// 游标值为 [游标字段值, tiebreaker 值]，下次查询原样传回
+	if len(hits) > 0 {
+		extra.MinCursor = hits[0].Sort
+		extra.MaxCursor = hits[len(hits)-1].Sort
 	}
 
 	return
@@ -334,28 +471,26 @@ func (r *BaseRepository) Cursor(c context.Context, query *model.CursorQuery, m m
 
 type HitsResult struct {
 	Hits struct {
-		Total int `json:"total"`
+		Total hitsTotal `json:"total"`
 		Hits  []struct {
-			Source interface{} `json:"_source"`
+			ID     string        `json:"_id"`
+			Source interface{}   `json:"_source"`
+			Sort   []interface{} `json:"sort"`
 		} `json:"hits"`
 	} `json:"hits"`
 }
 
 func (r *BaseRepository) getHitsResult(c context.Context, req esapi.Request) (respData HitsResult, err error) {
-	res, err := req.Do(c, r.DB)
+	res, err := r.do(c, req)
 	if err != nil {
 		return
 	}
 	defer res.Body.Close()
 
-	err = json.NewDecoder(res.Body).Decode(&respData)
-	if err != nil {
+	if err = decodeError(res); err != nil {
 		return
 	}
 
-	if res.StatusCode != 200 {
-		// todo 看怎么拿到详情
-		err = errors.New("not found")
-	}
+	err = json.NewDecoder(res.Body).Decode(&respData)
 	return
 }
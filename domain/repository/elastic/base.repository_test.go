@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	elasticsearch6 "github.com/elastic/go-elasticsearch/v6"
 	"github.com/micro/go-micro/v2/config"
 	"github.com/micro/go-micro/v2/config/source/memory"
 	"github.com/micro/go-micro/v2/logger"
@@ -59,7 +58,7 @@ func getConfig() (config.Config, error) {
 	return config, nil
 }
 
-func getDB(config config.Config) (*elasticsearch6.Client, error) {
+func getDB(config config.Config) (*elastic.Client, error) {
 	db, err := elastic.NewElasticProvider(config)
 	if err != nil {
 		log.Panic("数据库连接失败")
@@ -0,0 +1,16 @@
+package elastic
+
+import (
+	"errors"
+
+	"github.com/xxxmicro/base/database/breaker"
+)
+
+// IsFailure 熔断器的失败判断，通用部分见 breaker.IsFailure，集群返回 5xx 或 429(过载)时计为失败
+// 其它 4xx(如文档不存在、mapping 冲突)是请求本身的问题
+func IsFailure(err error) bool {
+	return breaker.IsFailure(err, func(err error) bool {
+		var e *Error
+		return errors.As(err, &e) && (e.Status >= 500 || e.Status == 429)
+	})
+}
@@ -0,0 +1,15 @@
+package elastic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsFailure(t *testing.T) {
+	assert.False(t, IsFailure(&Error{Status: 404, Type: "index_not_found_exception"}))
+	assert.False(t, IsFailure(&Error{Status: 400, Type: "mapper_parsing_exception"}))
+
+	assert.True(t, IsFailure(&Error{Status: 503}))
+	assert.True(t, IsFailure(&Error{Status: 429}))
+}
@@ -2,33 +2,69 @@ package elastic
 
 import "github.com/xxxmicro/base/domain/model"
 
-func buildCursorSearch(cursorQuery *model.CursorQuery) map[string]interface{} {
+// buildCursorSearch 构造游标查询，按游标字段排序并以 tiebreaker 作为第二排序字段打破相同值
+// 游标为 [游标字段值, tiebreaker 值] 时使用 search_after，旧的单值游标退化为游标字段的范围查询
+// reverse 为 true 时查询结果需要反转后返回
+func buildCursorSearch(cursorQuery *model.CursorQuery, size int, tiebreaker string) (search map[string]interface{}, reverse bool, err error) {
+	order, reverse := cursorOrder(cursorQuery.CursorSort.Type, cursorQuery.Direction)
+	prop := cursorQuery.CursorSort.Property
 
-	{
-		filterDirection := model.FilterType_ES_LT_FILTER
-		if cursorQuery.Direction == 1 {
-			filterDirection = model.FilterType_ES_GT_FILTER
+	query, err := buildQuery(cursorQuery.Filters)
+	if err != nil {
+		return
+	}
+
+	search = map[string]interface{}{
+		"query": query,
+		"size":  size,
+		"sort":  buildCursorSort(prop, tiebreaker, order),
+	}
+
+	switch cursor := cursorQuery.Cursor.(type) {
+	case nil:
+	case []interface{}:
+		search["search_after"] = cursor
+	default:
+		filterType := model.FilterType_ES_GT_FILTER
+		if order == "desc" {
+			filterType = model.FilterType_ES_LT_FILTER
 		}
-		cursorQuery.Filters[cursorQuery.CursorSort.Property] = map[string]interface{}{
-			string(filterDirection): cursorQuery.Cursor,
+		cursorRange, err := buildRange(prop, map[string]interface{}{
+			string(filterType): cursor,
+		})
+		if err != nil {
+			return nil, false, err
 		}
+		musts, _ := query["bool"]["must"].([]interface{})
+		query["bool"]["must"] = append(musts, cursorRange)
 	}
 
-	query := buildQuery(cursorQuery.Filters)
-	sort := buildCursorSort(cursorQuery.CursorSort.Property, cursorQuery.Direction)
+	return
+}
 
-	search := map[string]interface{}{
-		"query": query,
-		"size":  cursorQuery.Size,
-		"sort":  sort,
+// cursorOrder 根据游标排序方式和查询方向确定实际查询的排序
+func cursorOrder(sortType model.SortType, direction byte) (order string, reverse bool) {
+	switch sortType {
+	case model.SortType_DSC:
+		if direction == 0 {
+			// 游标前
+			return "asc", true
+		}
+		// 游标后
+		return "desc", false
+	default: // SortType_ASC
+		if direction == 0 {
+			// 游标前
+			return "desc", true
+		}
+		// 游标后
+		return "asc", false
 	}
-	return search
-
 }
 
-func buildCursorSort(property string, direction byte) map[string]string {
-	if direction == 0 {
-		return map[string]string{property: "desc"}
+func buildCursorSort(property string, tiebreaker string, order string) []map[string]string {
+	return []map[string]string{
+		{property: order},
+		{tiebreaker: order},
 	}
-	return map[string]string{property: "asc"}
 }
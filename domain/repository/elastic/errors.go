@@ -0,0 +1,146 @@
+package elastic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/elastic/go-elasticsearch/v6/esapi"
+)
+
+const (
+	ErrTypeVersionConflict      = "version_conflict_engine_exception"
+	ErrTypeIndexNotFound        = "index_not_found_exception"
+	ErrTypeMapperParsing        = "mapper_parsing_exception"
+	ErrTypeStrictDynamicMapping = "strict_dynamic_mapping_exception"
+	ErrTypeDocumentNotFound     = "document_not_found" // 文档不存在时 ES 不返回 error，由仓库补充
+)
+
+// ErrorCause ES 返回的错误原因
+type ErrorCause struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+	Index  string `json:"index,omitempty"`
+}
+
+// Error ES 请求失败时返回的错误，由响应体中的 error 和 status 解析得到
+type Error struct {
+	Status    int          `json:"status"`
+	Type      string       `json:"type"`
+	Reason    string       `json:"reason"`
+	Index     string       `json:"index,omitempty"`
+	RootCause []ErrorCause `json:"root_cause,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if len(e.RootCause) > 0 && e.RootCause[0].Reason != e.Reason {
+		return fmt.Sprintf("elastic: %d %s: %s (root cause: %s: %s)", e.Status, e.Type, e.Reason, e.RootCause[0].Type, e.RootCause[0].Reason)
+	}
+	return fmt.Sprintf("elastic: %d %s: %s", e.Status, e.Type, e.Reason)
+}
+
+// IsVersionConflict 文档已存在或版本冲突
+func IsVersionConflict(err error) bool {
+	return hasErrorType(err, ErrTypeVersionConflict)
+}
+
+// IsIndexNotFound 索引不存在
+func IsIndexNotFound(err error) bool {
+	return hasErrorType(err, ErrTypeIndexNotFound)
+}
+
+// IsNotFound 文档或索引不存在
+func IsNotFound(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	return e.Status == 404
+}
+
+// IsMappingError 文档与索引 mapping 不匹配
+func IsMappingError(err error) bool {
+	return hasErrorType(err, ErrTypeMapperParsing) || hasErrorType(err, ErrTypeStrictDynamicMapping)
+}
+
+func hasErrorType(err error, errType string) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	if e.Type == errType {
+		return true
+	}
+	for _, cause := range e.RootCause {
+		if cause.Type == errType {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeError 解析失败响应的响应体，成功响应返回 nil
+func decodeError(res *esapi.Response) error {
+	if !res.IsError() {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	return parseError(res.StatusCode, body)
+}
+
+func parseError(status int, body []byte) error {
+	e := &Error{Status: status}
+
+	var respData struct {
+		Error  json.RawMessage `json:"error"`
+		Status int             `json:"status"`
+		Found  *bool           `json:"found"`
+		Result string          `json:"result"`
+	}
+	if err := json.Unmarshal(body, &respData); err != nil {
+		e.Type = "unknown"
+		e.Reason = string(body)
+		return e
+	}
+
+	if len(respData.Error) == 0 {
+		// GET/DELETE 文档不存在时只返回 found: false 或 result: not_found
+		if (respData.Found != nil && !*respData.Found) || respData.Result == "not_found" {
+			e.Type = ErrTypeDocumentNotFound
+			e.Reason = "document not found"
+		} else {
+			e.Type = "unknown"
+			e.Reason = string(body)
+		}
+		return e
+	}
+
+	var reason string
+	if err := json.Unmarshal(respData.Error, &reason); err == nil {
+		// 部分接口 error 字段为字符串
+		e.Type = "unknown"
+		e.Reason = reason
+		return e
+	}
+
+	var cause struct {
+		ErrorCause
+		RootCause []ErrorCause `json:"root_cause"`
+	}
+	if err := json.Unmarshal(respData.Error, &cause); err != nil {
+		e.Type = "unknown"
+		e.Reason = string(respData.Error)
+		return e
+	}
+
+	e.Type = cause.Type
+	e.Reason = cause.Reason
+	e.Index = cause.Index
+	e.RootCause = cause.RootCause
+	return e
+}
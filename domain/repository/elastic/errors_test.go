@@ -0,0 +1,34 @@
+package elastic
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseError(t *testing.T) {
+	err := parseError(409, []byte(`{"error":{"root_cause":[{"type":"version_conflict_engine_exception","reason":"[users][1]: version conflict, document already exists (current version [1])","index":"users"}],"type":"version_conflict_engine_exception","reason":"[users][1]: version conflict, document already exists (current version [1])","index":"users"},"status":409}`))
+	assert.True(t, IsVersionConflict(err))
+	assert.False(t, IsIndexNotFound(err))
+	t.Log(err)
+
+	err = parseError(404, []byte(`{"error":{"root_cause":[{"type":"index_not_found_exception","reason":"no such index","index":"orders"}],"type":"index_not_found_exception","reason":"no such index","index":"orders"},"status":404}`))
+	assert.True(t, IsIndexNotFound(err))
+	assert.True(t, IsNotFound(err))
+
+	err = parseError(400, []byte(`{"error":{"root_cause":[{"type":"mapper_parsing_exception","reason":"failed to parse field [age] of type [long]"}],"type":"mapper_parsing_exception","reason":"failed to parse","caused_by":{"type":"illegal_argument_exception","reason":"For input string: \"abc\""}},"status":400}`))
+	assert.True(t, IsMappingError(err))
+
+	err = parseError(404, []byte(`{"_index":"users","_type":"users","_id":"1","found":false}`))
+	assert.True(t, IsNotFound(err))
+	assert.Equal(t, ErrTypeDocumentNotFound, err.(*Error).Type)
+
+	err = parseError(404, []byte(`{"error":"alias [users] missing","status":404}`))
+	assert.True(t, IsNotFound(err))
+
+	wrapped := fmt.Errorf("create user: %w", parseError(409, []byte(`{"error":{"type":"version_conflict_engine_exception","reason":"conflict"},"status":409}`)))
+	assert.True(t, IsVersionConflict(wrapped))
+	assert.False(t, IsVersionConflict(errors.New("conflict")))
+}
@@ -0,0 +1,94 @@
+package elastic
+
+import (
+	"fmt"
+
+	"github.com/xxxmicro/base/domain/model"
+)
+
+// buildGeoQuery 地理条件，字段需为 geo_point 类型
+func buildGeoQuery(column string, filterType model.FilterType, value interface{}) (interface{}, error) {
+	switch filterType {
+	case model.FilterType_GEO_NEAR:
+		near, err := model.ParseGeoNear(value)
+		if err != nil {
+			return nil, err
+		}
+		return buildGeoDistance(column, near), nil
+	case model.FilterType_GEO_WITHIN_BOX:
+		box, err := model.ParseGeoBox(value)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"geo_bounding_box": map[string]interface{}{
+				column: map[string]interface{}{
+					"top_left":     geoLatLon(box.TopLeft),
+					"bottom_right": geoLatLon(box.BottomRight),
+				},
+			},
+		}, nil
+	case model.FilterType_GEO_WITHIN_POLYGON:
+		points, err := model.ParseGeoPolygon(value)
+		if err != nil {
+			return nil, err
+		}
+		latLons := make([]map[string]float64, 0, len(points))
+		for _, p := range points {
+			latLons = append(latLons, geoLatLon(p))
+		}
+		return map[string]interface{}{
+			"geo_polygon": map[string]interface{}{
+				column: map[string]interface{}{
+					"points": latLons,
+				},
+			},
+		}, nil
+	}
+	return nil, model.ErrMalformedGeo
+}
+
+func buildGeoDistance(column string, near *model.GeoNear) interface{} {
+	distance := func(meters float64) map[string]interface{} {
+		return map[string]interface{}{
+			"geo_distance": map[string]interface{}{
+				"distance": fmt.Sprintf("%gm", meters),
+				column:     geoLatLon(near.Point),
+			},
+		}
+	}
+
+	query := map[string]interface{}{}
+	if near.MaxDistance > 0 {
+		query["filter"] = []interface{}{distance(near.MaxDistance)}
+	} else {
+		query["filter"] = []interface{}{map[string]interface{}{
+			"exists": map[string]interface{}{"field": column},
+		}}
+	}
+	if near.MinDistance > 0 {
+		query["must_not"] = []interface{}{distance(near.MinDistance)}
+	}
+
+	return map[string]interface{}{
+		"bool": query,
+	}
+}
+
+func buildGeoSort(spec *model.SortSpec) map[string]interface{} {
+	order := "asc"
+	if spec.Type == model.SortType_DSC {
+		order = "desc"
+	}
+	return map[string]interface{}{
+		"_geo_distance": map[string]interface{}{
+			spec.Property: geoLatLon(*spec.Near),
+			"order":       order,
+			"unit":        "m",
+		},
+	}
+}
+
+func geoLatLon(p model.GeoPoint) map[string]float64 {
+	return map[string]float64{"lat": p.Lat, "lon": p.Lng}
+}
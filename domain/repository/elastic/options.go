@@ -0,0 +1,29 @@
+package elastic
+
+import (
+	"github.com/micro/go-micro/v2/logger"
+)
+
+type Options struct {
+	Logger logger.Logger
+	// Tiebreaker 游标查询的第二排序字段，默认 _id
+	// _id 排序依赖 fielddata，ES 7.6 起废弃、8.x 默认禁用，此时需指定启用 doc values 的 keyword 字段，见 WithTiebreaker
+	Tiebreaker string
+}
+
+type Option func(o *Options)
+
+// WithLogger 指定仓库使用的日志，未指定时使用 logger.DefaultLogger
+func WithLogger(l logger.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
+
+// WithTiebreaker 指定游标查询打破相同值的排序字段，例如文档中保存的 id
+// 字段须为 keyword 类型，Reindex 和 EnsureRollingTemplate 生成的 mapping 中未显式声明时自动声明为 keyword
+func WithTiebreaker(field string) Option {
+	return func(o *Options) {
+		o.Tiebreaker = field
+	}
+}
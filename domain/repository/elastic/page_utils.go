@@ -2,12 +2,17 @@ package elastic
 
 import "github.com/xxxmicro/base/domain/model"
 
-func buildPageSearch(pageQuery *model.PageQuery) map[string]interface{} {
-	query := buildQuery(pageQuery.Filters)
+func buildPageSearch(pageQuery *model.PageQuery) (map[string]interface{}, error) {
+	query, err := buildQuery(pageQuery.Filters)
+	if err != nil {
+		return nil, err
+	}
 	search := map[string]interface{}{
 		"query": query,
 		"from":  (pageQuery.PageNo - 1) * pageQuery.PageSize,
 		"size":  pageQuery.PageSize,
+		// 7.x 默认只统计到 10000
+		"track_total_hits": true,
 	}
 
 	sort := buildSort(pageQuery.Sort)
@@ -15,5 +20,5 @@ func buildPageSearch(pageQuery *model.PageQuery) map[string]interface{} {
 		search["sort"] = sort
 	}
 
-	return search
+	return search, nil
 }
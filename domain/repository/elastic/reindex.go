@@ -0,0 +1,512 @@
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v6/esapi"
+	"github.com/micro/go-micro/v2/logger"
+	"github.com/xxxmicro/base/domain/model"
+	breflect2 "github.com/xxxmicro/base/domain/repository/elastic/reflect"
+)
+
+var (
+	ErrIndexNotAliased  = errors.New("ERR_ES_INDEX_NOT_ALIASED")
+	ErrNoRollbackTarget = errors.New("ERR_ES_NO_ROLLBACK_TARGET")
+)
+
+const maxReplayRounds = 5
+
+type ReindexOptions struct {
+	Settings     map[string]interface{} // 新索引的 settings
+	BatchSize    int                    // 每批复制的文档数
+	Scroll       bool                   // 直接使用 scroll+bulk 复制，不使用 _reindex
+	ReplayWrites bool                   // 回放复制期间经由本仓库写入的文档
+}
+
+type ReindexOption func(o *ReindexOptions)
+
+func ReindexSettings(settings map[string]interface{}) ReindexOption {
+	return func(o *ReindexOptions) {
+		o.Settings = settings
+	}
+}
+
+func ReindexBatchSize(size int) ReindexOption {
+	return func(o *ReindexOptions) {
+		o.BatchSize = size
+	}
+}
+
+func ReindexWithScroll() ReindexOption {
+	return func(o *ReindexOptions) {
+		o.Scroll = true
+	}
+}
+
+func ReindexReplayWrites() ReindexOption {
+	return func(o *ReindexOptions) {
+		o.ReplayWrites = true
+	}
+}
+
+type ReindexResult struct {
+	Alias    string `json:"alias"`
+	OldIndex string `json:"oldIndex"` // 原索引，保留用于回滚
+	NewIndex string `json:"newIndex"`
+	Copied   int    `json:"copied"`   // 复制的文档数
+	Replayed int    `json:"replayed"` // 回放的文档数
+}
+
+// Reindex 以新的 mapping 重建模型索引，不停机
+// 1、创建 <alias>_vN+1 索引
+// 2、通过 _reindex 复制数据，失败时退回 scroll+bulk
+// 3、可选回放复制期间的写入
+// 4、原子切换别名，保留旧索引用于回滚
+// 模型索引第一次创建时直接创建 <alias>_v1 并挂上别名
+// mapping 由模型的 Mapping() 和 suggest 标签生成
+func (r *BaseRepository) Reindex(c context.Context, m model.Model, opts ...ReindexOption) (result *ReindexResult, err error) {
+	options := ReindexOptions{
+		BatchSize: 500,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	ms, err := breflect2.GetStructInfo(m, nil)
+	if err != nil {
+		return
+	}
+	alias := TheNamingStrategy.Table(ms.Name)
+
+	sp := startSpan(c, "Reindex", alias)
+	defer func() {
+		if result != nil {
+			sp.SetTag("es.old_index", result.OldIndex)
+			sp.SetTag("es.new_index", result.NewIndex)
+			sp.SetTag("es.replayed", result.Replayed)
+			sp.SetCount(result.Copied)
+		}
+		sp.Finish(err)
+	}()
+
+	oldIndex, err := r.aliasTarget(c, alias)
+	if err != nil {
+		return
+	}
+
+	if oldIndex == "" {
+		var exists bool
+		exists, err = r.indexExists(c, alias)
+		if err != nil {
+			return
+		}
+		if exists {
+			// 历史索引直接以别名命名，无法原子切换
+			err = ErrIndexNotAliased
+			return
+		}
+	}
+
+	result = &ReindexResult{
+		Alias:    alias,
+		OldIndex: oldIndex,
+		NewIndex: versionedIndex(alias, parseIndexVersion(alias, oldIndex)+1),
+	}
+
+	mapping, err := r.modelMapping(m)
+	if err != nil {
+		return
+	}
+
+	body := buildCreateIndexBody(r.DB.MappingType(alias), mapping, options.Settings)
+	if err = r.createIndex(c, result.NewIndex, body); err != nil {
+		return
+	}
+
+	// 切换别名前失败时删除新索引，否则重试时会因同名索引已存在而失败
+	// c 可能已结束，删除使用独立的 context
+	defer func() {
+		if err == nil {
+			return
+		}
+		if e := r.deleteIndex(context.Background(), result.NewIndex); e != nil {
+			r.logger().Logf(logger.WarnLevel, "reindex %s failed, delete %s: %v", alias, result.NewIndex, e)
+		}
+	}()
+
+	if oldIndex == "" {
+		err = r.swapAlias(c, alias, "", result.NewIndex)
+		return
+	}
+
+	gate := r.gates.get(alias)
+	if options.ReplayWrites {
+		gate.startCapture()
+		defer gate.stopCapture()
+	}
+
+	if err = r.refreshIndex(c, oldIndex); err != nil {
+		return
+	}
+
+	if options.Scroll {
+		result.Copied, err = r.reindexByScroll(c, r.DB.MappingType(alias), oldIndex, result.NewIndex, options.BatchSize)
+	} else {
+		result.Copied, err = r.reindexByTask(c, oldIndex, result.NewIndex, options.BatchSize)
+		if err != nil {
+			r.logger().Logf(logger.WarnLevel, "reindex %s -> %s by _reindex failed, fallback to scroll: %v", oldIndex, result.NewIndex, err)
+			result.Copied, err = r.reindexByScroll(c, r.DB.MappingType(alias), oldIndex, result.NewIndex, options.BatchSize)
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	if !options.ReplayWrites {
+		err = r.swapAlias(c, alias, oldIndex, result.NewIndex)
+		return
+	}
+
+	// 复制期间的写入先回放几轮，尽量缩短切换时阻塞写入的时间
+	for i := 0; i < maxReplayRounds; i++ {
+		ids := gate.drain()
+		if len(ids) == 0 {
+			break
+		}
+
+		var n int
+		n, err = r.replayDocuments(c, r.DB.DocumentType(alias), oldIndex, result.NewIndex, ids)
+		result.Replayed += n
+		if err != nil {
+			return
+		}
+	}
+
+	gate.l.Lock()
+	defer gate.l.Unlock()
+
+	n, err := r.replayDocuments(c, r.DB.DocumentType(alias), oldIndex, result.NewIndex, gate.drain())
+	result.Replayed += n
+	if err != nil {
+		return
+	}
+
+	err = r.swapAlias(c, alias, oldIndex, result.NewIndex)
+	return
+}
+
+// Rollback 将别名切回上一个版本的索引，返回切换后的索引名
+func (r *BaseRepository) Rollback(c context.Context, m model.Model) (index string, err error) {
+	ms, err := breflect2.GetStructInfo(m, nil)
+	if err != nil {
+		return
+	}
+	alias := TheNamingStrategy.Table(ms.Name)
+
+	sp := startSpan(c, "Rollback", alias)
+	defer func() {
+		sp.SetTag("es.new_index", index)
+		sp.Finish(err)
+	}()
+
+	current, err := r.aliasTarget(c, alias)
+	if err != nil {
+		return
+	}
+
+	version := parseIndexVersion(alias, current)
+	if version <= 1 {
+		err = ErrNoRollbackTarget
+		return
+	}
+
+	index = versionedIndex(alias, version-1)
+	exists, err := r.indexExists(c, index)
+	if err != nil {
+		return
+	}
+	if !exists {
+		err = ErrNoRollbackTarget
+		return
+	}
+
+	gate := r.gates.get(alias)
+	gate.l.Lock()
+	defer gate.l.Unlock()
+
+	err = r.swapAlias(c, alias, current, index)
+	return
+}
+
+// aliasTarget 返回别名指向的索引，别名不存在时返回空
+func (r *BaseRepository) aliasTarget(c context.Context, alias string) (string, error) {
+	req := esapi.IndicesGetAliasRequest{
+		Name: []string{alias},
+	}
+	res, err := r.do(c, req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		return "", nil
+	}
+	if err = decodeError(res); err != nil {
+		return "", err
+	}
+
+	var respData map[string]interface{}
+	if err = json.NewDecoder(res.Body).Decode(&respData); err != nil {
+		return "", err
+	}
+
+	if len(respData) > 1 {
+		return "", errors.New(fmt.Sprintf("ERR_ES_ALIAS_MULTIPLE_INDICES %s", alias))
+	}
+	for index := range respData {
+		return index, nil
+	}
+	return "", nil
+}
+
+func (r *BaseRepository) indexExists(c context.Context, index string) (bool, error) {
+	req := esapi.IndicesExistsRequest{
+		Index: []string{index},
+	}
+	res, err := r.do(c, req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	return res.StatusCode == 200, nil
+}
+
+func (r *BaseRepository) createIndex(c context.Context, index string, body map[string]interface{}) error {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req := esapi.IndicesCreateRequest{
+		Index: index,
+		Body:  bytes.NewReader(jsonBody),
+	}
+	res, err := r.do(c, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return decodeError(res)
+}
+
+func (r *BaseRepository) deleteIndex(c context.Context, index string) error {
+	req := esapi.IndicesDeleteRequest{
+		Index: []string{index},
+	}
+	res, err := r.do(c, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return decodeError(res)
+}
+
+func (r *BaseRepository) refreshIndex(c context.Context, index string) error {
+	req := esapi.IndicesRefreshRequest{
+		Index: []string{index},
+	}
+	res, err := r.do(c, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return decodeError(res)
+}
+
+func (r *BaseRepository) swapAlias(c context.Context, alias string, from string, to string) error {
+	jsonBody, err := json.Marshal(buildSwapAliasBody(alias, from, to))
+	if err != nil {
+		return err
+	}
+
+	req := esapi.IndicesUpdateAliasesRequest{
+		Body: bytes.NewReader(jsonBody),
+	}
+	res, err := r.do(c, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return decodeError(res)
+}
+
+// reindexByTask 使用 _reindex 复制数据
+func (r *BaseRepository) reindexByTask(c context.Context, from string, to string, batchSize int) (int, error) {
+	body := map[string]interface{}{
+		"source": map[string]interface{}{
+			"index": from,
+			"size":  batchSize,
+		},
+		"dest": map[string]interface{}{
+			"index": to,
+		},
+	}
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+
+	waitForCompletion := true
+	refresh := true
+	req := esapi.ReindexRequest{
+		Body:              bytes.NewReader(jsonBody),
+		WaitForCompletion: &waitForCompletion,
+		Refresh:           &refresh,
+	}
+	res, err := r.do(c, req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if err = decodeError(res); err != nil {
+		return 0, err
+	}
+
+	var respData struct {
+		Total    int           `json:"total"`
+		Failures []interface{} `json:"failures"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&respData); err != nil {
+		return 0, err
+	}
+
+	if len(respData.Failures) > 0 {
+		b, _ := json.Marshal(respData.Failures)
+		return respData.Total, errors.New(fmt.Sprintf("ERR_ES_REINDEX_FAILURES %s", b))
+	}
+	return respData.Total, nil
+}
+
+// reindexByScroll 通过 scroll 读取旧索引，bulk 写入新索引
+func (r *BaseRepository) reindexByScroll(c context.Context, docType string, from string, to string, batchSize int) (copied int, err error) {
+	search := map[string]interface{}{
+		"size": batchSize,
+		"sort": []string{"_doc"},
+	}
+
+	err = r.scroll(c, from, search, time.Minute, func(hits []scrollHit) error {
+		if err := r.bulkIndex(c, to, docType, hits); err != nil {
+			return err
+		}
+		copied += len(hits)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	err = r.refreshIndex(c, to)
+	return
+}
+
+func (r *BaseRepository) bulkIndex(c context.Context, index string, docType string, hits []scrollHit) error {
+	body, err := buildBulkIndexBody(index, docType, hits)
+	if err != nil {
+		return err
+	}
+
+	req := esapi.BulkRequest{
+		Body: body,
+	}
+	res, err := r.do(c, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err = decodeError(res); err != nil {
+		return err
+	}
+
+	var respData struct {
+		Errors bool          `json:"errors"`
+		Items  []interface{} `json:"items"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&respData); err != nil {
+		return err
+	}
+	if respData.Errors {
+		return errors.New(fmt.Sprintf("ERR_ES_BULK_FAILURES %s", index))
+	}
+	return nil
+}
+
+// replayDocuments 将旧索引中的文档同步到新索引，旧索引中已删除的文档在新索引中同样删除
+func (r *BaseRepository) replayDocuments(c context.Context, docType string, from string, to string, ids []string) (replayed int, err error) {
+	for _, id := range ids {
+		getReq := esapi.GetRequest{
+			Index:        from,
+			DocumentType: docType,
+			DocumentID:   id,
+		}
+		var res *esapi.Response
+		res, err = r.do(c, getReq)
+		if err != nil {
+			return
+		}
+
+		var hit scrollHit
+		found := res.StatusCode == 200
+		if found {
+			err = json.NewDecoder(res.Body).Decode(&hit)
+		} else if res.StatusCode != 404 {
+			err = decodeError(res)
+		}
+		res.Body.Close()
+		if err != nil {
+			return
+		}
+
+		var req esapi.Request
+		if found {
+			req = esapi.IndexRequest{
+				Index:        to,
+				DocumentType: docType,
+				DocumentID:   id,
+				Body:         bytes.NewReader(hit.Source),
+			}
+		} else {
+			req = esapi.DeleteRequest{
+				Index:        to,
+				DocumentType: docType,
+				DocumentID:   id,
+			}
+		}
+
+		res, err = r.do(c, req)
+		if err != nil {
+			return
+		}
+		if res.StatusCode != 404 {
+			err = decodeError(res)
+		}
+		res.Body.Close()
+		if err != nil {
+			return
+		}
+		replayed++
+	}
+	return
+}
@@ -0,0 +1,136 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	elasticsearch6 "github.com/elastic/go-elasticsearch/v6"
+	"github.com/elastic/go-elasticsearch/v6/esapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/xxxmicro/base/database/elastic"
+)
+
+func TestParseIndexVersion(t *testing.T) {
+	assert.Equal(t, 0, parseIndexVersion("users", ""))
+	assert.Equal(t, 0, parseIndexVersion("users", "users"))
+	assert.Equal(t, 1, parseIndexVersion("users", "users_v1"))
+	assert.Equal(t, 12, parseIndexVersion("users", "users_v12"))
+	assert.Equal(t, 0, parseIndexVersion("users", "orders_v3"))
+	assert.Equal(t, "users_v2", versionedIndex("users", parseIndexVersion("users", "users_v1")+1))
+}
+
+func TestBuildSwapAliasBody(t *testing.T) {
+	body, err := json.Marshal(buildSwapAliasBody("users", "users_v1", "users_v2"))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"actions":[{"remove":{"alias":"users","index":"users_v1"}},{"add":{"alias":"users","index":"users_v2"}}]}`, string(body))
+
+	body, err = json.Marshal(buildSwapAliasBody("users", "", "users_v1"))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"actions":[{"add":{"alias":"users","index":"users_v1"}}]}`, string(body))
+}
+
+func TestBuildBulkIndexBody(t *testing.T) {
+	hits := []scrollHit{
+		{ID: "1", Source: json.RawMessage(`{"name":"吕布"}`)},
+		{ID: "2", Source: json.RawMessage(`{"name":"貂蝉"}`)},
+	}
+
+	buf, err := buildBulkIndexBody("users_v2", "users", hits)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, 4, len(lines))
+	assert.Equal(t, `{"index":{"_id":"1","_index":"users_v2","_type":"users"}}`, lines[0])
+	assert.Equal(t, `{"name":"貂蝉"}`, lines[3])
+}
+
+func TestWriteGateCapture(t *testing.T) {
+	gate := &writeGate{}
+	gate.record("1")
+	assert.Equal(t, 0, len(gate.drain()))
+
+	gate.startCapture()
+	gate.record("1")
+	gate.record("2")
+	gate.record("1")
+	assert.Equal(t, 2, len(gate.drain()))
+	assert.Equal(t, 0, len(gate.drain()))
+
+	gate.stopCapture()
+	gate.record("3")
+	assert.Equal(t, 0, len(gate.drain()))
+}
+
+func TestBuildCreateIndexBody(t *testing.T) {
+	mapping := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "keyword"},
+		},
+	}
+
+	body, _ := json.Marshal(buildCreateIndexBody("users", mapping, nil))
+	assert.Equal(t, `{"mappings":{"users":{"properties":{"name":{"type":"keyword"}}}}}`, string(body))
+
+	// 7.x 不带类型
+	body, _ = json.Marshal(buildCreateIndexBody("", mapping, nil))
+	assert.Equal(t, `{"mappings":{"properties":{"name":{"type":"keyword"}}}}`, string(body))
+}
+
+// fakeCluster 只实现 Reindex 用到的接口，users 别名指向 users_v1
+type fakeCluster struct {
+	indices  map[string]bool
+	failCopy bool
+}
+
+func (f *fakeCluster) Perform(req *http.Request) (*http.Response, error) {
+	status, body := 200, `{}`
+	path := req.URL.Path
+	switch {
+	case path == "/_alias/users":
+		body = `{"users_v1":{"aliases":{"users":{}}}}`
+	case req.Method == "HEAD":
+		if !f.indices[strings.TrimPrefix(path, "/")] {
+			status = 404
+		}
+	case req.Method == "PUT":
+		index := strings.TrimPrefix(path, "/")
+		if f.indices[index] {
+			status, body = 400, `{"error":{"type":"resource_already_exists_exception","reason":"index already exists"},"status":400}`
+		}
+		f.indices[index] = true
+	case req.Method == "DELETE":
+		delete(f.indices, strings.TrimPrefix(path, "/"))
+	case path == "/_reindex" || strings.HasSuffix(path, "/_search"):
+		if f.failCopy {
+			status, body = 500, `{"error":{"type":"exception","reason":"copy failed"},"status":500}`
+		} else {
+			body = `{"total":0,"failures":[]}`
+		}
+	}
+	return &http.Response{StatusCode: status, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+}
+
+func TestReindexRetryAfterCopyFailure(t *testing.T) {
+	cluster := &fakeCluster{indices: map[string]bool{"users_v1": true}, failCopy: true}
+	db := &elastic.Client{
+		Client:  &elasticsearch6.Client{Transport: cluster, API: esapi.New(cluster)},
+		Version: "7.10.2",
+		Major:   7,
+	}
+	r := NewBaseRepository(db)
+
+	// 复制失败时删除新建的索引
+	_, err := r.Reindex(context.Background(), &User{})
+	assert.Error(t, err)
+	assert.False(t, cluster.indices["users_v2"])
+
+	cluster.failCopy = false
+	result, err := r.Reindex(context.Background(), &User{})
+	assert.NoError(t, err)
+	assert.Equal(t, "users_v2", result.NewIndex)
+	assert.True(t, cluster.indices["users_v2"])
+}
@@ -0,0 +1,87 @@
+package elastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var indexVersionRegexp = regexp.MustCompile(`_v(\d+)$`)
+
+// parseIndexVersion 从 <alias>_vN 形式的索引名中解析版本号，不带版本后缀返回 0
+func parseIndexVersion(alias string, index string) int {
+	if len(index) <= len(alias) || index[:len(alias)] != alias {
+		return 0
+	}
+
+	matches := indexVersionRegexp.FindStringSubmatch(index[len(alias):])
+	if len(matches) != 2 {
+		return 0
+	}
+
+	version, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0
+	}
+	return version
+}
+
+func versionedIndex(alias string, version int) string {
+	return fmt.Sprintf("%s_v%d", alias, version)
+}
+
+// buildCreateIndexBody docType 为空时生成 7.x 不带类型的 mapping
+func buildCreateIndexBody(docType string, mapping map[string]interface{}, settings map[string]interface{}) map[string]interface{} {
+	body := map[string]interface{}{
+		"mappings": mapping,
+	}
+	if docType != "" {
+		body["mappings"] = map[string]interface{}{
+			docType: mapping,
+		}
+	}
+	if settings != nil {
+		body["settings"] = settings
+	}
+	return body
+}
+
+func buildSwapAliasBody(alias string, from string, to string) map[string]interface{} {
+	var actions []map[string]map[string]string
+	if from != "" {
+		actions = append(actions, map[string]map[string]string{
+			"remove": {"index": from, "alias": alias},
+		})
+	}
+	actions = append(actions, map[string]map[string]string{
+		"add": {"index": to, "alias": alias},
+	})
+
+	return map[string]interface{}{
+		"actions": actions,
+	}
+}
+
+// buildBulkIndexBody 构造 bulk 的 NDJSON 请求体
+func buildBulkIndexBody(index string, docType string, hits []scrollHit) (*bytes.Buffer, error) {
+	buf := new(bytes.Buffer)
+	for _, hit := range hits {
+		meta := map[string]map[string]string{
+			"index": {"_index": index, "_id": hit.ID},
+		}
+		if docType != "" {
+			meta["index"]["_type"] = docType
+		}
+		b, err := json.Marshal(meta)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+		buf.Write(hit.Source)
+		buf.WriteByte('\n')
+	}
+	return buf, nil
+}
@@ -0,0 +1,103 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v6/esapi"
+)
+
+// typelessRequest 7.x 的 /{index}/_create/{id} 与 /{index}/_update/{id} 接口
+// v6 esapi 只能生成带类型的路径
+type typelessRequest struct {
+	Method string
+	Path   string
+	Body   io.Reader
+}
+
+func (r typelessRequest) Do(c context.Context, transport esapi.Transport) (*esapi.Response, error) {
+	req, err := http.NewRequest(r.Method, r.Path, r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if c != nil {
+		req = req.WithContext(c)
+	}
+
+	res, err := transport.Perform(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &esapi.Response{
+		StatusCode: res.StatusCode,
+		Body:       res.Body,
+		Header:     res.Header,
+	}, nil
+}
+
+func (r *BaseRepository) createRequest(index string, docType string, id string, body io.Reader) esapi.Request {
+	if r.DB.Typeless() {
+		return typelessRequest{
+			Method: "PUT",
+			Path:   strings.Join([]string{"", index, "_create", id}, "/"),
+			Body:   body,
+		}
+	}
+
+	return esapi.CreateRequest{
+		Index:        index,
+		DocumentType: docType,
+		DocumentID:   id,
+		Body:         body,
+	}
+}
+
+func (r *BaseRepository) updateRequest(index string, docType string, id string, body io.Reader) esapi.Request {
+	if r.DB.Typeless() {
+		return typelessRequest{
+			Method: "POST",
+			Path:   strings.Join([]string{"", index, "_update", id}, "/"),
+			Body:   body,
+		}
+	}
+
+	return esapi.UpdateRequest{
+		Index:        index,
+		DocumentType: docType,
+		DocumentID:   id,
+		Body:         body,
+	}
+}
+
+// hitsTotal 兼容 6.x 的数字和 7.x 的 {"value": n, "relation": "eq"}
+type hitsTotal int
+
+func (t *hitsTotal) UnmarshalJSON(b []byte) error {
+	var n int
+	if err := json.Unmarshal(b, &n); err == nil {
+		*t = hitsTotal(n)
+		return nil
+	}
+
+	var total struct {
+		Value *int `json:"value"`
+	}
+	if err := json.Unmarshal(b, &total); err != nil {
+		return err
+	}
+	if total.Value == nil {
+		return errors.New("ERR_ES_MALFORMED_HITS_TOTAL " + string(b))
+	}
+	*t = hitsTotal(*total.Value)
+	return nil
+}
@@ -0,0 +1,367 @@
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v6/esapi"
+	"github.com/micro/go-micro/v2/logger"
+	"github.com/xxxmicro/base/domain/model"
+	breflect2 "github.com/xxxmicro/base/domain/repository/elastic/reflect"
+	breflect "github.com/xxxmicro/base/reflect"
+	"github.com/xxxmicro/base/task"
+)
+
+type RollingPeriod string
+
+const (
+	RollingPeriod_DAILY   RollingPeriod = "DAILY"   // 按天，<index>-2006.01.02
+	RollingPeriod_MONTHLY RollingPeriod = "MONTHLY" // 按月，<index>-2006.01
+)
+
+// RollingPolicy 按时间滚动的索引策略，适用于审计、事件等只增不改的日志类数据
+// 写入时根据时间字段路由到带日期后缀的索引，查询时通过别名或通配符查询全部索引
+type RollingPolicy struct {
+	Field     string        // 时间字段名(json)，支持 time.Time、*time.Time 和毫秒时间戳
+	Period    RollingPeriod // 滚动周期
+	Retention time.Duration // 保留时长，0 表示不清理
+	Alias     string        // 查询别名，为空时使用通配符 <index>-*
+}
+
+// Rolling 使用滚动索引的模型
+type Rolling interface {
+	RollingPolicy() *RollingPolicy
+	model.Model
+}
+
+func (p *RollingPolicy) layout() string {
+	if p.Period == RollingPeriod_MONTHLY {
+		return "2006.01"
+	}
+	return "2006.01.02"
+}
+
+// next 索引覆盖时间段的结束时间
+func (p *RollingPolicy) next(t time.Time) time.Time {
+	if p.Period == RollingPeriod_MONTHLY {
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+func (p *RollingPolicy) pattern(index string) string {
+	return index + "-*"
+}
+
+func (p *RollingPolicy) readIndex(index string) string {
+	if p.Alias != "" {
+		return p.Alias
+	}
+	return p.pattern(index)
+}
+
+func rollingIndex(index string, p *RollingPolicy, t time.Time) string {
+	return index + "-" + t.UTC().Format(p.layout())
+}
+
+func rollingPolicy(m model.Model) *RollingPolicy {
+	rolling, ok := m.(Rolling)
+	if !ok {
+		return nil
+	}
+	return rolling.RollingPolicy()
+}
+
+// readIndex 查询使用的索引，滚动索引返回别名或通配符
+func readIndex(m model.Model, index string) string {
+	if p := rollingPolicy(m); p != nil {
+		return p.readIndex(index)
+	}
+	return index
+}
+
+// rollingTime 读取模型的时间字段，字段为零值时 ok 为 false
+func rollingTime(m model.Model, p *RollingPolicy) (t time.Time, ok bool, err error) {
+	ms, err := breflect2.GetStructInfo(m, nil)
+	if err != nil {
+		return
+	}
+
+	field, found := ms.FieldsMap[p.Field]
+	if !found {
+		err = errors.New(fmt.Sprintf("ERR_ES_UNKNOWN_ROLLING_FIELD %s", p.Field))
+		return
+	}
+
+	value, err := breflect.GetStructField(m, field.Name)
+	if err != nil {
+		return
+	}
+
+	switch v := value.Interface().(type) {
+	case time.Time:
+		t = v
+	case *time.Time:
+		if v != nil {
+			t = *v
+		}
+	case int64:
+		if v > 0 {
+			t = time.Unix(0, v*int64(time.Millisecond))
+		}
+	default:
+		err = errors.New(fmt.Sprintf("ERR_ES_ROLLING_FIELD_TYPE %s", field.FieldType))
+		return
+	}
+
+	ok = !t.IsZero()
+	return
+}
+
+// targetIndex 写入或按ID访问文档的实际索引
+// 非滚动索引直接返回 index；滚动索引根据时间字段计算，时间字段为空时：
+// id 不为空则查找文档所在索引，找不到时 fallbackNow 为 true 则使用当前时间
+func (r *BaseRepository) targetIndex(c context.Context, m model.Model, index string, id string, fallbackNow bool) (string, error) {
+	p := rollingPolicy(m)
+	if p == nil {
+		return index, nil
+	}
+
+	t, ok, err := rollingTime(m, p)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return rollingIndex(index, p, t), nil
+	}
+
+	if id != "" {
+		target, err := r.locateIndex(c, p.readIndex(index), id)
+		if err == nil {
+			return target, nil
+		}
+		if !fallbackNow || !IsNotFound(err) {
+			return "", err
+		}
+	}
+
+	if !fallbackNow {
+		return "", &Error{Status: 404, Type: ErrTypeDocumentNotFound, Reason: "document not found"}
+	}
+	return rollingIndex(index, p, time.Now()), nil
+}
+
+// locateIndex 通过 ids 查询找到文档所在的索引
+func (r *BaseRepository) locateIndex(c context.Context, index string, id string) (string, error) {
+	search := map[string]interface{}{
+		"query": map[string]interface{}{
+			"ids": map[string]interface{}{
+				"values": []string{id},
+			},
+		},
+		"size":    1,
+		"_source": false,
+	}
+	jsonBody, err := json.Marshal(search)
+	if err != nil {
+		return "", err
+	}
+
+	req := esapi.SearchRequest{
+		Index:      []string{index},
+		Body:       bytes.NewReader(jsonBody),
+		FilterPath: []string{"hits.hits._index"},
+	}
+	res, err := r.do(c, req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if err = decodeError(res); err != nil {
+		return "", err
+	}
+
+	var respData struct {
+		Hits struct {
+			Hits []struct {
+				Index string `json:"_index"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&respData); err != nil {
+		return "", err
+	}
+
+	if len(respData.Hits.Hits) == 0 {
+		return "", &Error{Status: 404, Type: ErrTypeDocumentNotFound, Reason: "document not found"}
+	}
+	return respData.Hits.Hits[0].Index, nil
+}
+
+// EnsureRollingTemplate 创建或更新滚动索引的模板，新索引自动使用模型 mapping 并加入查询别名
+func (r *BaseRepository) EnsureRollingTemplate(c context.Context, m model.Model) (err error) {
+	p := rollingPolicy(m)
+	if p == nil {
+		return errors.New("ERR_ES_NOT_ROLLING_MODEL")
+	}
+
+	index, _, err := getModelInfo(m)
+	if err != nil {
+		return err
+	}
+
+	sp := startSpan(c, "EnsureRollingTemplate", index)
+	defer func() { sp.Finish(err) }()
+
+	mapping, err := r.modelMapping(m)
+	if err != nil {
+		return err
+	}
+
+	body := buildRollingTemplateBody(index, r.DB.MappingType(index), mapping, p)
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req := esapi.IndicesPutTemplateRequest{
+		Name: index,
+		Body: bytes.NewReader(jsonBody),
+	}
+	res, err := r.do(c, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return decodeError(res)
+}
+
+func buildRollingTemplateBody(index string, docType string, mapping map[string]interface{}, p *RollingPolicy) map[string]interface{} {
+	body := buildCreateIndexBody(docType, mapping, nil)
+	body["index_patterns"] = []string{p.pattern(index)}
+	if p.Alias != "" {
+		body["aliases"] = map[string]interface{}{
+			p.Alias: map[string]interface{}{},
+		}
+	}
+	return body
+}
+
+// PurgeExpiredIndices 删除超过保留时长的滚动索引，返回被删除的索引
+func (r *BaseRepository) PurgeExpiredIndices(c context.Context, m model.Model) (deleted []string, err error) {
+	p := rollingPolicy(m)
+	if p == nil || p.Retention <= 0 {
+		return
+	}
+
+	index, _, err := getModelInfo(m)
+	if err != nil {
+		return
+	}
+
+	sp := startSpan(c, "PurgeExpiredIndices", index)
+	defer func() {
+		sp.SetCount(len(deleted))
+		sp.Finish(err)
+	}()
+
+	req := esapi.CatIndicesRequest{
+		Index:  []string{p.pattern(index)},
+		Format: "json",
+		H:      []string{"index"},
+	}
+	res, err := r.do(c, req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	if err = decodeError(res); err != nil {
+		return
+	}
+
+	var rows []struct {
+		Index string `json:"index"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&rows); err != nil {
+		return
+	}
+
+	indices := make([]string, 0, len(rows))
+	for _, row := range rows {
+		indices = append(indices, row.Index)
+	}
+
+	expired := expiredIndices(index, p, indices, time.Now())
+	if len(expired) == 0 {
+		return
+	}
+
+	deleteReq := esapi.IndicesDeleteRequest{
+		Index: expired,
+	}
+	deleteRes, err := r.do(c, deleteReq)
+	if err != nil {
+		return
+	}
+	defer deleteRes.Body.Close()
+
+	if err = decodeError(deleteRes); err != nil {
+		return
+	}
+
+	deleted = expired
+	return
+}
+
+// expiredIndices 索引覆盖的时间段全部早于 now - Retention 时过期，无法解析日期的索引不处理
+func expiredIndices(index string, p *RollingPolicy, indices []string, now time.Time) []string {
+	deadline := now.Add(-p.Retention)
+
+	var expired []string
+	for _, name := range indices {
+		if !strings.HasPrefix(name, index+"-") {
+			continue
+		}
+
+		t, err := time.ParseInLocation(p.layout(), strings.TrimPrefix(name, index+"-"), time.UTC)
+		if err != nil {
+			continue
+		}
+
+		if !p.next(t).After(deadline) {
+			expired = append(expired, name)
+		}
+	}
+	return expired
+}
+
+type retentionTask struct {
+	r      *BaseRepository
+	models []model.Model
+}
+
+// NewRetentionTask 定时清理滚动索引的任务，可注册到 task.TaskExecutor
+func NewRetentionTask(r *BaseRepository, models ...model.Model) task.ITask {
+	return &retentionTask{r: r, models: models}
+}
+
+func (t *retentionTask) Execute(c context.Context, req *task.RunReq) {
+	for _, m := range t.models {
+		deleted, err := t.r.PurgeExpiredIndices(c, m)
+		if err != nil {
+			t.r.logger().Logf(logger.ErrorLevel, "purge expired indices of %T failed: %v", m, err)
+			continue
+		}
+		if len(deleted) > 0 {
+			t.r.logger().Logf(logger.InfoLevel, "purge expired indices of %T: %v", m, deleted)
+		}
+	}
+}
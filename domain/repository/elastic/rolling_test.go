@@ -0,0 +1,44 @@
+package elastic
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollingIndex(t *testing.T) {
+	at := time.Date(2020, 3, 9, 23, 0, 0, 0, time.UTC)
+
+	daily := &RollingPolicy{Field: "ctime", Period: RollingPeriod_DAILY}
+	assert.Equal(t, "audit-2020.03.09", rollingIndex("audit", daily, at))
+	assert.Equal(t, "audit-*", daily.readIndex("audit"))
+
+	monthly := &RollingPolicy{Field: "ctime", Period: RollingPeriod_MONTHLY, Alias: "audit_all"}
+	assert.Equal(t, "audit-2020.03", rollingIndex("audit", monthly, at))
+	assert.Equal(t, "audit_all", monthly.readIndex("audit"))
+}
+
+func TestExpiredIndices(t *testing.T) {
+	now := time.Date(2020, 3, 10, 12, 0, 0, 0, time.UTC)
+	indices := []string{"audit-2020.03.01", "audit-2020.03.02", "audit-2020.03.03", "audit-2020.03.10", "audit-bak", "audit2-2020.01.01"}
+
+	daily := &RollingPolicy{Period: RollingPeriod_DAILY, Retention: 7 * 24 * time.Hour}
+	assert.Equal(t, []string{"audit-2020.03.01", "audit-2020.03.02"}, expiredIndices("audit", daily, indices, now))
+
+	monthly := &RollingPolicy{Period: RollingPeriod_MONTHLY, Retention: 30 * 24 * time.Hour}
+	assert.Equal(t, []string{"audit-2020.01"}, expiredIndices("audit", monthly, []string{"audit-2020.01", "audit-2020.02", "audit-2020.03"}, now))
+}
+
+func TestBuildRollingTemplateBody(t *testing.T) {
+	mapping := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"ctime": map[string]interface{}{"type": "date"},
+		},
+	}
+	p := &RollingPolicy{Period: RollingPeriod_DAILY, Alias: "audit_all"}
+
+	body, _ := json.Marshal(buildRollingTemplateBody("audit", "", mapping, p))
+	assert.Equal(t, `{"aliases":{"audit_all":{}},"index_patterns":["audit-*"],"mappings":{"properties":{"ctime":{"type":"date"}}}}`, string(body))
+}
@@ -0,0 +1,205 @@
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v6/esapi"
+	"github.com/micro/go-micro/v2/logger"
+	"github.com/xxxmicro/base/domain/model"
+)
+
+type ScrollQuery struct {
+	Filters   map[string]interface{} `json:"filters"`   // 筛选条件
+	Sort      []*model.SortSpec      `json:"sort"`      // 排序，为空时按 _doc 顺序遍历
+	Size      int                    `json:"size"`      // 每批数据量
+	KeepAlive time.Duration          `json:"keepAlive"` // scroll 上下文保持时间
+}
+
+type scrollHit struct {
+	ID     string          `json:"_id"`
+	Source json.RawMessage `json:"_source"`
+}
+
+// Scroll 使用 scroll API 遍历全部筛选结果
+// 每批数据解码到 resultPtr 指向的切片后回调 fn，fn 返回错误时终止遍历
+func (r *BaseRepository) Scroll(c context.Context, m model.Model, query *ScrollQuery, resultPtr interface{}, fn func() error) (err error) {
+	index, _, err := getModelInfo(m)
+	if err != nil {
+		return
+	}
+
+	search, keepAlive, err := buildScrollSearch(query)
+	if err != nil {
+		return
+	}
+
+	// 整个遍历一个 span，耗时包含回调的处理时间
+	count := 0
+	sp := startSpan(c, "Scroll", index)
+	sp.SetStatement(search["query"], search["sort"])
+	defer func() {
+		sp.SetCount(count)
+		sp.Finish(err)
+	}()
+
+	err = r.scroll(c, readIndex(m, index), search, keepAlive, func(hits []scrollHit) error {
+		count += len(hits)
+		if err := decodeScrollHits(hits, resultPtr); err != nil {
+			return err
+		}
+		return fn()
+	})
+	return
+}
+
+// Export 将全部筛选结果以每行一个 JSON 文档的格式写入 w，返回导出的文档数
+func (r *BaseRepository) Export(c context.Context, m model.Model, query *ScrollQuery, w io.Writer) (count int, err error) {
+	index, _, err := getModelInfo(m)
+	if err != nil {
+		return
+	}
+
+	search, keepAlive, err := buildScrollSearch(query)
+	if err != nil {
+		return
+	}
+
+	sp := startSpan(c, "Export", index)
+	sp.SetStatement(search["query"], search["sort"])
+	defer func() {
+		sp.SetCount(count)
+		sp.Finish(err)
+	}()
+
+	err = r.scroll(c, readIndex(m, index), search, keepAlive, func(hits []scrollHit) error {
+		for _, hit := range hits {
+			if _, err := w.Write(hit.Source); err != nil {
+				return err
+			}
+			if _, err := w.Write([]byte{'\n'}); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return
+}
+
+func buildScrollSearch(query *ScrollQuery) (search map[string]interface{}, keepAlive time.Duration, err error) {
+	size := query.Size
+	if size > 10000 {
+		size = 10000
+	} else if size <= 0 {
+		size = 500
+	}
+
+	keepAlive = query.KeepAlive
+	if keepAlive <= 0 {
+		keepAlive = time.Minute
+	}
+
+	filters, err := buildQuery(query.Filters)
+	if err != nil {
+		return
+	}
+
+	search = map[string]interface{}{
+		"query": filters,
+		"size":  size,
+	}
+
+	if sort := buildSort(query.Sort); sort != nil {
+		search["sort"] = sort
+	} else {
+		search["sort"] = []string{"_doc"}
+	}
+	return
+}
+
+func decodeScrollHits(hits []scrollHit, resultPtr interface{}) error {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('[')
+	for i, hit := range hits {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(hit.Source)
+	}
+	buf.WriteByte(']')
+
+	return json.Unmarshal(buf.Bytes(), resultPtr)
+}
+
+// scroll 遍历查询结果，每批回调一次
+func (r *BaseRepository) scroll(c context.Context, index string, search map[string]interface{}, keepAlive time.Duration, fn func(hits []scrollHit) error) error {
+	jsonBody, err := json.Marshal(search)
+	if err != nil {
+		return err
+	}
+
+	var req esapi.Request = esapi.SearchRequest{
+		Index:  []string{index},
+		Body:   bytes.NewReader(jsonBody),
+		Scroll: keepAlive,
+	}
+
+	var scrollID string
+	defer func() {
+		if scrollID != "" {
+			r.clearScroll(scrollID)
+		}
+	}()
+
+	for {
+		res, err := r.do(c, req)
+		if err != nil {
+			return err
+		}
+
+		var respData struct {
+			ScrollID string `json:"_scroll_id"`
+			Hits     struct {
+				Hits []scrollHit `json:"hits"`
+			} `json:"hits"`
+		}
+		err = decodeError(res)
+		if err == nil {
+			err = json.NewDecoder(res.Body).Decode(&respData)
+		}
+		res.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		scrollID = respData.ScrollID
+		if len(respData.Hits.Hits) == 0 {
+			return nil
+		}
+
+		if err = fn(respData.Hits.Hits); err != nil {
+			return err
+		}
+
+		req = esapi.ScrollRequest{
+			ScrollID: scrollID,
+			Scroll:   keepAlive,
+		}
+	}
+}
+
+func (r *BaseRepository) clearScroll(scrollID string) {
+	req := esapi.ClearScrollRequest{
+		ScrollID: []string{scrollID},
+	}
+	res, err := req.Do(context.Background(), r.DB)
+	if err != nil {
+		r.logger().Logf(logger.WarnLevel, "clear scroll failed: %v", err)
+		return
+	}
+	res.Body.Close()
+}
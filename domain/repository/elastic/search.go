@@ -0,0 +1,285 @@
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/elastic/go-elasticsearch/v6/esapi"
+	"github.com/xxxmicro/base/domain/model"
+	breflect "github.com/xxxmicro/base/reflect"
+)
+
+type FacetType string
+
+const (
+	FacetType_TERMS          FacetType = "TERMS"          // 按值分组
+	FacetType_RANGE          FacetType = "RANGE"          // 按区间分组
+	FacetType_HISTOGRAM      FacetType = "HISTOGRAM"      // 按固定间隔分组
+	FacetType_DATE_HISTOGRAM FacetType = "DATE_HISTOGRAM" // 按时间间隔分组
+)
+
+type FacetRange struct {
+	Key  string      `json:"key,omitempty"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+// Facet 分面统计定义，对应一个 ES 聚合
+type Facet struct {
+	Name     string        `json:"name"`     // 结果中的分面名
+	Field    string        `json:"field"`    // 统计字段，字符串字段需使用 keyword 字段
+	Type     FacetType     `json:"type"`     // 分面类型
+	Size     int           `json:"size"`     // TERMS 返回的桶数量
+	Ranges   []*FacetRange `json:"ranges"`   // RANGE 的区间
+	Interval interface{}   `json:"interval"` // HISTOGRAM/DATE_HISTOGRAM 的间隔
+}
+
+type SearchQuery struct {
+	Filters   map[string]interface{} `json:"filters"`
+	PageNo    int                    `json:"pageNo"`
+	PageSize  int                    `json:"pageSize"`
+	Sort      []*model.SortSpec      `json:"sort"`      // 为空时按相关度排序
+	Highlight []string               `json:"highlight"` // 高亮字段
+	PreTag    string                 `json:"preTag"`    // 高亮前缀，默认 <em>
+	PostTag   string                 `json:"postTag"`   // 高亮后缀，默认 </em>
+	MinScore  float64                `json:"minScore"`  // 最低相关度
+	Facets    []*Facet               `json:"facets"`
+}
+
+type SearchHit struct {
+	ID        string              `json:"id"`
+	Score     float64             `json:"score"`
+	Highlight map[string][]string `json:"highlight,omitempty"`
+}
+
+type FacetBucket struct {
+	Key         interface{} `json:"key"`
+	KeyAsString string      `json:"keyAsString,omitempty"`
+	From        interface{} `json:"from,omitempty"`
+	To          interface{} `json:"to,omitempty"`
+	DocCount    int         `json:"docCount"`
+}
+
+type SearchResult struct {
+	Total    int                       `json:"total"`
+	MaxScore float64                   `json:"maxScore"`
+	Hits     []*SearchHit              `json:"hits"`   // 与结果列表一一对应
+	Facets   map[string][]*FacetBucket `json:"facets"` // 分面名 -> 桶
+}
+
+type searchResponse struct {
+	Hits struct {
+		Total    hitsTotal `json:"total"`
+		MaxScore *float64  `json:"max_score"`
+		Hits     []struct {
+			ID        string              `json:"_id"`
+			Score     *float64            `json:"_score"`
+			Source    interface{}         `json:"_source"`
+			Highlight map[string][]string `json:"highlight"`
+		} `json:"hits"`
+	} `json:"hits"`
+	Aggregations map[string]struct {
+		Buckets []struct {
+			Key         interface{} `json:"key"`
+			KeyAsString string      `json:"key_as_string"`
+			From        interface{} `json:"from"`
+			To          interface{} `json:"to"`
+			DocCount    int         `json:"doc_count"`
+		} `json:"buckets"`
+	} `json:"aggregations"`
+}
+
+// Search 全文检索，结果数据写入 resultPtr，相关度、高亮和分面统计在 SearchResult 中返回
+func (r *BaseRepository) Search(c context.Context, m model.Model, query *SearchQuery, resultPtr interface{}) (result *SearchResult, err error) {
+	index, _, err := getModelInfo(m)
+	if err != nil {
+		return
+	}
+
+	search, err := buildSearch(query)
+	if err != nil {
+		return
+	}
+
+	sp := startSpan(c, "Search", index)
+	sp.SetStatement(search["query"], search["sort"])
+	defer func() {
+		if result != nil {
+			sp.SetCount(len(result.Hits))
+		}
+		sp.Finish(err)
+	}()
+
+	jsonBody, err := json.Marshal(search)
+	if err != nil {
+		return
+	}
+
+	req := esapi.SearchRequest{
+		Index:        []string{readIndex(m, index)},
+		DocumentType: r.DB.SearchTypes(index),
+		Body:         bytes.NewReader(jsonBody),
+	}
+	res, err := r.do(c, req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	if err = decodeError(res); err != nil {
+		return
+	}
+
+	var respData searchResponse
+	if err = json.NewDecoder(res.Body).Decode(&respData); err != nil {
+		return
+	}
+
+	result = &SearchResult{
+		Total:  int(respData.Hits.Total),
+		Hits:   make([]*SearchHit, 0, len(respData.Hits.Hits)),
+		Facets: make(map[string][]*FacetBucket),
+	}
+	if respData.Hits.MaxScore != nil {
+		result.MaxScore = *respData.Hits.MaxScore
+	}
+
+	sources := make([]interface{}, 0, len(respData.Hits.Hits))
+	for _, v := range respData.Hits.Hits {
+		hit := &SearchHit{
+			ID:        v.ID,
+			Highlight: v.Highlight,
+		}
+		if v.Score != nil {
+			hit.Score = *v.Score
+		}
+		result.Hits = append(result.Hits, hit)
+		sources = append(sources, v.Source)
+	}
+
+	for name, agg := range respData.Aggregations {
+		buckets := make([]*FacetBucket, 0, len(agg.Buckets))
+		for _, b := range agg.Buckets {
+			buckets = append(buckets, &FacetBucket{
+				Key:         b.Key,
+				KeyAsString: b.KeyAsString,
+				From:        b.From,
+				To:          b.To,
+				DocCount:    b.DocCount,
+			})
+		}
+		result.Facets[name] = buckets
+	}
+
+	err = breflect.MapSlice2StructSlice(sources, resultPtr)
+	return
+}
+
+func buildSearch(query *SearchQuery) (map[string]interface{}, error) {
+	pageSize := query.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	pageNo := query.PageNo
+	if pageNo <= 0 {
+		pageNo = 1
+	}
+
+	filters, err := buildQuery(query.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	search := map[string]interface{}{
+		"query": filters,
+		"from":  (pageNo - 1) * pageSize,
+		"size":  pageSize,
+		// 7.x 默认只统计到 10000
+		"track_total_hits": true,
+	}
+
+	if sort := buildSort(query.Sort); sort != nil {
+		search["sort"] = sort
+		// 指定排序时 ES 默认不计算相关度
+		search["track_scores"] = true
+	}
+
+	if query.MinScore > 0 {
+		search["min_score"] = query.MinScore
+	}
+
+	if len(query.Highlight) > 0 {
+		search["highlight"] = buildHighlight(query.Highlight, query.PreTag, query.PostTag)
+	}
+
+	if len(query.Facets) > 0 {
+		search["aggs"] = buildAggs(query.Facets)
+	}
+
+	return search, nil
+}
+
+func buildHighlight(fields []string, preTag string, postTag string) map[string]interface{} {
+	highlightFields := make(map[string]interface{})
+	for _, field := range fields {
+		highlightFields[field] = map[string]interface{}{}
+	}
+
+	highlight := map[string]interface{}{
+		"fields": highlightFields,
+	}
+	if preTag != "" && postTag != "" {
+		highlight["pre_tags"] = []string{preTag}
+		highlight["post_tags"] = []string{postTag}
+	}
+	return highlight
+}
+
+func buildAggs(facets []*Facet) map[string]interface{} {
+	aggs := make(map[string]interface{})
+
+	for _, facet := range facets {
+		name := facet.Name
+		if name == "" {
+			name = facet.Field
+		}
+
+		switch facet.Type {
+		case FacetType_RANGE:
+			aggs[name] = map[string]interface{}{
+				"range": map[string]interface{}{
+					"field":  facet.Field,
+					"ranges": facet.Ranges,
+				},
+			}
+		case FacetType_HISTOGRAM:
+			aggs[name] = map[string]interface{}{
+				"histogram": map[string]interface{}{
+					"field":    facet.Field,
+					"interval": facet.Interval,
+				},
+			}
+		case FacetType_DATE_HISTOGRAM:
+			aggs[name] = map[string]interface{}{
+				"date_histogram": map[string]interface{}{
+					"field":    facet.Field,
+					"interval": facet.Interval,
+				},
+			}
+		default: // FacetType_TERMS
+			size := facet.Size
+			if size <= 0 {
+				size = 10
+			}
+			aggs[name] = map[string]interface{}{
+				"terms": map[string]interface{}{
+					"field": facet.Field,
+					"size":  size,
+				},
+			}
+		}
+	}
+
+	return aggs
+}
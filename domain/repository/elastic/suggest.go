@@ -0,0 +1,291 @@
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v6/esapi"
+	"github.com/xxxmicro/base/domain/model"
+)
+
+type SuggestType string
+
+const (
+	SuggestType_COMPLETION         SuggestType = "completion"         // completion suggester，前缀匹配，性能最好
+	SuggestType_SEARCH_AS_YOU_TYPE SuggestType = "search_as_you_type" // 边输入边搜索，支持词中间匹配，7.2 以下退化为 index_prefixes
+)
+
+// 在字段上声明联想类型，例如:
+//
+//	Name string `json:"name" suggest:"completion"`
+//	Title string `json:"title" suggest:"search_as_you_type"`
+//
+// 创建索引时自动生成对应的 mapping
+const suggestTag = "suggest"
+
+type Suggestion struct {
+	ID    string  `json:"id"`
+	Text  string  `json:"text"`
+	Score float64 `json:"score"`
+}
+
+// suggestFields 解析模型中声明了联想类型的字段，json 字段名 -> 联想类型
+func suggestFields(m model.Model) (map[string]SuggestType, error) {
+	t := reflect.TypeOf(m)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, errors.New("not struct param")
+	}
+
+	fields := make(map[string]SuggestType)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.TrimSpace(field.Tag.Get(suggestTag))
+		if tag == "" {
+			continue
+		}
+
+		name := strings.TrimSpace(strings.Split(field.Tag.Get("json"), ",")[0])
+		if name == "" || name == "-" {
+			continue
+		}
+
+		switch SuggestType(tag) {
+		case SuggestType_COMPLETION, SuggestType_SEARCH_AS_YOU_TYPE:
+			fields[name] = SuggestType(tag)
+		default:
+			return nil, errors.New(fmt.Sprintf("ERR_ES_UNKNOWN_SUGGEST_TYPE %s", tag))
+		}
+	}
+	return fields, nil
+}
+
+// buildSuggestProperties 生成联想字段的 mapping，searchAsYouType 表示集群支持 search_as_you_type 类型
+func buildSuggestProperties(fields map[string]SuggestType, searchAsYouType bool) map[string]interface{} {
+	properties := make(map[string]interface{})
+	for name, suggestType := range fields {
+		switch suggestType {
+		case SuggestType_COMPLETION:
+			properties[name] = map[string]interface{}{
+				"type": "completion",
+			}
+		case SuggestType_SEARCH_AS_YOU_TYPE:
+			if searchAsYouType {
+				properties[name] = map[string]interface{}{
+					"type": "search_as_you_type",
+				}
+			} else {
+				properties[name] = map[string]interface{}{
+					"type":           "text",
+					"index_prefixes": map[string]interface{}{},
+				}
+			}
+		}
+	}
+	return properties
+}
+
+// mergeProperties 将生成的字段加入 mapping，mapping 中已显式声明的字段优先
+func mergeProperties(mapping map[string]interface{}, properties map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{})
+	for k, v := range mapping {
+		merged[k] = v
+	}
+
+	mergedProperties := make(map[string]interface{})
+	for k, v := range properties {
+		mergedProperties[k] = v
+	}
+	if explicit, ok := mapping["properties"].(map[string]interface{}); ok {
+		for k, v := range explicit {
+			mergedProperties[k] = v
+		}
+	}
+
+	if len(mergedProperties) > 0 {
+		merged["properties"] = mergedProperties
+	}
+	return merged
+}
+
+// modelMapping 模型的 mapping，由 Mapped 声明的 mapping、suggest 标签生成的字段和游标的 tiebreaker 字段合并而成
+func (r *BaseRepository) modelMapping(m model.Model) (map[string]interface{}, error) {
+	var mapping map[string]interface{}
+	if mapped, ok := m.(Mapped); ok {
+		mapping = mapped.Mapping()
+	}
+
+	fields, err := suggestFields(m)
+	if err != nil {
+		return nil, err
+	}
+
+	properties := buildSuggestProperties(fields, r.DB.AtLeast(7, 2))
+	if tiebreaker := r.tiebreaker(); tiebreaker != "_id" {
+		properties[tiebreaker] = map[string]interface{}{"type": "keyword"}
+	}
+	return mergeProperties(mapping, properties), nil
+}
+
+// Suggest 根据前缀返回联想结果，按相关度排序并去重
+// field 需通过 suggest 标签声明联想类型
+func (r *BaseRepository) Suggest(c context.Context, m model.Model, field string, prefix string, size int) (suggestions []*Suggestion, err error) {
+	index, _, err := getModelInfo(m)
+	if err != nil {
+		return
+	}
+
+	fields, err := suggestFields(m)
+	if err != nil {
+		return
+	}
+	suggestType, ok := fields[field]
+	if !ok {
+		err = errors.New(fmt.Sprintf("ERR_ES_NOT_SUGGEST_FIELD %s", field))
+		return
+	}
+
+	if size <= 0 {
+		size = 10
+	} else if size > 100 {
+		size = 100
+	}
+
+	search := buildSuggestSearch(field, suggestType, prefix, size, r.DB.AtLeast(7, 2))
+
+	sp := startSpan(c, "Suggest", index)
+	sp.SetStatement(search, nil)
+	defer func() {
+		sp.SetCount(len(suggestions))
+		sp.Finish(err)
+	}()
+	jsonBody, err := json.Marshal(search)
+	if err != nil {
+		return
+	}
+
+	req := esapi.SearchRequest{
+		Index:        []string{readIndex(m, index)},
+		DocumentType: r.DB.SearchTypes(index),
+		Body:         bytes.NewReader(jsonBody),
+	}
+	res, err := r.do(c, req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	if err = decodeError(res); err != nil {
+		return
+	}
+
+	var respData suggestResponse
+	if err = json.NewDecoder(res.Body).Decode(&respData); err != nil {
+		return
+	}
+
+	suggestions = respData.suggestions(field, size)
+	return
+}
+
+const suggestName = "suggestion"
+
+func buildSuggestSearch(field string, suggestType SuggestType, prefix string, size int, searchAsYouType bool) map[string]interface{} {
+	if suggestType == SuggestType_COMPLETION {
+		return map[string]interface{}{
+			"_source": false,
+			"suggest": map[string]interface{}{
+				suggestName: map[string]interface{}{
+					"prefix": prefix,
+					"completion": map[string]interface{}{
+						"field":           field,
+						"size":            size,
+						"skip_duplicates": true,
+					},
+				},
+			},
+		}
+	}
+
+	var query map[string]interface{}
+	if searchAsYouType {
+		query = map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":  prefix,
+				"type":   "bool_prefix",
+				"fields": []string{field, field + "._2gram", field + "._3gram"},
+			},
+		}
+	} else {
+		query = map[string]interface{}{
+			"match_phrase_prefix": map[string]interface{}{
+				field: prefix,
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"_source": []string{field},
+		// 多取一些，去重后再截断
+		"size":  size * 2,
+		"query": query,
+	}
+}
+
+type suggestResponse struct {
+	Hits struct {
+		Hits []struct {
+			ID     string                 `json:"_id"`
+			Score  *float64               `json:"_score"`
+			Source map[string]interface{} `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+	Suggest map[string][]struct {
+		Options []struct {
+			ID    string  `json:"_id"`
+			Text  string  `json:"text"`
+			Score float64 `json:"_score"`
+		} `json:"options"`
+	} `json:"suggest"`
+}
+
+// suggestions 从 completion 结果或查询结果中提取联想词，按文本去重
+func (resp *suggestResponse) suggestions(field string, size int) []*Suggestion {
+	suggestions := make([]*Suggestion, 0, size)
+	seen := make(map[string]struct{})
+
+	add := func(id string, text string, score float64) {
+		if text == "" || len(suggestions) >= size {
+			return
+		}
+		if _, ok := seen[text]; ok {
+			return
+		}
+		seen[text] = struct{}{}
+		suggestions = append(suggestions, &Suggestion{ID: id, Text: text, Score: score})
+	}
+
+	for _, entry := range resp.Suggest[suggestName] {
+		for _, option := range entry.Options {
+			add(option.ID, option.Text, option.Score)
+		}
+	}
+
+	for _, hit := range resp.Hits.Hits {
+		text, _ := hit.Source[field].(string)
+		var score float64
+		if hit.Score != nil {
+			score = *hit.Score
+		}
+		add(hit.ID, text, score)
+	}
+
+	return suggestions
+}
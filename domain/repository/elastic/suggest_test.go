@@ -0,0 +1,62 @@
+package elastic
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type suggestProduct struct {
+	ID    string   `json:"id"`
+	Name  string   `json:"name,omitempty" suggest:"completion"`
+	Title string   `json:"title" suggest:"search_as_you_type"`
+	Tags  []string `json:"tags"`
+}
+
+func (p *suggestProduct) Unique() interface{} {
+	return map[string]interface{}{"id": p.ID}
+}
+
+func (p *suggestProduct) Mapping() map[string]interface{} {
+	return map[string]interface{}{
+		"properties": map[string]interface{}{
+			"tags": map[string]interface{}{"type": "keyword"},
+		},
+	}
+}
+
+func TestSuggestFields(t *testing.T) {
+	fields, err := suggestFields(&suggestProduct{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]SuggestType{"name": SuggestType_COMPLETION, "title": SuggestType_SEARCH_AS_YOU_TYPE}, fields)
+
+	mapping := mergeProperties((&suggestProduct{}).Mapping(), buildSuggestProperties(fields, true))
+	body, _ := json.Marshal(mapping)
+	assert.Equal(t, `{"properties":{"name":{"type":"completion"},"tags":{"type":"keyword"},"title":{"type":"search_as_you_type"}}}`, string(body))
+
+	// 7.2 以下退化为 index_prefixes
+	body, _ = json.Marshal(buildSuggestProperties(fields, false))
+	assert.Equal(t, `{"name":{"type":"completion"},"title":{"index_prefixes":{},"type":"text"}}`, string(body))
+}
+
+func TestBuildSuggestSearch(t *testing.T) {
+	body, _ := json.Marshal(buildSuggestSearch("name", SuggestType_COMPLETION, "iph", 5, true))
+	assert.Equal(t, `{"_source":false,"suggest":{"suggestion":{"completion":{"field":"name","size":5,"skip_duplicates":true},"prefix":"iph"}}}`, string(body))
+
+	body, _ = json.Marshal(buildSuggestSearch("title", SuggestType_SEARCH_AS_YOU_TYPE, "iph", 5, true))
+	assert.Equal(t, `{"_source":["title"],"query":{"multi_match":{"fields":["title","title._2gram","title._3gram"],"query":"iph","type":"bool_prefix"}},"size":10}`, string(body))
+}
+
+func TestSuggestResponse(t *testing.T) {
+	var resp suggestResponse
+	err := json.Unmarshal([]byte(`{"hits":{"hits":[
+		{"_id":"1","_score":2.5,"_source":{"title":"iPhone 11"}},
+		{"_id":"2","_score":2.1,"_source":{"title":"iPhone 11"}},
+		{"_id":"3","_score":1.2,"_source":{"title":"iPhone 12"}}]}}`), &resp)
+	assert.NoError(t, err)
+
+	suggestions := resp.suggestions("title", 5)
+	assert.Equal(t, 2, len(suggestions))
+	assert.Equal(t, "iPhone 12", suggestions[1].Text)
+}
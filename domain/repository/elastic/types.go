@@ -0,0 +1,12 @@
+package elastic
+
+import (
+	"github.com/xxxmicro/base/domain/model"
+)
+
+// Mapped 提供索引 mapping 的模型，用于创建和重建索引
+// Mapping 返回文档类型下的 mapping，例如 {"properties": {...}}
+type Mapped interface {
+	Mapping() map[string]interface{}
+	model.Model
+}
@@ -34,10 +34,15 @@ func getModelInfoAndCheckID(m model.Model) (index string, idRefValue reflect.Val
 	return
 }
 
-func buildSort(sortSpecs []*model.SortSpec) []map[string]string {
-	var sorts []map[string]string
+func buildSort(sortSpecs []*model.SortSpec) []interface{} {
+	var sorts []interface{}
 
 	for _, spec := range sortSpecs {
+		if spec.Near != nil {
+			sorts = append(sorts, buildGeoSort(spec))
+			continue
+		}
+
 		// todo 字符串排序时要加 ".keyword" 看怎么判断
 		sort := map[string]string{
 			spec.Property: string(spec.Type),
@@ -48,11 +53,15 @@ func buildSort(sortSpecs []*model.SortSpec) []map[string]string {
 	return sorts
 }
 
-func buildQuery(filters map[string]interface{}) map[string]map[string]interface{} {
+// buildQuery 筛选条件转换为 bool 查询，条件值格式错误时返回错误，不发送请求
+func buildQuery(filters map[string]interface{}) (map[string]map[string]interface{}, error) {
 	var musts []interface{}
 
 	for column, v := range filters {
-		must := buildMust(column, v)
+		must, err := buildMust(column, v)
+		if err != nil {
+			return nil, err
+		}
 		musts = append(musts, must)
 	}
 
@@ -62,10 +71,10 @@ func buildQuery(filters map[string]interface{}) map[string]map[string]interface{
 		},
 	}
 
-	return query
+	return query, nil
 }
 
-func buildMust(column string, value interface{}) interface{} {
+func buildMust(column string, value interface{}) (interface{}, error) {
 	switch value.(type) {
 	case string:
 		must := map[string]map[string]map[string]interface{}{
@@ -75,7 +84,7 @@ func buildMust(column string, value interface{}) interface{} {
 				},
 			},
 		}
-		return must
+		return must, nil
 	case map[string]interface{}:
 		return buildRange(column, value.(map[string]interface{}))
 	default:
@@ -86,11 +95,11 @@ func buildMust(column string, value interface{}) interface{} {
 				},
 			},
 		}
-		return must
+		return must, nil
 	}
 }
 
-func buildRange(column string, filters map[string]interface{}) interface{} {
+func buildRange(column string, filters map[string]interface{}) (interface{}, error) {
 	filter := make(map[string]interface{})
 
 	// 每个条件的话， 支持 gt gte lt lte eq  like ne in 这几个即可
@@ -113,7 +122,7 @@ func buildRange(column string, filters map[string]interface{}) interface{} {
 					},
 				},
 			}
-			return must
+			return must, nil
 		case model.FilterType_ES_NE:
 			switch v.(type) {
 			case string:
@@ -128,7 +137,7 @@ func buildRange(column string, filters map[string]interface{}) interface{} {
 						}},
 					},
 				}
-				return must
+				return must, nil
 			default:
 				must := map[string]map[string][]map[string]map[string]map[string]interface{}{
 					"bool": {
@@ -141,7 +150,7 @@ func buildRange(column string, filters map[string]interface{}) interface{} {
 						}},
 					},
 				}
-				return must
+				return must, nil
 			}
 		case model.FilterType_ES_IN:
 			must := map[string]map[string]interface{}{
@@ -149,7 +158,11 @@ func buildRange(column string, filters map[string]interface{}) interface{} {
 					column: v,
 				},
 			}
-			return must
+			return must, nil
+		case model.FilterType_ANY, model.FilterType_ALL, model.FilterType_SIZE, model.FilterType_ELEM_MATCH:
+			return buildArrayQuery(column, filterType, v)
+		case model.FilterType_GEO_NEAR, model.FilterType_GEO_WITHIN_BOX, model.FilterType_GEO_WITHIN_POLYGON:
+			return buildGeoQuery(column, filterType, v)
 		case model.FilterType_ES_LIKE:
 			must := map[string]map[string]map[string]interface{}{
 				"match_phrase": {
@@ -158,7 +171,7 @@ func buildRange(column string, filters map[string]interface{}) interface{} {
 					},
 				},
 			}
-			return must
+			return must, nil
 		default:
 
 		}
@@ -169,5 +182,5 @@ func buildRange(column string, filters map[string]interface{}) interface{} {
 			column: filter,
 		}}
 
-	return rangeFilter
+	return rangeFilter, nil
 }
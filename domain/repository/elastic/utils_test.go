@@ -3,6 +3,7 @@ package elastic
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/stretchr/testify/assert"
 	"github.com/xxxmicro/base/domain/model"
 	"testing"
 	"time"
@@ -29,7 +30,8 @@ func TestGetQuery(t *testing.T) {
 		PageNo:   1,
 	}
 
-	queryMap := buildQuery(pageQuery.Filters)
+	queryMap, err := buildQuery(pageQuery.Filters)
+	assert.NoError(t, err)
 	str, err := json.Marshal(queryMap)
 
 	if err != nil {
@@ -55,7 +57,8 @@ func TestBuildPageSearch(t *testing.T) {
 		}},
 	}
 
-	searchMap := buildPageSearch(pageQuery)
+	searchMap, err := buildPageSearch(pageQuery)
+	assert.NoError(t, err)
 	str, err := json.Marshal(searchMap)
 
 	if err != nil {
@@ -83,7 +86,8 @@ func TestBuildCursorSearch(t *testing.T) {
 		Size: 10,
 	}
 
-	searchMap := buildCursorSearch(cursorQuery)
+	searchMap, reverse, err := buildCursorSearch(cursorQuery, cursorQuery.Size, "_id")
+	assert.NoError(t, err)
 	str, err := json.Marshal(searchMap)
 
 	if err != nil {
@@ -91,4 +95,151 @@ func TestBuildCursorSearch(t *testing.T) {
 	}
 	fmt.Println("map to json  :   ", string(str))
 
-}
\ No newline at end of file
+	// 不能修改调用方的筛选条件
+	assert.Equal(t, 1, len(cursorQuery.Filters))
+	assert.True(t, reverse)
+}
+
+func TestBuildCursorSearchAfter(t *testing.T) {
+	cursorQuery := &model.CursorQuery{
+		Filters: map[string]interface{}{},
+		CursorSort: &model.SortSpec{
+			Property: "ctime",
+			Type:     model.SortType_DSC,
+		},
+		Cursor:    []interface{}{1634968309000, "617268cf31cc5f56ec21c32d"},
+		Size:      10,
+		Direction: 1,
+	}
+
+	searchMap, reverse, err := buildCursorSearch(cursorQuery, cursorQuery.Size+1, "id")
+	assert.NoError(t, err)
+	assert.False(t, reverse)
+	assert.Equal(t, cursorQuery.Cursor, searchMap["search_after"])
+	assert.Equal(t, []map[string]string{{"ctime": "desc"}, {"id": "desc"}}, searchMap["sort"])
+	assert.Equal(t, 11, searchMap["size"])
+}
+
+func TestBuildScrollSearch(t *testing.T) {
+	search, keepAlive, err := buildScrollSearch(&ScrollQuery{
+		Filters: map[string]interface{}{"name": "吕布"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, keepAlive)
+	assert.Equal(t, 500, search["size"])
+	assert.Equal(t, []string{"_doc"}, search["sort"])
+}
+func TestBuildSearch(t *testing.T) {
+	searchQuery := &SearchQuery{
+		Filters: map[string]interface{}{
+			"name": map[string]interface{}{
+				"LIKE": "吕",
+			},
+		},
+		PageNo:    2,
+		PageSize:  10,
+		Highlight: []string{"name"},
+		MinScore:  0.5,
+		Facets: []*Facet{
+			{Name: "ages", Field: "age", Type: FacetType_RANGE, Ranges: []*FacetRange{{To: 20}, {From: 20}}},
+			{Field: "name.keyword", Size: 5},
+		},
+	}
+
+	searchMap, err := buildSearch(searchQuery)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, searchMap["from"])
+	assert.Equal(t, 0.5, searchMap["min_score"])
+	assert.Nil(t, searchMap["track_scores"])
+
+	aggs := searchMap["aggs"].(map[string]interface{})
+	assert.Contains(t, aggs, "ages")
+	assert.Contains(t, aggs, "name.keyword")
+
+	str, err := json.Marshal(searchMap)
+	assert.NoError(t, err)
+	fmt.Println("map to json  :   ", string(str))
+}
+
+func TestHitsTotal(t *testing.T) {
+	var respData HitsResult
+
+	err := json.Unmarshal([]byte(`{"hits":{"total":3,"hits":[]}}`), &respData)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, int(respData.Hits.Total))
+
+	err = json.Unmarshal([]byte(`{"hits":{"total":{"value":12,"relation":"eq"},"hits":[]}}`), &respData)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, int(respData.Hits.Total))
+}
+
+func TestBuildGeoQuery(t *testing.T) {
+	query, err := buildQuery(map[string]interface{}{
+		"location": map[string]interface{}{
+			"GEO_NEAR": map[string]interface{}{"point": []interface{}{116.4, 39.9}, "maxDistance": 5000},
+		},
+	})
+	assert.NoError(t, err)
+	body, _ := json.Marshal(query)
+	assert.Equal(t, `{"bool":{"must":[{"bool":{"filter":[{"geo_distance":{"distance":"5000m","location":{"lat":39.9,"lon":116.4}}}]}}]}}`, string(body))
+
+	// 参数错误在发送请求前返回
+	_, err = buildQuery(map[string]interface{}{
+		"location": map[string]interface{}{
+			"GEO_NEAR": map[string]interface{}{"point": []interface{}{116.4}},
+		},
+	})
+	assert.Equal(t, model.ErrMalformedGeo, err)
+
+	box, err := buildGeoQuery("location", model.FilterType_GEO_WITHIN_BOX, map[string]interface{}{
+		"topLeft":     []interface{}{116.3, 40.0},
+		"bottomRight": []interface{}{116.5, 39.8},
+	})
+	assert.NoError(t, err)
+	body, _ = json.Marshal(box)
+	assert.Equal(t, `{"geo_bounding_box":{"location":{"bottom_right":{"lat":39.8,"lon":116.5},"top_left":{"lat":40,"lon":116.3}}}}`, string(body))
+
+	sorts := buildSort([]*model.SortSpec{{Property: "location", Type: model.SortType_ASC, Near: &model.GeoPoint{Lng: 116.4, Lat: 39.9}}})
+	body, _ = json.Marshal(sorts)
+	assert.Equal(t, `[{"_geo_distance":{"location":{"lat":39.9,"lon":116.4},"order":"asc","unit":"m"}}]`, string(body))
+}
+
+func TestBuildArrayQuery(t *testing.T) {
+	query, err := buildArrayQuery("roles", model.FilterType_ALL, []interface{}{"admin", "ops"})
+	assert.NoError(t, err)
+	body, _ := json.Marshal(query)
+	assert.Equal(t, `{"bool":{"filter":[{"term":{"roles":"admin"}},{"term":{"roles":"ops"}}]}}`, string(body))
+
+	query, err = buildArrayQuery("roles", model.FilterType_ALL, []string{"admin"})
+	assert.NoError(t, err)
+	body, _ = json.Marshal(query)
+	assert.Equal(t, `{"bool":{"filter":[{"term":{"roles":"admin"}}]}}`, string(body))
+
+	// 空数组不匹配任何文档，不能生成空的 bool.filter
+	query, err = buildArrayQuery("roles", model.FilterType_ALL, []string{})
+	assert.NoError(t, err)
+	body, _ = json.Marshal(query)
+	assert.Equal(t, `{"match_none":{}}`, string(body))
+
+	query, err = buildArrayQuery("roles", model.FilterType_SIZE, 2)
+	assert.NoError(t, err)
+	body, _ = json.Marshal(query)
+	assert.Equal(t, `{"script":{"script":{"params":{"field":"roles","size":2},"source":"doc[params.field].size() == params.size"}}}`, string(body))
+
+	query, err = buildArrayQuery("items", model.FilterType_ELEM_MATCH, map[string]interface{}{
+		"qty": map[string]interface{}{"GTE_FILTER": 2},
+	})
+	assert.NoError(t, err)
+	body, _ = json.Marshal(query)
+	assert.Equal(t, `{"nested":{"path":"items","query":{"bool":{"must":[{"range":{"items.qty":{"gte":2}}}]}}}}`, string(body))
+
+	// 格式错误的值不能生成匹配全部文档的空条件
+	_, err = buildArrayQuery("roles", model.FilterType_ALL, "admin")
+	assert.EqualError(t, err, "ERR_MALFORMED_PARAMETERS")
+	_, err = buildArrayQuery("items", model.FilterType_ELEM_MATCH, []interface{}{"qty"})
+	assert.EqualError(t, err, "ERR_MALFORMED_PARAMETERS")
+	_, err = buildQuery(map[string]interface{}{
+		"roles": map[string]interface{}{"ALL": 1},
+	})
+	assert.EqualError(t, err, "ERR_MALFORMED_PARAMETERS")
+}
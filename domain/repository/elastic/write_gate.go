@@ -0,0 +1,71 @@
+package elastic
+
+import (
+	"sync"
+)
+
+// writeGate 每个索引一个写闸门
+// 普通写操作持有读锁，重建索引切换别名时持有写锁，短暂阻塞写入
+// capturing 为 true 时记录经由本仓库写入的文档ID，供重建索引后回放
+type writeGate struct {
+	l         sync.RWMutex
+	idsLock   sync.Mutex
+	capturing bool
+	ids       map[string]struct{}
+}
+
+func (g *writeGate) record(id string) {
+	g.idsLock.Lock()
+	defer g.idsLock.Unlock()
+	if !g.capturing {
+		return
+	}
+	g.ids[id] = struct{}{}
+}
+
+func (g *writeGate) drain() []string {
+	g.idsLock.Lock()
+	defer g.idsLock.Unlock()
+	ids := make([]string, 0, len(g.ids))
+	for id := range g.ids {
+		ids = append(ids, id)
+	}
+	g.ids = make(map[string]struct{})
+	return ids
+}
+
+// startCapture 等待进行中的写操作结束后开始记录
+func (g *writeGate) startCapture() {
+	g.l.Lock()
+	defer g.l.Unlock()
+	g.idsLock.Lock()
+	defer g.idsLock.Unlock()
+	g.capturing = true
+	g.ids = make(map[string]struct{})
+}
+
+func (g *writeGate) stopCapture() {
+	g.idsLock.Lock()
+	defer g.idsLock.Unlock()
+	g.capturing = false
+	g.ids = nil
+}
+
+type writeGates struct {
+	l sync.Mutex
+	m map[string]*writeGate
+}
+
+func (s *writeGates) get(index string) *writeGate {
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.m == nil {
+		s.m = make(map[string]*writeGate)
+	}
+	g, ok := s.m[index]
+	if !ok {
+		g = &writeGate{}
+		s.m[index] = g
+	}
+	return g
+}
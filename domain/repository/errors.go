@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrTimeout  = errors.New("ERR_DB_TIMEOUT")  // 超过 context 截止时间或服务端执行时间限制
+	ErrCanceled = errors.New("ERR_DB_CANCELED") // context 被取消
+)
+
+// ContextError 因 context 结束而失败的操作，Err 为 ErrTimeout 或 ErrCanceled
+// 可用 errors.Is(err, repository.ErrTimeout) 判断
+type ContextError struct {
+	Err   error
+	Cause error // 驱动返回的原始错误
+}
+
+func (e *ContextError) Error() string {
+	if e.Cause == nil {
+		return e.Err.Error()
+	}
+	return e.Err.Error() + ": " + e.Cause.Error()
+}
+
+func (e *ContextError) Unwrap() error {
+	return e.Err
+}
+
+// Is 使 errors.Is 按 context.DeadlineExceeded 和 context.Canceled 判断同样成立，
+// 不依赖本包的调用方(如 database/breaker)也能识别
+func (e *ContextError) Is(target error) bool {
+	switch target {
+	case context.DeadlineExceeded:
+		return e.Err == ErrTimeout
+	case context.Canceled:
+		return e.Err == ErrCanceled
+	}
+	return false
+}
+
+// CheckContext context 已结束时返回 ContextError，用于在发起请求前快速失败
+func CheckContext(c context.Context) error {
+	if c == nil {
+		return nil
+	}
+	return WrapContextError(c, c.Err())
+}
+
+// WrapContextError 操作失败且 context 已结束时将错误转换为 ContextError，其它错误原样返回
+func WrapContextError(c context.Context, err error) error {
+	if err == nil || c == nil {
+		return err
+	}
+
+	var ce *ContextError
+	if errors.As(err, &ce) {
+		return err
+	}
+
+	switch c.Err() {
+	case context.DeadlineExceeded:
+		return &ContextError{Err: ErrTimeout, Cause: err}
+	case context.Canceled:
+		return &ContextError{Err: ErrCanceled, Cause: err}
+	}
+	return err
+}
+
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrTimeout)
+}
+
+func IsCanceled(err error) bool {
+	return errors.Is(err, ErrCanceled)
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapContextError(t *testing.T) {
+	failure := errors.New("connection refused")
+	assert.Equal(t, failure, WrapContextError(context.Background(), failure))
+	assert.NoError(t, CheckContext(context.Background()))
+
+	c, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-c.Done()
+
+	err := WrapContextError(c, failure)
+	assert.True(t, IsTimeout(err))
+	assert.False(t, IsCanceled(err))
+	assert.Equal(t, "ERR_DB_TIMEOUT: connection refused", err.Error())
+	// 已转换过的错误不重复包装
+	assert.Equal(t, err, WrapContextError(c, err))
+	assert.True(t, IsTimeout(CheckContext(c)))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.False(t, errors.Is(err, context.Canceled))
+
+	c, cancel = context.WithCancel(context.Background())
+	cancel()
+	assert.True(t, IsCanceled(CheckContext(c)))
+	assert.True(t, errors.Is(CheckContext(c), context.Canceled))
+}
@@ -0,0 +1,78 @@
+package gorm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/xxxmicro/base/domain/model"
+)
+
+// buildArrayCondition 数组条件，字段需为 JSON 类型
+// mysql 使用 JSON_CONTAINS/JSON_LENGTH，postgres 使用 jsonb 的 @> 和 jsonb_array_length
+func buildArrayCondition(dialect string, fieldName string, filterType model.FilterType, value interface{}) (string, []interface{}, error) {
+	var containsFormat, lengthFormat string
+	switch dialect {
+	case "mysql":
+		containsFormat = "JSON_CONTAINS(`%s`, ?)"
+		lengthFormat = "JSON_LENGTH(`%s`) = ?"
+	case "postgres":
+		containsFormat = `"%s" @> ?::jsonb`
+		lengthFormat = `jsonb_array_length("%s") = ?`
+	default:
+		return "", nil, ErrFilterDialect
+	}
+	contains := fmt.Sprintf(containsFormat, fieldName)
+
+	switch filterType {
+	case model.FilterType_ANY:
+		values, ok := value.([]interface{})
+		if !ok {
+			return "", nil, ErrFilterValueType
+		}
+		if len(values) == 0 {
+			return "1 = 0", nil, nil
+		}
+
+		conds := make([]string, 0, len(values))
+		args := make([]interface{}, 0, len(values))
+		for _, v := range values {
+			arg, err := json.Marshal([]interface{}{v})
+			if err != nil {
+				return "", nil, err
+			}
+			conds = append(conds, contains)
+			args = append(args, string(arg))
+		}
+		return "(" + strings.Join(conds, " OR ") + ")", args, nil
+	case model.FilterType_ALL:
+		values, ok := value.([]interface{})
+		if !ok {
+			return "", nil, ErrFilterValueType
+		}
+		arg, err := json.Marshal(values)
+		if err != nil {
+			return "", nil, err
+		}
+		return contains, []interface{}{string(arg)}, nil
+	case model.FilterType_SIZE:
+		return fmt.Sprintf(lengthFormat, fieldName), []interface{}{value}, nil
+	case model.FilterType_ELEM_MATCH:
+		// JSON 包含只能表达相等，子条件只支持直接给值
+		subFilters, ok := value.(map[string]interface{})
+		if !ok {
+			return "", nil, ErrFilterValueType
+		}
+		for _, v := range subFilters {
+			if _, ok := v.(map[string]interface{}); ok {
+				return "", nil, ErrFilterValueType
+			}
+		}
+		arg, err := json.Marshal([]interface{}{subFilters})
+		if err != nil {
+			return "", nil, err
+		}
+		return contains, []interface{}{string(arg)}, nil
+	}
+	return "", nil, ErrFilterValueType
+}
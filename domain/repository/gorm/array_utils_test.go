@@ -0,0 +1,34 @@
+package gorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xxxmicro/base/domain/model"
+)
+
+func TestBuildArrayCondition(t *testing.T) {
+	cond, args, err := buildArrayCondition("mysql", "roles", model.FilterType_ANY, []interface{}{"admin", "ops"})
+	assert.NoError(t, err)
+	assert.Equal(t, "(JSON_CONTAINS(`roles`, ?) OR JSON_CONTAINS(`roles`, ?))", cond)
+	assert.Equal(t, []interface{}{`["admin"]`, `["ops"]`}, args)
+
+	cond, args, err = buildArrayCondition("postgres", "roles", model.FilterType_ALL, []interface{}{"admin", "ops"})
+	assert.NoError(t, err)
+	assert.Equal(t, `"roles" @> ?::jsonb`, cond)
+	assert.Equal(t, []interface{}{`["admin","ops"]`}, args)
+
+	cond, _, err = buildArrayCondition("mysql", "roles", model.FilterType_SIZE, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "JSON_LENGTH(`roles`) = ?", cond)
+
+	_, args, err = buildArrayCondition("mysql", "items", model.FilterType_ELEM_MATCH, map[string]interface{}{"sku": "A001"})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{`[{"sku":"A001"}]`}, args)
+
+	_, _, err = buildArrayCondition("mysql", "items", model.FilterType_ELEM_MATCH, map[string]interface{}{"qty": map[string]interface{}{"GT": 1}})
+	assert.Error(t, err)
+
+	_, _, err = buildArrayCondition("sqlite3", "roles", model.FilterType_SIZE, 2)
+	assert.Equal(t, ErrFilterDialect, err)
+}
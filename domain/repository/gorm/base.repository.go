@@ -2,9 +2,11 @@ package gorm
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	_gorm "github.com/jinzhu/gorm"
+	"github.com/xxxmicro/base/database/gorm"
 	"github.com/xxxmicro/base/database/gorm/opentracing"
 	"github.com/xxxmicro/base/domain/model"
 	"github.com/xxxmicro/base/domain/repository"
@@ -19,42 +21,102 @@ func NewBaseRepository(db *_gorm.DB) repository.BaseRepository {
 	return &BaseRepository{db}
 }
 
-func (r *BaseRepository) Create(c context.Context, m model.Model) error {
+// NewNamedRepository 使用 registry 中名为 name 的数据源
+func NewNamedRepository(registry *gorm.Registry, name string) (repository.BaseRepository, error) {
+	db, err := registry.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewBaseRepository(db), nil
+}
+
+// run 带上 tracing span 执行 fn，context 结束导致的失败返回 repository.ContextError
+// jinzhu/gorm 的语句不接受 context，带截止时间的 context 通过 BeginTx 绑定到事务上，context 结束时事务回滚，
+// 使用 database/gorm 创建的连接时驱动同时中断执行中的语句。绑定事务多两次往返，只能取消的 context
+// (如 go-micro 的请求 context)不绑定，只在执行前检查；r.DB 已在事务中时同样只在执行前检查
+// 经过 database/gorm 按配置创建的熔断器，只有 IsFailure 判断为数据库故障的错误计入熔断
+func (r *BaseRepository) run(c context.Context, fn func(db *_gorm.DB) error) (err error) {
+	if err = repository.CheckContext(c); err != nil {
+		return
+	}
+
+	done, err := gorm.Breaker(r.DB).Allow()
+	if err != nil {
+		return
+	}
+	defer func() { done(IsFailure(err)) }()
 	db := opentracing.SetSpanToGorm(c, r.DB)
 
-	return db.Create(m).Error
+	_, inTx := db.CommonDB().(*sql.Tx)
+	if !hasDeadline(c) || inTx {
+		return repository.WrapContextError(c, fn(db))
+	}
+
+	tx := db.BeginTx(c, nil)
+	if tx.Error != nil {
+		return repository.WrapContextError(c, tx.Error)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return repository.WrapContextError(c, err)
+	}
+	return repository.WrapContextError(c, tx.Commit().Error)
 }
 
-func (r *BaseRepository) Upsert(c context.Context, m model.Model) (*repository.ChangeInfo, error) {
-	db := opentracing.SetSpanToGorm(c, r.DB)
+func hasDeadline(c context.Context) bool {
+	if c == nil {
+		return false
+	}
+	_, ok := c.Deadline()
+	return ok
+}
+
+func (r *BaseRepository) Create(c context.Context, m model.Model) error {
+	return r.run(c, func(db *_gorm.DB) error {
+		return db.Create(m).Error
+	})
+}
 
-	result := db.Save(m)
-	if result.Error != nil {
-		return nil, result.Error
+func (r *BaseRepository) Upsert(c context.Context, m model.Model) (*repository.ChangeInfo, error) {
+	var rowsAffected int64
+	err := r.run(c, func(db *_gorm.DB) error {
+		result := db.Save(m)
+		rowsAffected = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	change := &repository.ChangeInfo{
-		Updated: int(result.RowsAffected),
+		Updated: int(rowsAffected),
 	}
 	return change, nil
 }
 
 func (r *BaseRepository) Update(c context.Context, m model.Model, data interface{}) error {
-	db := opentracing.SetSpanToGorm(c, r.DB)
-
 	// 主键保护，如果 m 什么都没设置，这里将会删除表的所有记录
 	scope := r.DB.NewScope(m)
 	if scope.PrimaryKeyZero() {
 		return errors.New(fmt.Sprintf("primary key(%s) must be set for update", scope.PrimaryKey()))
 	}
 
-	return db.Model(m).Update(data).Error
+	return r.run(c, func(db *_gorm.DB) error {
+		return db.Model(m).Update(data).Error
+	})
 }
 
 func (r *BaseRepository) FindOne(c context.Context, m model.Model) error {
-	db := opentracing.SetSpanToGorm(c, r.DB)
-
-	return db.Where(m.Unique()).Take(m).Error
+	return r.run(c, func(db *_gorm.DB) error {
+		return db.Where(m.Unique()).Take(m).Error
+	})
 }
 
 func (r *BaseRepository) Delete(c context.Context, m model.Model) error {
@@ -71,46 +133,52 @@ func (r *BaseRepository) Delete(c context.Context, m model.Model) error {
 		}
 	}
 
-	return r.DB.Delete(m).Error
+	return r.run(c, func(db *_gorm.DB) error {
+		return db.Delete(m).Error
+	})
 }
 
 func (r *BaseRepository) Page(c context.Context, m model.Model, query *model.PageQuery, resultPtr interface{}) (total int, pageCount int, err error) {
 	// items := breflect.MakeSlicePtr(m, 0, 0)
 	ms := r.DB.NewScope(m).GetModelStruct()
 
-	dbHandler := r.DB.Model(m)
-	dbHandler, err = buildQuery(dbHandler, ms, query.Filters)
-	if err != nil {
-		return
-	}
-
-	dbHandler, err = buildSort(dbHandler, ms, query.Sort)
-	if err != nil {
-		return
-	}
+	err = r.run(c, func(db *_gorm.DB) error {
+		dbHandler, err := buildQuery(db.Model(m), ms, query.Filters)
+		if err != nil {
+			return err
+		}
 
-	total, pageCount, err = pageQuery(dbHandler, query.PageNo, query.PageSize, resultPtr)
+		dbHandler, err = buildSort(dbHandler, ms, query.Sort)
+		if err != nil {
+			return err
+		}
 
+		total, pageCount, err = pageQuery(c, dbHandler, query.PageNo, query.PageSize, resultPtr)
+		return err
+	})
 	return
 }
 
 func (r *BaseRepository) Cursor(c context.Context, query *model.CursorQuery, m model.Model, resultPtr interface{}) (extra *model.CursorExtra, err error) {
 	ms := r.DB.NewScope(m).GetModelStruct()
 
-	dbHandler := r.DB.Model(m)
-	dbHandler, err = buildQuery(dbHandler, ms, query.Filters)
-	if err != nil {
-		return
-	}
+	var reverse bool
+	err = r.run(c, func(db *_gorm.DB) error {
+		dbHandler, err := buildQuery(db.Model(m), ms, query.Filters)
+		if err != nil {
+			return err
+		}
 
-	dbHandler, reverse, err := gormCursorFilter(dbHandler, ms, query)
-	if err != nil {
-		return
-	}
+		dbHandler, reverse, err = gormCursorFilter(dbHandler, ms, query)
+		if err != nil {
+			return err
+		}
 
-	// items := breflect.MakeSlicePtr(m, 0, 0)
+		// items := breflect.MakeSlicePtr(m, 0, 0)
 
-	if err = dbHandler.Limit(query.Size).Find(resultPtr).Error; err != nil {
+		return dbHandler.Limit(query.Size).Find(resultPtr).Error
+	})
+	if err != nil {
 		return
 	}
 
@@ -124,7 +192,7 @@ func (r *BaseRepository) Cursor(c context.Context, query *model.CursorQuery, m m
 	count := breflect.SlicePtrLen(resultPtr)
 	if count > 0 {
 		minItem := breflect.SlicePtrIndexOf(resultPtr, 0)
-		field, ok := FindField(query.CursorSort.Property, ms, dbHandler)
+		field, ok := FindField(query.CursorSort.Property, ms, r.DB)
 		if !ok {
 			err = errors.New("field not found")
 			return
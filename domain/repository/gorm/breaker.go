@@ -0,0 +1,35 @@
+package gorm
+
+import (
+	"database/sql/driver"
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/xxxmicro/base/database/breaker"
+)
+
+// mysqlFailures 表示服务端过载或不可用的 mysql 错误码
+var mysqlFailures = map[uint16]bool{
+	1040: true, // ER_CON_COUNT_ERROR 连接数已满
+	1053: true, // ER_SERVER_SHUTDOWN
+	1205: true, // ER_LOCK_WAIT_TIMEOUT
+	1290: true, // ER_OPTION_PREVENTS_STATEMENT 如只读实例
+	3024: true, // ER_QUERY_TIMEOUT 超过 max_execution_time
+}
+
+// IsFailure 熔断器的失败判断，通用部分见 breaker.IsFailure，另外坏连接和 mysqlFailures 中的错误码计为失败
+// 记录不存在、唯一键冲突等语句本身的错误不计入
+func IsFailure(err error) bool {
+	return breaker.IsFailure(err, isMySQLFailure)
+}
+
+func isMySQLFailure(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		return mysqlFailures[me.Number]
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+package gorm
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	_gorm "github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsFailure(t *testing.T) {
+	assert.False(t, IsFailure(_gorm.ErrRecordNotFound))
+	assert.False(t, IsFailure(ErrFilterOperate))
+	assert.False(t, IsFailure(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}))
+
+	assert.True(t, IsFailure(driver.ErrBadConn))
+	assert.True(t, IsFailure(mysql.ErrInvalidConn))
+	assert.True(t, IsFailure(&mysql.MySQLError{Number: 1040, Message: "Too many connections"}))
+}
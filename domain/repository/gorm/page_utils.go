@@ -1,8 +1,10 @@
 package gorm
 
 import(
+	"context"
 	"errors"
 	_gorm "github.com/jinzhu/gorm"
+	"github.com/xxxmicro/base/domain/repository"
 )
 
 // ErrFilter
@@ -11,15 +13,21 @@ var (
 	ErrFilterValueType 		= errors.New("过滤值类型错误")
 	ErrFilterValueSize 		= errors.New("过滤值大小错误")
 	ErrFilterOperate   		= errors.New("过滤操作错误")
+	ErrFilterDialect   		= errors.New("数据库不支持该过滤操作")
 )
 
-func pageQuery(queryHandler *_gorm.DB, pageNo int, pageSize int, resultPtr interface{}) (count int, pageCount int, err error) {
+func pageQuery(c context.Context, queryHandler *_gorm.DB, pageNo int, pageSize int, resultPtr interface{}) (count int, pageCount int, err error) {
 	limit, offset := getLimitOffset(pageNo-1, pageSize)
 
 	count = 0
-	queryHandler.Count(&count)
-	queryHandler.Limit(limit).Offset(offset).Find(resultPtr)
-	if err = queryHandler.Error; err != nil {
+	if err = queryHandler.Count(&count).Error; err != nil {
+		return
+	}
+	// 统计后已超时则不再查询数据
+	if err = repository.CheckContext(c); err != nil {
+		return
+	}
+	if err = queryHandler.Limit(limit).Offset(offset).Find(resultPtr).Error; err != nil {
 		return
 	}
 
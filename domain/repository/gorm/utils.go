@@ -116,7 +116,7 @@ func gormFilter(db *_gorm.DB, ms *_gorm.ModelStruct, key string, value interface
 					return db.Where(fmt.Sprintf("`%s` <= ?", fieldName), vValue), nil
 				case model.FilterType_LIKE:
 					return db.Where(fmt.Sprintf("`%s` LIKE ?", fieldName), vValue), nil
-				case model.FilterType_MATCH:
+				case model.FilterType_MATCH, model.FilterType_TEXT:
 					return db.Where(fmt.Sprintf("`%s` LIKE ?", fieldName), vValue), nil
 				case model.FilterType_NOT_LIKE:
 					return db.Not(fmt.Sprintf("`%s` LIKE ?", fieldName), vValue), nil
@@ -130,6 +130,17 @@ func gormFilter(db *_gorm.DB, ms *_gorm.ModelStruct, key string, value interface
 					return db.Where(fmt.Sprintf("`%s` IS NULL", fieldName)), nil
 				case model.FilterType_NOT_NULL:
 					return db.Where(fmt.Sprintf("`%s` IS NOT NULL", fieldName)), nil
+				case model.FilterType_ANY, model.FilterType_ALL, model.FilterType_SIZE, model.FilterType_ELEM_MATCH:
+					cond, args, err := buildArrayCondition(db.Dialect().GetName(), fieldName, filterType, vValue)
+					if err != nil {
+						return nil, err
+					}
+					return db.Where(cond, args...), nil
+				case model.FilterType_GEO_NEAR, model.FilterType_GEO_WITHIN_BOX, model.FilterType_GEO_WITHIN_POLYGON:
+					// 关系型数据库不支持地理条件，忽略会返回全部数据
+					return nil, errors.New("ERR_GEO_FILTER_UNSUPPORTED")
+				default:
+					return nil, ErrFilterOperate
 				}
 			}
 		}
@@ -183,6 +194,12 @@ func buildSort(dbHandler *_gorm.DB, ms *_gorm.ModelStruct, sorts []*model.SortSp
 	}
 
 	for _, sort := range sorts {
+		if sort.Near != nil {
+			// 关系型数据库不支持按距离排序
+			err = errors.New("ERR_GEO_SORT_UNSUPPORTED")
+			return
+		}
+
 		sortKey := sort.Property
 		field, ok := FindField(sortKey, ms, dbHandler)
 		if !ok {
@@ -0,0 +1,251 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/xxxmicro/base/domain/model"
+	"github.com/xxxmicro/base/domain/repository/mongo/reflect"
+	breflect "github.com/xxxmicro/base/reflect"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Stage 聚合管道的阶段
+type Stage interface {
+	stage(ms *reflect.StructInfo) (bson.M, error)
+}
+
+// MatchStage 使用与 Page 相同的过滤条件，字段按模型校验，适合放在管道开头
+type MatchStage struct {
+	Filters map[string]interface{}
+}
+
+// GroupStage ID 为分组键，如 "$status" 或 bson.M{"day": ...}；Fields 为累加器，如 {"total": {"$sum": "$amount"}}
+type GroupStage struct {
+	ID     interface{}
+	Fields bson.M
+}
+
+// LookupStage 关联查询，From 为模型时按命名策略得到集合名
+type LookupStage struct {
+	From         model.Model
+	FromName     string // 直接指定集合名，From 为空时使用
+	LocalField   string
+	ForeignField string
+	As           string
+}
+
+type UnwindStage struct {
+	Path                       string // 不带 $ 前缀也可以
+	IncludeArrayIndex          string
+	PreserveNullAndEmptyArrays bool
+}
+
+type ProjectStage struct {
+	Fields bson.M
+}
+
+// SortStage 管道中字段可能已被改写，不按模型校验
+type SortStage struct {
+	Sort []*model.SortSpec
+}
+
+type SkipStage struct {
+	Skip int
+}
+
+type LimitStage struct {
+	Limit int
+}
+
+// RawStage 其他阶段直接写原始语句，如 bson.M{"$count": "total"}
+type RawStage bson.M
+
+func (s *MatchStage) stage(ms *reflect.StructInfo) (bson.M, error) {
+	filters, err := buildQuery(ms, s.Filters)
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"$match": filters}, nil
+}
+
+func (s *GroupStage) stage(ms *reflect.StructInfo) (bson.M, error) {
+	group := bson.M{"_id": s.ID}
+	for k, v := range s.Fields {
+		if k == "_id" {
+			return nil, errors.New("ERR_MALFORMED_PARAMETERS")
+		}
+		group[k] = v
+	}
+	return bson.M{"$group": group}, nil
+}
+
+func (s *LookupStage) stage(ms *reflect.StructInfo) (bson.M, error) {
+	from := s.FromName
+	if s.From != nil {
+		fromMs, err := reflect.GetStructInfo(s.From, nil)
+		if err != nil {
+			return nil, err
+		}
+		from = TheNamingStrategy.Table(fromMs.Name)
+	}
+	if from == "" || s.As == "" {
+		return nil, errors.New("ERR_MALFORMED_PARAMETERS")
+	}
+
+	return bson.M{"$lookup": bson.M{
+		"from":         from,
+		"localField":   s.LocalField,
+		"foreignField": s.ForeignField,
+		"as":           s.As,
+	}}, nil
+}
+
+func (s *UnwindStage) stage(ms *reflect.StructInfo) (bson.M, error) {
+	path := s.Path
+	if path == "" {
+		return nil, errors.New("ERR_MALFORMED_PARAMETERS")
+	}
+	if path[0] != '$' {
+		path = "$" + path
+	}
+
+	unwind := bson.M{"path": path}
+	if s.IncludeArrayIndex != "" {
+		unwind["includeArrayIndex"] = s.IncludeArrayIndex
+	}
+	if s.PreserveNullAndEmptyArrays {
+		unwind["preserveNullAndEmptyArrays"] = true
+	}
+	return bson.M{"$unwind": unwind}, nil
+}
+
+func (s *ProjectStage) stage(ms *reflect.StructInfo) (bson.M, error) {
+	return bson.M{"$project": s.Fields}, nil
+}
+
+func (s *SortStage) stage(ms *reflect.StructInfo) (bson.M, error) {
+	if len(s.Sort) == 0 {
+		return nil, errors.New("ERR_MALFORMED_PARAMETERS")
+	}
+
+	// 多字段排序需保持顺序
+	sort := bson.D{}
+	for _, spec := range s.Sort {
+		order := 1
+		if spec.Type == model.SortType_DSC {
+			order = -1
+		}
+		sort = append(sort, bson.DocElem{Name: spec.Property, Value: order})
+	}
+	return bson.M{"$sort": sort}, nil
+}
+
+func (s *SkipStage) stage(ms *reflect.StructInfo) (bson.M, error) {
+	return bson.M{"$skip": s.Skip}, nil
+}
+
+func (s *LimitStage) stage(ms *reflect.StructInfo) (bson.M, error) {
+	if s.Limit <= 0 {
+		return nil, errors.New("ERR_MALFORMED_PARAMETERS")
+	}
+	return bson.M{"$limit": s.Limit}, nil
+}
+
+func (s RawStage) stage(ms *reflect.StructInfo) (bson.M, error) {
+	return bson.M(s), nil
+}
+
+type AggregateOptions struct {
+	AllowDiskUse bool // 允许使用磁盘临时文件，突破 100M 内存限制
+	BatchSize    int
+}
+
+type AggregateOption func(o *AggregateOptions)
+
+func AggregateAllowDiskUse() AggregateOption {
+	return func(o *AggregateOptions) {
+		o.AllowDiskUse = true
+	}
+}
+
+func AggregateBatchSize(size int) AggregateOption {
+	return func(o *AggregateOptions) {
+		o.BatchSize = size
+	}
+}
+
+func buildPipeline(ms *reflect.StructInfo, stages []Stage) ([]bson.M, error) {
+	pipeline := make([]bson.M, 0, len(stages))
+	for _, s := range stages {
+		stage, err := s.stage(ms)
+		if err != nil {
+			return nil, err
+		}
+		pipeline = append(pipeline, stage)
+	}
+	return pipeline, nil
+}
+
+// pipe 使用 context 中的读偏好，mgo 的 Pipe 不支持 maxTimeMS，MaxTime 对聚合不生效
+// fn 返回读取的结果数，记录到 span
+func (r *BaseRepository) pipe(c context.Context, method string, m model.Model, stages []Stage, opts []AggregateOption, fn func(p *mgo.Pipe) (int, error)) error {
+	options := AggregateOptions{}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	ms, err := reflect.GetStructInfo(m, nil)
+	if err != nil {
+		return err
+	}
+	collection := TheNamingStrategy.Table(ms.Name)
+
+	pipeline, err := buildPipeline(ms, stages)
+	if err != nil {
+		return err
+	}
+
+	sp := startSpan(c, method, collection)
+	sp.SetStatement(pipeline, nil)
+	return sp.Finish(r.execute(c, collection, func(c *mgo.Collection) error {
+		p := c.Pipe(pipeline)
+		if options.AllowDiskUse {
+			p = p.AllowDiskUse()
+		}
+		if options.BatchSize > 0 {
+			p = p.Batch(options.BatchSize)
+		}
+		count, err := fn(p)
+		sp.SetCount(count)
+		return err
+	}))
+}
+
+// Aggregate 在模型集合上执行聚合管道，结果写入 resultPtr 切片指针
+func (r *BaseRepository) Aggregate(c context.Context, m model.Model, stages []Stage, resultPtr interface{}, opts ...AggregateOption) error {
+	return r.pipe(c, "Aggregate", m, stages, opts, func(p *mgo.Pipe) (int, error) {
+		if err := p.All(resultPtr); err != nil {
+			return 0, err
+		}
+		return breflect.SlicePtrLen(resultPtr), nil
+	})
+}
+
+// AggregateEach 逐条读取聚合结果，每条解码到 result 后调用 fn，fn 返回错误时停止
+// 适合结果集较大的报表，不需要一次性加载到内存
+func (r *BaseRepository) AggregateEach(c context.Context, m model.Model, stages []Stage, result interface{}, fn func() error, opts ...AggregateOption) error {
+	return r.pipe(c, "AggregateEach", m, stages, opts, func(p *mgo.Pipe) (int, error) {
+		count := 0
+		iter := p.Iter()
+		for iter.Next(result) {
+			count++
+			if err := fn(); err != nil {
+				iter.Close()
+				return count, err
+			}
+		}
+		return count, iter.Close()
+	})
+}
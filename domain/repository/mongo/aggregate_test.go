@@ -0,0 +1,33 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xxxmicro/base/domain/model"
+	"github.com/xxxmicro/base/domain/repository/mongo/reflect"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestBuildPipeline(t *testing.T) {
+	ms, err := reflect.GetStructInfo(&Shop{}, nil)
+	assert.NoError(t, err)
+
+	pipeline, err := buildPipeline(ms, []Stage{
+		&MatchStage{Filters: map[string]interface{}{"name": "a"}},
+		&LookupStage{From: &User{}, LocalField: "owner", ForeignField: "_id", As: "owners"},
+		&UnwindStage{Path: "owners"},
+		&GroupStage{ID: "$owners.name", Fields: bson.M{"count": bson.M{"$sum": 1}}},
+		&SortStage{Sort: []*model.SortSpec{{Property: "count", Type: model.SortType_DSC}, {Property: "_id"}}},
+		&LimitStage{Limit: 10},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 6, len(pipeline))
+	assert.Equal(t, bson.M{"$match": bson.M{"name": bson.M{"$eq": "a"}}}, pipeline[0])
+	assert.Equal(t, "users", pipeline[1]["$lookup"].(bson.M)["from"])
+	assert.Equal(t, bson.M{"path": "$owners"}, pipeline[2]["$unwind"])
+	assert.Equal(t, bson.D{{Name: "count", Value: -1}, {Name: "_id", Value: 1}}, pipeline[4]["$sort"])
+
+	_, err = buildPipeline(ms, []Stage{&MatchStage{Filters: map[string]interface{}{"unknown": 1}}})
+	assert.Error(t, err)
+}
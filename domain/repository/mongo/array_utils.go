@@ -0,0 +1,60 @@
+package mongo
+
+import (
+	"errors"
+	stdreflect "reflect"
+
+	"github.com/xxxmicro/base/domain/model"
+	"github.com/xxxmicro/base/domain/repository/mongo/reflect"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// elemField 数组元素的子字段没有结构体信息，按任意类型处理
+var elemField = &reflect.StructField{FieldType: stdreflect.TypeOf((*interface{})(nil)).Elem()}
+
+func buildArrayFilter(filterType model.FilterType, value interface{}) (bson.M, error) {
+	switch filterType {
+	case model.FilterType_ANY:
+		return bson.M{"$in": value}, nil
+	case model.FilterType_ALL:
+		return bson.M{"$all": value}, nil
+	case model.FilterType_SIZE:
+		size, ok := toInt(value)
+		if !ok {
+			return nil, errors.New("ERR_MALFORMED_PARAMETERS")
+		}
+		return bson.M{"$size": size}, nil
+	case model.FilterType_ELEM_MATCH:
+		subFilters, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("ERR_MALFORMED_PARAMETERS")
+		}
+
+		elemMatch := bson.M{}
+		for k, v := range subFilters {
+			bFilter, err := buildMongoFilter(elemField, v)
+			if err != nil {
+				return nil, err
+			}
+			elemMatch[k] = bFilter
+		}
+		return bson.M{"$elemMatch": elemMatch}, nil
+	}
+	return nil, errors.New("ERR_MALFORMED_FILTER_TYPE")
+}
+
+func toInt(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,32 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xxxmicro/base/domain/model"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestBuildArrayFilter(t *testing.T) {
+	filter, err := buildArrayFilter(model.FilterType_ALL, []interface{}{"admin", "ops"})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"$all": []interface{}{"admin", "ops"}}, filter)
+
+	filter, err = buildArrayFilter(model.FilterType_SIZE, 2.0)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"$size": 2}, filter)
+
+	_, err = buildArrayFilter(model.FilterType_SIZE, 2.5)
+	assert.Error(t, err)
+
+	filter, err = buildArrayFilter(model.FilterType_ELEM_MATCH, map[string]interface{}{
+		"sku": "A001",
+		"qty": map[string]interface{}{"GTE": 2},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"$elemMatch": bson.M{
+		"sku": bson.M{"$eq": "A001"},
+		"qty": bson.M{"$gte": 2},
+	}}, filter)
+}
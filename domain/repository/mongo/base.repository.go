@@ -4,12 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"reflect"
 
 	"github.com/xxxmicro/base/database/mongo"
 	"github.com/xxxmicro/base/domain/model"
 	"github.com/xxxmicro/base/domain/repository"
 	reflect2 "github.com/xxxmicro/base/domain/repository/mongo/reflect"
+	xxxmicro_opentracing "github.com/xxxmicro/base/opentracing"
 	breflect "github.com/xxxmicro/base/reflect"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -23,6 +23,45 @@ func NewBaseRepository(db *mongo.DB) *BaseRepository {
 	return &BaseRepository{db}
 }
 
+// NewNamedRepository 使用 registry 中名为 name 的数据源
+func NewNamedRepository(registry *mongo.Registry, name string) (*BaseRepository, error) {
+	db, err := registry.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewBaseRepository(db), nil
+}
+
+// execute 使用 context 中的读写选项和截止时间执行，见 WithQueryOptions
+// mgo 不支持中断执行中的操作，截止时间通过 socket 超时和 maxTimeMS 生效，取消只在执行前检查
+func (r *BaseRepository) execute(c context.Context, collection string, fn DBFunc) error {
+	if err := repository.CheckContext(c); err != nil {
+		return err
+	}
+	err := r.run(collection, contextOptions(c), fn)
+	return wrapTimeoutError(c, err)
+}
+
+// run 经过数据源的熔断器在当前连接上执行，配置热更新替换连接时会等待执行结束
+// 只有 IsFailure 判断为数据库故障的错误计入熔断
+func (r *BaseRepository) run(collection string, options *QueryOptions, fn DBFunc) error {
+	done, err := r.db.Breaker().Allow()
+	if err != nil {
+		return err
+	}
+
+	session, database, release := r.db.Acquire()
+	defer release()
+	err = ExecuteWithOptions(session, database, collection, options, fn)
+	done(IsFailure(err))
+	return err
+}
+
+// startSpan 记录一次集合操作，见 opentracing.StartDBSpan
+func startSpan(c context.Context, method string, collection string) *xxxmicro_opentracing.DBSpan {
+	return xxxmicro_opentracing.StartDBSpan(c, "mongo", method, collection)
+}
+
 func (r *BaseRepository) Create(c context.Context, m model.Model) error {
 	ms, err := reflect2.GetStructInfo(m, nil)
 	if err != nil {
@@ -32,9 +71,10 @@ func (r *BaseRepository) Create(c context.Context, m model.Model) error {
 
 	// TODO 找出 ctime, utime 的 tag 进行设置
 
-	return Execute(r.db.Session, r.db.Name, collection, func(c *mgo.Collection) error {
+	sp := startSpan(c, "Create", collection)
+	return sp.Finish(r.execute(c, collection, func(c *mgo.Collection) error {
 		return c.Insert(m)
-	})
+	}))
 }
 
 func (r *BaseRepository) Upsert(c context.Context, m model.Model) (changeInfo *repository.ChangeInfo, err error) {
@@ -44,7 +84,11 @@ func (r *BaseRepository) Upsert(c context.Context, m model.Model) (changeInfo *r
 	}
 	collection := TheNamingStrategy.Table(ms.Name)
 
-	Execute(r.db.Session, r.db.Name, collection, func(c *mgo.Collection) error {
+	sp := startSpan(c, "Upsert", collection)
+	sp.SetStatement(m.Unique(), nil)
+	defer func() { sp.Finish(err) }()
+
+	err = r.execute(c, collection, func(c *mgo.Collection) error {
 		var change *mgo.ChangeInfo
 		change, err = c.Upsert(m.Unique(), m)
 		if err != nil {
@@ -69,11 +113,13 @@ func (r *BaseRepository) Update(c context.Context, m model.Model, change interfa
 	}
 	collection := TheNamingStrategy.Table(ms.Name)
 
-	return Execute(r.db.Session, r.db.Name, collection, func(c *mgo.Collection) error {
+	sp := startSpan(c, "Update", collection)
+	sp.SetStatement(m.Unique(), nil)
+	return sp.Finish(r.execute(c, collection, func(c *mgo.Collection) error {
 		return c.Update(m.Unique(), bson.M{
 			"$set": change,
 		})
-	})
+	}))
 }
 
 func (r *BaseRepository) FindOne(c context.Context, m model.Model) error {
@@ -83,9 +129,12 @@ func (r *BaseRepository) FindOne(c context.Context, m model.Model) error {
 	}
 	collection := TheNamingStrategy.Table(ms.Name)
 
-	return Execute(r.db.Session, r.db.Name, collection, func(c *mgo.Collection) error {
-		return c.Find(m.Unique()).One(m)
-	})
+	sp := startSpan(c, "FindOne", collection)
+	sp.SetStatement(m.Unique(), nil)
+	options := contextOptions(c)
+	return sp.Finish(r.execute(c, collection, func(c *mgo.Collection) error {
+		return options.query(c.Find(m.Unique())).One(m)
+	}))
 }
 
 func (r *BaseRepository) Delete(c context.Context, m model.Model) error {
@@ -95,9 +144,11 @@ func (r *BaseRepository) Delete(c context.Context, m model.Model) error {
 	}
 	collection := TheNamingStrategy.Table(ms.Name)
 
-	return Execute(r.db.Session, r.db.Name, collection, func(c *mgo.Collection) error {
+	sp := startSpan(c, "Delete", collection)
+	sp.SetStatement(m.Unique(), nil)
+	return sp.Finish(r.execute(c, collection, func(c *mgo.Collection) error {
 		return c.Remove(m.Unique())
-	})
+	}))
 }
 
 func (r *BaseRepository) Page(c context.Context, m model.Model, query *model.PageQuery, resultPtr interface{}) (total int, pageCount int, err error) {
@@ -107,18 +158,34 @@ func (r *BaseRepository) Page(c context.Context, m model.Model, query *model.Pag
 	}
 	collection := TheNamingStrategy.Table(ms.Name)
 
-	filters, err := buildQuery(ms, query.Filters)
+	filters, textActive, err := r.buildTextQuery(ms, collection, query.Filters)
 	if err != nil {
 		return
 	}
 
-	sorts, err := buildSort(ms, query.Sort)
+	filters, sortSpecs, err := applyGeoSort(ms, filters, query.Sort)
 	if err != nil {
 		return
 	}
 
-	err = Execute(r.db.Session, r.db.Name, collection, func(c *mgo.Collection) error {
-		total, err = c.Find(filters).Count()
+	sortSpecs, scoreSort := splitScoreSort(sortSpecs)
+	sorts, err := buildSort(ms, sortSpecs)
+	if err != nil {
+		return
+	}
+
+	sp := startSpan(c, "Page", collection)
+	sp.SetStatement(filters, sorts)
+	defer func() {
+		if err == nil {
+			sp.SetCount(breflect.SlicePtrLen(resultPtr))
+		}
+		sp.Finish(err)
+	}()
+
+	options := contextOptions(c)
+	err = r.execute(c, collection, func(c *mgo.Collection) error {
+		total, err = c.Find(geoCountQuery(filters)).Count()
 		if err != nil {
 			return err
 		}
@@ -139,7 +206,7 @@ func (r *BaseRepository) Page(c context.Context, m model.Model, query *model.Pag
 			pageCount++
 		}
 
-		return c.Find(filters).Skip(offset).Limit(pageSize).Sort(sorts...).All(resultPtr)
+		return options.query(textQuery(c.Find(filters), textActive, scoreSort, sorts)).Skip(offset).Limit(pageSize).All(resultPtr)
 	})
 
 	return
@@ -152,7 +219,7 @@ func (r *BaseRepository) Cursor(c context.Context, query *model.CursorQuery, m m
 	}
 	collection := TheNamingStrategy.Table(ms.Name)
 
-	filters, err := buildQuery(ms, query.Filters)
+	filters, textActive, err := r.buildTextQuery(ms, collection, query.Filters)
 	if err != nil {
 		return
 	}
@@ -177,9 +244,19 @@ func (r *BaseRepository) Cursor(c context.Context, query *model.CursorQuery, m m
 		size = 20
 	}
 
-	err = Execute(r.db.Session, r.db.Name, collection, func(c *mgo.Collection) error {
+	sp := startSpan(c, "Cursor", collection)
+	sp.SetStatement(filters, []string{sort})
+	defer func() {
+		if err == nil {
+			sp.SetCount(breflect.SlicePtrLen(resultPtr))
+		}
+		sp.Finish(err)
+	}()
+
+	options := contextOptions(c)
+	err = r.execute(c, collection, func(c *mgo.Collection) error {
 		// 多取一个，用于判断是否有更多数据
-		return c.Find(filters).Limit(size).Sort(sort).All(resultPtr)
+		return options.query(textQuery(c.Find(filters), textActive, false, []string{sort})).Limit(size).All(resultPtr)
 	})
 
 	if err != nil {
@@ -220,10 +297,26 @@ func (r *BaseRepository) Cursor(c context.Context, query *model.CursorQuery, m m
 	return
 }
 
-func (r *BaseRepository) EnsureIndexes(m Indexed) (err error) {
-	collection := TheNamingStrategy.Table(reflect.TypeOf(m).Elem().Name())
+func (r *BaseRepository) EnsureIndexes(m Indexed) error {
+	return r.EnsureIndexesContext(context.Background(), m)
+}
+
+// EnsureIndexesContext 同 EnsureIndexes，使用 c 中的 span 和截止时间
+func (r *BaseRepository) EnsureIndexesContext(ctx context.Context, m Indexed) (err error) {
+	t, err := modelType(m)
+	if err != nil {
+		return
+	}
+	collection := TheNamingStrategy.Table(t.Name())
+	defer r.invalidateTextIndex(collection)
+
+	sp := startSpan(ctx, "EnsureIndexes", collection)
+	defer func() {
+		sp.SetCount(len(m.Indexes()))
+		sp.Finish(err)
+	}()
 
-	err = Execute(r.db.Session, r.db.Name, collection, func(c *mgo.Collection) error {
+	err = r.execute(ctx, collection, func(c *mgo.Collection) error {
 		for _, i := range m.Indexes() {
 			err = c.EnsureIndex(i)
 			if err != nil {
@@ -0,0 +1,45 @@
+package mongo
+
+import (
+	"github.com/xxxmicro/base/database/breaker"
+	"gopkg.in/mgo.v2"
+)
+
+// failureCodes 表示节点不可用、主从切换或执行超时的服务端错误码，mongodriver 共用
+var failureCodes = map[int]bool{
+	6:     true, // HostUnreachable
+	7:     true, // HostNotFound
+	50:    true, // MaxTimeMSExpired
+	64:    true, // WriteConcernFailed
+	89:    true, // NetworkTimeout
+	91:    true, // ShutdownInProgress
+	189:   true, // PrimarySteppedDown
+	262:   true, // ExceededTimeLimit
+	9001:  true, // SocketException
+	10107: true, // NotWritablePrimary
+	11600: true, // InterruptedAtShutdown
+	11602: true, // InterruptedDueToReplStateChange
+	13435: true, // NotPrimaryNoSecondaryOk
+	13436: true, // NotPrimaryOrSecondary
+}
+
+// IsFailureCode 服务端错误码是否表示数据库故障
+func IsFailureCode(code int) bool {
+	return failureCodes[code]
+}
+
+// IsFailure 熔断器的失败判断，通用部分见 breaker.IsFailure，mgo 返回的 failureCodes 错误码和无可用节点计为失败
+func IsFailure(err error) bool {
+	return breaker.IsFailure(err, isMgoFailure)
+}
+
+func isMgoFailure(err error) bool {
+	switch e := err.(type) {
+	case *mgo.QueryError:
+		return IsFailureCode(e.Code)
+	case *mgo.LastError:
+		return IsFailureCode(e.Code)
+	}
+	// mgo 没有导出找不到可用节点的错误
+	return err.Error() == "no reachable servers"
+}
@@ -0,0 +1,20 @@
+package mongo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2"
+)
+
+func TestIsFailure(t *testing.T) {
+	assert.False(t, IsFailure(mgo.ErrNotFound))
+	assert.False(t, IsFailure(&mgo.LastError{Code: 11000, Err: "E11000 duplicate key error"}))
+	assert.False(t, IsFailure(&mgo.QueryError{Code: 2, Message: "bad value"}))
+	assert.False(t, IsFailure(errors.New("ERR_DB_READ_CONCERN_UNSUPPORTED")))
+
+	assert.True(t, IsFailure(errors.New("no reachable servers")))
+	assert.True(t, IsFailure(&mgo.QueryError{Code: maxTimeExpired, Message: "operation exceeded time limit"}))
+	assert.True(t, IsFailure(&mgo.LastError{Code: 10107, Err: "not master"}))
+}
@@ -0,0 +1,29 @@
+package mongo
+
+import (
+	"github.com/xxxmicro/base/domain/model"
+	"github.com/xxxmicro/base/domain/repository/mongo/reflect"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// 以下函数供 mongodriver 复用，保证两套实现的过滤、排序和游标行为一致
+
+func BuildQuery(ms *reflect.StructInfo, filters map[string]interface{}) (bson.M, error) {
+	return buildQuery(ms, filters)
+}
+
+func BuildSort(ms *reflect.StructInfo, sorts []*model.SortSpec) ([]string, error) {
+	return buildSort(ms, sorts)
+}
+
+func ApplyGeoSort(ms *reflect.StructInfo, filters bson.M, sorts []*model.SortSpec) (bson.M, []*model.SortSpec, error) {
+	return applyGeoSort(ms, filters, sorts)
+}
+
+func GeoCountQuery(filters bson.M) bson.M {
+	return geoCountQuery(filters)
+}
+
+func CursorFilter(ms *reflect.StructInfo, cursorQuery *model.CursorQuery) (filter bson.M, sort string, reverse bool, err error) {
+	return mongoCursorFilter(ms, cursorQuery)
+}
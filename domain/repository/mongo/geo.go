@@ -0,0 +1,147 @@
+package mongo
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/xxxmicro/base/domain/model"
+	"github.com/xxxmicro/base/domain/repository/mongo/reflect"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// 地球半径(米)，$centerSphere 使用弧度
+const earthRadius = 6378100.0
+
+// buildGeoFilter 地理条件，字段需建 2dsphere 索引，坐标为 GeoJSON Point
+func buildGeoFilter(filterType model.FilterType, value interface{}) (bson.M, error) {
+	switch filterType {
+	case model.FilterType_GEO_NEAR:
+		near, err := model.ParseGeoNear(value)
+		if err != nil {
+			return nil, err
+		}
+		return bson.M{"$nearSphere": buildNearSphere(near)}, nil
+	case model.FilterType_GEO_WITHIN_BOX:
+		box, err := model.ParseGeoBox(value)
+		if err != nil {
+			return nil, err
+		}
+		return bson.M{"$geoWithin": bson.M{"$geometry": buildGeoPolygon([]model.GeoPoint{
+			box.TopLeft,
+			{Lng: box.TopLeft.Lng, Lat: box.BottomRight.Lat},
+			box.BottomRight,
+			{Lng: box.BottomRight.Lng, Lat: box.TopLeft.Lat},
+		})}}, nil
+	case model.FilterType_GEO_WITHIN_POLYGON:
+		points, err := model.ParseGeoPolygon(value)
+		if err != nil {
+			return nil, err
+		}
+		return bson.M{"$geoWithin": bson.M{"$geometry": buildGeoPolygon(points)}}, nil
+	}
+	return nil, errors.New("ERR_MALFORMED_FILTER_TYPE")
+}
+
+func buildGeoPoint(p model.GeoPoint) bson.M {
+	return bson.M{"type": "Point", "coordinates": []float64{p.Lng, p.Lat}}
+}
+
+// buildGeoPolygon 多边形需首尾闭合
+func buildGeoPolygon(points []model.GeoPoint) bson.M {
+	ring := make([][]float64, 0, len(points)+1)
+	for _, p := range points {
+		ring = append(ring, []float64{p.Lng, p.Lat})
+	}
+	if first, last := points[0], points[len(points)-1]; first != last {
+		ring = append(ring, []float64{first.Lng, first.Lat})
+	}
+	return bson.M{"type": "Polygon", "coordinates": [][][]float64{ring}}
+}
+
+func buildNearSphere(near *model.GeoNear) bson.M {
+	nearSphere := bson.M{"$geometry": buildGeoPoint(near.Point)}
+	if near.MaxDistance > 0 {
+		nearSphere["$maxDistance"] = near.MaxDistance
+	}
+	if near.MinDistance > 0 {
+		nearSphere["$minDistance"] = near.MinDistance
+	}
+	return nearSphere
+}
+
+// applyGeoSort 按距离排序时在查询中加入 $nearSphere，结果按距离由近到远返回
+// $nearSphere 的排序会被显式排序覆盖，所以按距离排序时忽略其他排序
+func applyGeoSort(ms *reflect.StructInfo, filters bson.M, sorts []*model.SortSpec) (bson.M, []*model.SortSpec, error) {
+	for i, s := range sorts {
+		if s.Near == nil {
+			continue
+		}
+		if i != 0 {
+			return nil, nil, errors.New("ERR_GEO_SORT_MUST_BE_FIRST")
+		}
+		if s.Type == model.SortType_DSC {
+			return nil, nil, errors.New("ERR_GEO_SORT_DSC_UNSUPPORTED")
+		}
+		if _, ok := ms.FieldsMap[s.Property]; !ok {
+			return nil, nil, errors.New(fmt.Sprintf("ERR_DB_UNKNOWN_FIELD %s", s.Property))
+		}
+
+		nearFilter := bson.M{"$nearSphere": buildNearSphere(&model.GeoNear{Point: *s.Near})}
+		if existing, ok := filters[s.Property].(bson.M); ok {
+			if _, ok := existing["$nearSphere"]; ok {
+				// 已有距离条件，直接按该条件的中心点排序
+				return filters, nil, nil
+			}
+			return bson.M{"$and": []bson.M{filters, {s.Property: nearFilter}}}, nil, nil
+		}
+
+		merged := bson.M{}
+		for k, v := range filters {
+			merged[k] = v
+		}
+		merged[s.Property] = nearFilter
+		return merged, nil, nil
+	}
+	return filters, sorts, nil
+}
+
+// geoCountQuery count 不支持 $nearSphere，替换为等价的 $geoWithin/$centerSphere
+func geoCountQuery(filters bson.M) bson.M {
+	count := bson.M{}
+	for k, v := range filters {
+		switch sub := v.(type) {
+		case []bson.M:
+			subCount := make([]bson.M, len(sub))
+			for i, f := range sub {
+				subCount[i] = geoCountQuery(f)
+			}
+			count[k] = subCount
+		case bson.M:
+			nearSphere, ok := sub["$nearSphere"].(bson.M)
+			if !ok {
+				count[k] = sub
+				continue
+			}
+			count[k] = nearToWithin(nearSphere)
+		default:
+			count[k] = v
+		}
+	}
+	return count
+}
+
+func nearToWithin(nearSphere bson.M) bson.M {
+	geometry, _ := nearSphere["$geometry"].(bson.M)
+	center := geometry["coordinates"]
+
+	filter := bson.M{}
+	if max, ok := nearSphere["$maxDistance"].(float64); ok {
+		filter["$geoWithin"] = bson.M{"$centerSphere": []interface{}{center, max / earthRadius}}
+	} else {
+		filter["$exists"] = true
+	}
+	if min, ok := nearSphere["$minDistance"].(float64); ok {
+		filter["$not"] = bson.M{"$geoWithin": bson.M{"$centerSphere": []interface{}{center, min / earthRadius}}}
+	}
+	return filter
+}
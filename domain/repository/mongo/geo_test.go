@@ -0,0 +1,68 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xxxmicro/base/domain/model"
+	"github.com/xxxmicro/base/domain/repository/mongo/reflect"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type Shop struct {
+	ID       bson.ObjectId `bson:"_id"`
+	Name     string        `bson:"name"`
+	Location bson.M        `bson:"location"`
+}
+
+func (s *Shop) Unique() interface{} {
+	return bson.M{"_id": s.ID}
+}
+
+func TestBuildGeoFilter(t *testing.T) {
+	ms, err := reflect.GetStructInfo(&Shop{}, nil)
+	assert.NoError(t, err)
+
+	filters, err := buildQuery(ms, map[string]interface{}{
+		"location": map[string]interface{}{
+			"GEO_NEAR": map[string]interface{}{"point": []interface{}{116.4, 39.9}, "maxDistance": 5000.0},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"$nearSphere": bson.M{
+		"$geometry":    bson.M{"type": "Point", "coordinates": []float64{116.4, 39.9}},
+		"$maxDistance": 5000.0,
+	}}, filters["location"])
+
+	// count 时替换为 $centerSphere
+	count := geoCountQuery(filters)
+	within := count["location"].(bson.M)["$geoWithin"].(bson.M)["$centerSphere"].([]interface{})
+	assert.Equal(t, 5000.0/earthRadius, within[1])
+
+	box, err := buildGeoFilter(model.FilterType_GEO_WITHIN_BOX, map[string]interface{}{
+		"topLeft":     []interface{}{116.3, 40.0},
+		"bottomRight": map[string]interface{}{"lng": 116.5, "lat": 39.8},
+	})
+	assert.NoError(t, err)
+	ring := box["$geoWithin"].(bson.M)["$geometry"].(bson.M)["coordinates"].([][][]float64)[0]
+	assert.Equal(t, 5, len(ring))
+	assert.Equal(t, ring[0], ring[4])
+
+	_, err = buildGeoFilter(model.FilterType_GEO_WITHIN_POLYGON, []interface{}{[]interface{}{116.3, 40.0}})
+	assert.Error(t, err)
+}
+
+func TestApplyGeoSort(t *testing.T) {
+	ms, err := reflect.GetStructInfo(&Shop{}, nil)
+	assert.NoError(t, err)
+
+	sorts := []*model.SortSpec{{Property: "location", Type: model.SortType_ASC, Near: &model.GeoPoint{Lng: 116.4, Lat: 39.9}}}
+	filters, rest, err := applyGeoSort(ms, bson.M{"name": bson.M{"$eq": "a"}}, sorts)
+	assert.NoError(t, err)
+	assert.Nil(t, rest)
+	assert.NotNil(t, filters["location"].(bson.M)["$nearSphere"])
+
+	sorts = []*model.SortSpec{{Property: "name"}, sorts[0]}
+	_, _, err = applyGeoSort(ms, bson.M{}, sorts)
+	assert.Error(t, err)
+}
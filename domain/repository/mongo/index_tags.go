@@ -0,0 +1,425 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	stdreflect "reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v2/logger"
+	"github.com/xxxmicro/base/domain/model"
+	"gopkg.in/mgo.v2"
+)
+
+// 在字段上通过 index 标签声明索引，多个索引用 ; 分隔，每个索引的选项用 , 分隔:
+//
+//	Email string    `bson:"email" index:"unique"`
+//	Ctime time.Time `bson:"ctime" index:"ttl=86400;name=idx_owner_ctime,order=1,desc"`
+//	Owner string    `bson:"owner" index:"name=idx_owner_ctime,order=0"`
+//	Title string    `bson:"title" index:"text"`
+//	Loc   bson.M    `bson:"loc" index:"2dsphere"`
+//
+// 选项: name 索引名，同名字段组成复合索引，按 order 排列；desc 降序；unique、sparse；
+// ttl 过期秒数；text 全文索引；2dsphere 地理索引
+// 每个集合只能有一个文本索引，未指定 name 的 text 字段合并为一个索引，有命名的文本索引时并入该索引
+const indexTag = "index"
+
+// textIndexGroup 未命名 text 字段所在的索引，解析完成后按字段生成索引名
+const textIndexGroup = "$text"
+
+type indexField struct {
+	key   string
+	order int
+	seq   int
+}
+
+// modelType 模型的结构体类型，模型可以是结构体或结构体指针
+func modelType(m model.Model) (stdreflect.Type, error) {
+	t := stdreflect.TypeOf(m)
+	if t == nil {
+		return nil, errors.New("not struct param")
+	}
+	if t.Kind() == stdreflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != stdreflect.Struct {
+		return nil, errors.New("not struct param")
+	}
+	return t, nil
+}
+
+// TagIndexes 解析模型 index 标签声明的索引，同时包含 Indexed 接口声明的索引
+func TagIndexes(m model.Model) ([]mgo.Index, error) {
+	t, err := modelType(m)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := make(map[string]*mgo.Index)
+	fields := make(map[string][]indexField)
+	var names []string
+
+	seq := 0
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.TrimSpace(field.Tag.Get(indexTag))
+		if tag == "" {
+			continue
+		}
+
+		column := strings.TrimSpace(strings.Split(field.Tag.Get("bson"), ",")[0])
+		if column == "" || column == "-" {
+			return nil, errors.New(fmt.Sprintf("ERR_DB_INDEX_FIELD_WITHOUT_BSON %s", field.Name))
+		}
+
+		for _, spec := range strings.Split(tag, ";") {
+			spec = strings.TrimSpace(spec)
+			if spec == "" {
+				continue
+			}
+
+			index, f, err := parseIndexSpec(column, spec)
+			if err != nil {
+				return nil, err
+			}
+			f.seq = seq
+			seq++
+
+			name := index.Name
+			if name == "" && strings.HasPrefix(f.key, "$text:") {
+				name = textIndexGroup
+			} else if name == "" {
+				name = indexName([]string{f.key})
+			}
+
+			existing, ok := indexes[name]
+			if !ok {
+				indexes[name] = index
+				names = append(names, name)
+			} else {
+				existing.Unique = existing.Unique || index.Unique
+				existing.Sparse = existing.Sparse || index.Sparse
+				if index.ExpireAfter > 0 {
+					existing.ExpireAfter = index.ExpireAfter
+				}
+			}
+			fields[name] = append(fields[name], f)
+		}
+	}
+
+	names, err = mergeTextIndexes(names, indexes, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]mgo.Index, 0, len(names))
+	for _, name := range names {
+		index := indexes[name]
+		fs := fields[name]
+		sort.SliceStable(fs, func(i, j int) bool {
+			if fs[i].order != fs[j].order {
+				return fs[i].order < fs[j].order
+			}
+			return fs[i].seq < fs[j].seq
+		})
+		for _, f := range fs {
+			index.Key = append(index.Key, f.key)
+		}
+		index.Name = name
+		if name == textIndexGroup {
+			index.Name = indexName(index.Key)
+		}
+		result = append(result, *index)
+	}
+
+	if indexed, ok := m.(Indexed); ok {
+		for _, index := range indexed.Indexes() {
+			if index.Name == "" {
+				index.Name = indexName(index.Key)
+			}
+			result = append(result, index)
+		}
+	}
+
+	return result, nil
+}
+
+// mergeTextIndexes 未命名的 text 字段并入命名的文本索引，存在多个命名的文本索引时返回错误
+func mergeTextIndexes(names []string, indexes map[string]*mgo.Index, fields map[string][]indexField) ([]string, error) {
+	var named []string
+	for _, name := range names {
+		if name == textIndexGroup {
+			continue
+		}
+		for _, f := range fields[name] {
+			if strings.HasPrefix(f.key, "$text:") {
+				named = append(named, name)
+				break
+			}
+		}
+	}
+
+	switch {
+	case len(named) > 1:
+		return nil, errors.New(fmt.Sprintf("ERR_DB_MULTIPLE_TEXT_INDEXES %s", strings.Join(named, ",")))
+	case len(named) == 0 || fields[textIndexGroup] == nil:
+		return names, nil
+	}
+
+	target := named[0]
+	fields[target] = append(fields[target], fields[textIndexGroup]...)
+	indexes[target].Unique = indexes[target].Unique || indexes[textIndexGroup].Unique
+	indexes[target].Sparse = indexes[target].Sparse || indexes[textIndexGroup].Sparse
+	result := make([]string, 0, len(names)-1)
+	for _, name := range names {
+		if name != textIndexGroup {
+			result = append(result, name)
+		}
+	}
+	return result, nil
+}
+
+func parseIndexSpec(column string, spec string) (*mgo.Index, indexField, error) {
+	index := &mgo.Index{}
+	f := indexField{key: column}
+
+	for _, opt := range strings.Split(spec, ",") {
+		opt = strings.TrimSpace(opt)
+		kv := strings.SplitN(opt, "=", 2)
+
+		switch kv[0] {
+		case "":
+		case "unique":
+			index.Unique = true
+		case "sparse":
+			index.Sparse = true
+		case "desc":
+			f.key = "-" + column
+		case "text":
+			f.key = "$text:" + column
+		case "2dsphere":
+			f.key = "$2dsphere:" + column
+		case "name":
+			if len(kv) != 2 || kv[1] == "" {
+				return nil, f, errors.New(fmt.Sprintf("ERR_DB_MALFORMED_INDEX_TAG %s", spec))
+			}
+			index.Name = kv[1]
+		case "order", "ttl":
+			if len(kv) != 2 {
+				return nil, f, errors.New(fmt.Sprintf("ERR_DB_MALFORMED_INDEX_TAG %s", spec))
+			}
+			n, err := strconv.Atoi(kv[1])
+			if err != nil {
+				return nil, f, errors.New(fmt.Sprintf("ERR_DB_MALFORMED_INDEX_TAG %s", spec))
+			}
+			if kv[0] == "order" {
+				f.order = n
+			} else {
+				index.ExpireAfter = time.Duration(n) * time.Second
+			}
+		default:
+			return nil, f, errors.New(fmt.Sprintf("ERR_DB_MALFORMED_INDEX_TAG %s", spec))
+		}
+	}
+	return index, f, nil
+}
+
+// indexName 与 mgo 生成的默认索引名一致
+func indexName(key []string) string {
+	parts := make([]string, 0, len(key))
+	for _, field := range key {
+		switch {
+		case strings.HasPrefix(field, "$"):
+			if c := strings.Index(field, ":"); c > 1 {
+				parts = append(parts, field[c+1:]+"_"+field[1:c])
+				continue
+			}
+			parts = append(parts, field)
+		case strings.HasPrefix(field, "@"):
+			parts = append(parts, field[1:]+"_2d")
+		case strings.HasPrefix(field, "-"):
+			parts = append(parts, field[1:]+"_-1")
+		default:
+			parts = append(parts, strings.TrimPrefix(field, "+")+"_1")
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+type IndexAction string
+
+const (
+	IndexAction_CREATE   IndexAction = "CREATE"   // 缺少的索引
+	IndexAction_DROP     IndexAction = "DROP"     // 多余的索引
+	IndexAction_RECREATE IndexAction = "RECREATE" // 同名但定义不一致的索引
+)
+
+type IndexChange struct {
+	Collection string
+	Action     IndexAction
+	Index      mgo.Index
+	Applied    bool // dry-run 时为 false
+}
+
+type ReconcileOptions struct {
+	DryRun    bool // 只报告差异，不修改索引
+	KeepExtra bool // 不删除多余的索引
+}
+
+type ReconcileOption func(o *ReconcileOptions)
+
+func ReconcileDryRun() ReconcileOption {
+	return func(o *ReconcileOptions) {
+		o.DryRun = true
+	}
+}
+
+func ReconcileKeepExtra() ReconcileOption {
+	return func(o *ReconcileOptions) {
+		o.KeepExtra = true
+	}
+}
+
+var indexRegistry = struct {
+	sync.Mutex
+	models []model.Model
+}{}
+
+// RegisterIndexes 注册需要维护索引的模型，启动时由 ReconcileIndexes 统一处理
+func RegisterIndexes(models ...model.Model) {
+	indexRegistry.Lock()
+	defer indexRegistry.Unlock()
+	indexRegistry.models = append(indexRegistry.models, models...)
+}
+
+// ReconcileIndexes 对比所有注册模型声明的索引与数据库中的索引，创建缺少的、重建不一致的、删除多余的
+func (r *BaseRepository) ReconcileIndexes(ctx context.Context, opts ...ReconcileOption) (changes []*IndexChange, err error) {
+	indexRegistry.Lock()
+	models := append([]model.Model(nil), indexRegistry.models...)
+	indexRegistry.Unlock()
+
+	for _, m := range models {
+		var modelChanges []*IndexChange
+		modelChanges, err = r.ReconcileModelIndexes(ctx, m, opts...)
+		changes = append(changes, modelChanges...)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (r *BaseRepository) ReconcileModelIndexes(ctx context.Context, m model.Model, opts ...ReconcileOption) (changes []*IndexChange, err error) {
+	options := ReconcileOptions{}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	desired, err := TagIndexes(m)
+	if err != nil {
+		return
+	}
+	t, err := modelType(m)
+	if err != nil {
+		return
+	}
+	collection := TheNamingStrategy.Table(t.Name())
+
+	if !options.DryRun {
+		defer r.invalidateTextIndex(collection)
+	}
+
+	sp := startSpan(ctx, "ReconcileIndexes", collection)
+	sp.SetTag("db.dry_run", options.DryRun)
+	defer func() {
+		sp.SetCount(len(changes))
+		sp.Finish(err)
+	}()
+
+	err = r.execute(ctx, collection, func(c *mgo.Collection) error {
+		existing, err := c.Indexes()
+		if err != nil && !isNamespaceNotFound(err) {
+			return err
+		}
+
+		changes = diffIndexes(collection, desired, existing, options.KeepExtra)
+		for _, change := range changes {
+			logger.Logf(logger.InfoLevel, "mongo index %s %s.%s %v dryRun=%v", change.Action, collection, change.Index.Name, change.Index.Key, options.DryRun)
+			if options.DryRun {
+				continue
+			}
+
+			switch change.Action {
+			case IndexAction_DROP:
+				err = c.DropIndexName(change.Index.Name)
+			case IndexAction_RECREATE:
+				if err = c.DropIndexName(change.Index.Name); err == nil {
+					err = c.EnsureIndex(change.Index)
+				}
+			default:
+				err = c.EnsureIndex(change.Index)
+			}
+			if err != nil {
+				return err
+			}
+			change.Applied = true
+		}
+		return nil
+	})
+	return
+}
+
+// diffIndexes 按索引名比较，_id 索引不参与
+func diffIndexes(collection string, desired []mgo.Index, existing []mgo.Index, keepExtra bool) []*IndexChange {
+	existingMap := make(map[string]mgo.Index, len(existing))
+	for _, index := range existing {
+		existingMap[index.Name] = index
+	}
+
+	var changes []*IndexChange
+	desiredNames := make(map[string]struct{}, len(desired))
+	for _, index := range desired {
+		desiredNames[index.Name] = struct{}{}
+
+		current, ok := existingMap[index.Name]
+		if !ok {
+			changes = append(changes, &IndexChange{Collection: collection, Action: IndexAction_CREATE, Index: index})
+		} else if !sameIndex(index, current) {
+			changes = append(changes, &IndexChange{Collection: collection, Action: IndexAction_RECREATE, Index: index})
+		}
+	}
+
+	if !keepExtra {
+		for _, index := range existing {
+			if _, ok := desiredNames[index.Name]; ok || index.Name == "_id_" {
+				continue
+			}
+			changes = append(changes, &IndexChange{Collection: collection, Action: IndexAction_DROP, Index: index})
+		}
+	}
+	return changes
+}
+
+func sameIndex(a mgo.Index, b mgo.Index) bool {
+	if len(a.Key) != len(b.Key) {
+		return false
+	}
+	for i := range a.Key {
+		if strings.TrimPrefix(a.Key[i], "+") != strings.TrimPrefix(b.Key[i], "+") {
+			return false
+		}
+	}
+	return a.Unique == b.Unique && a.Sparse == b.Sparse && a.ExpireAfter == b.ExpireAfter
+}
+
+func isNamespaceNotFound(err error) bool {
+	if qerr, ok := err.(*mgo.QueryError); ok {
+		return qerr.Code == 26
+	}
+	return strings.Contains(err.Error(), "ns not found") || strings.Contains(err.Error(), "NamespaceNotFound")
+}
@@ -0,0 +1,118 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type Order struct {
+	ID    bson.ObjectId `bson:"_id"`
+	No    string        `bson:"no" index:"unique"`
+	Owner string        `bson:"owner,omitempty" index:"name=idx_owner_ctime,order=0"`
+	Ctime time.Time     `bson:"ctime" index:"ttl=86400;name=idx_owner_ctime,order=1,desc"`
+	Title string        `bson:"title" index:"text"`
+	Loc   bson.M        `bson:"loc" index:"2dsphere"`
+}
+
+func (o *Order) Unique() interface{} {
+	return bson.M{"_id": o.ID}
+}
+
+func TestTagIndexes(t *testing.T) {
+	indexes, err := TagIndexes(&Order{})
+	assert.NoError(t, err)
+	assert.Equal(t, []mgo.Index{
+		{Key: []string{"no"}, Unique: true, Name: "no_1"},
+		{Key: []string{"owner", "-ctime"}, Name: "idx_owner_ctime"},
+		{Key: []string{"ctime"}, ExpireAfter: 24 * time.Hour, Name: "ctime_1"},
+		{Key: []string{"$text:title"}, Name: "title_text"},
+		{Key: []string{"$2dsphere:loc"}, Name: "loc_2dsphere"},
+	}, indexes)
+}
+
+// Article 值接收者的模型，多个未命名的 text 字段
+type Article struct {
+	Title string `bson:"title" index:"text"`
+	Body  string `bson:"body" index:"text"`
+}
+
+func (a Article) Unique() interface{} {
+	return bson.M{"title": a.Title}
+}
+
+type Note struct {
+	Title string `bson:"title" index:"text"`
+	Body  string `bson:"body" index:"name=idx_search,text"`
+}
+
+func (n *Note) Unique() interface{} {
+	return bson.M{"title": n.Title}
+}
+
+type Post struct {
+	Title string `bson:"title" index:"text"`
+	Body  string `bson:"body" index:"name=idx_search,text"`
+	Tags  string `bson:"tags" index:"name=idx_tags,text"`
+}
+
+func (p *Post) Unique() interface{} {
+	return bson.M{"title": p.Title}
+}
+
+func TestTagIndexesText(t *testing.T) {
+	// 未命名的 text 字段合并为一个索引
+	indexes, err := TagIndexes(Article{})
+	assert.NoError(t, err)
+	assert.Equal(t, []mgo.Index{
+		{Key: []string{"$text:title", "$text:body"}, Name: "title_text_body_text"},
+	}, indexes)
+
+	// 有命名的文本索引时并入该索引
+	indexes, err = TagIndexes(&Note{})
+	assert.NoError(t, err)
+	assert.Equal(t, []mgo.Index{
+		{Key: []string{"$text:title", "$text:body"}, Name: "idx_search"},
+	}, indexes)
+
+	// 集合只能有一个文本索引
+	_, err = TagIndexes(&Post{})
+	assert.EqualError(t, err, "ERR_DB_MULTIPLE_TEXT_INDEXES idx_search,idx_tags")
+}
+
+func TestModelType(t *testing.T) {
+	ty, err := modelType(Article{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Article", ty.Name())
+
+	ty, err = modelType(&Order{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Order", ty.Name())
+}
+
+func TestDiffIndexes(t *testing.T) {
+	desired := []mgo.Index{
+		{Key: []string{"no"}, Unique: true, Name: "no_1"},
+		{Key: []string{"ctime"}, ExpireAfter: time.Hour, Name: "ctime_1"},
+		{Key: []string{"owner"}, Name: "owner_1"},
+	}
+	existing := []mgo.Index{
+		{Key: []string{"_id"}, Name: "_id_"},
+		{Key: []string{"no"}, Unique: true, Name: "no_1"},
+		{Key: []string{"ctime"}, ExpireAfter: 2 * time.Hour, Name: "ctime_1"},
+		{Key: []string{"age"}, Name: "age_1"},
+	}
+
+	changes := diffIndexes("orders", desired, existing, false)
+	assert.Equal(t, 3, len(changes))
+	assert.Equal(t, IndexAction_RECREATE, changes[0].Action)
+	assert.Equal(t, IndexAction_CREATE, changes[1].Action)
+	assert.Equal(t, IndexAction_DROP, changes[2].Action)
+	assert.Equal(t, "age_1", changes[2].Index.Name)
+
+	changes = diffIndexes("orders", desired, existing, true)
+	assert.Equal(t, 2, len(changes))
+}
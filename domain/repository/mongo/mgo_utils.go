@@ -9,8 +9,16 @@ import(
 type DBFunc func(*mgo.Collection) error
 
 func Execute(globalSession *mgo.Session, database string, collection string, fn DBFunc) error {
+	return ExecuteWithOptions(globalSession, database, collection, nil, fn)
+}
+
+// ExecuteWithOptions 在克隆出的 session 上应用读偏好、写关注后执行，options 为 nil 时沿用全局配置
+func ExecuteWithOptions(globalSession *mgo.Session, database string, collection string, options *QueryOptions, fn DBFunc) error {
 	session := globalSession.Clone()
 	defer session.Close()
+	if err := options.apply(session); err != nil {
+		return err
+	}
 	db := session.DB(database)
 	c := db.C(collection)
 	if c == nil {
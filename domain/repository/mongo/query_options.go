@@ -0,0 +1,173 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/xxxmicro/base/domain/repository"
+	"gopkg.in/mgo.v2"
+)
+
+const (
+	ReadConcern_LOCAL    = "local"
+	ReadConcern_MAJORITY = "majority"
+)
+
+// WriteConcern 写关注，W 与 WMode 二选一，WMode 可为 "majority" 或自定义 tag
+type WriteConcern struct {
+	W        int
+	WMode    string
+	J        bool          // 等待写入 journal
+	WTimeout time.Duration // 等待复制的超时时间
+}
+
+// QueryOptions 单次调用的读写选项，未设置的项沿用全局 session 的配置
+type QueryOptions struct {
+	ReadMode     *mgo.Mode     // 读偏好，nil 表示使用全局设置
+	ReadConcern  string        // 读关注，mgo 不支持设置，只接受 local，其它级别需使用 mongodriver 仓库
+	WriteConcern *WriteConcern // 写关注
+	MaxTime      time.Duration // 查询在服务端的最长执行时间
+
+	deadline time.Time // context 的截止时间，用于设置 socket 超时
+}
+
+type QueryOption func(o *QueryOptions)
+
+// WithReadMode 设置读偏好，如 mgo.SecondaryPreferred 将分析类查询发往从节点
+func WithReadMode(mode mgo.Mode) QueryOption {
+	return func(o *QueryOptions) {
+		o.ReadMode = &mode
+	}
+}
+
+// WithReadConcern 设置读关注，mongodriver 仓库支持全部级别，mgo 仓库只支持 local
+func WithReadConcern(level string) QueryOption {
+	return func(o *QueryOptions) {
+		o.ReadConcern = level
+	}
+}
+
+func WithWriteConcern(wc *WriteConcern) QueryOption {
+	return func(o *QueryOptions) {
+		o.WriteConcern = wc
+	}
+}
+
+func WithMaxTime(d time.Duration) QueryOption {
+	return func(o *QueryOptions) {
+		o.MaxTime = d
+	}
+}
+
+type queryOptionsKey struct{}
+
+// WithQueryOptions 将读写选项放入 context，仓库方法据此设置克隆出的 session
+// 已存在的选项会被保留，后设置的项覆盖先设置的项
+func WithQueryOptions(c context.Context, opts ...QueryOption) context.Context {
+	options := QueryOptions{}
+	if parent := QueryOptionsFromContext(c); parent != nil {
+		options = *parent
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return context.WithValue(c, queryOptionsKey{}, &options)
+}
+
+// QueryOptionsFromContext 未设置时返回 nil
+func QueryOptionsFromContext(c context.Context) *QueryOptions {
+	if c == nil {
+		return nil
+	}
+	options, _ := c.Value(queryOptionsKey{}).(*QueryOptions)
+	return options
+}
+
+// contextOptions 合并 context 中的读写选项和截止时间，MaxTime 不超过剩余时间
+func contextOptions(c context.Context) *QueryOptions {
+	options := QueryOptionsFromContext(c)
+	if c == nil {
+		return options
+	}
+	deadline, ok := c.Deadline()
+	if !ok {
+		return options
+	}
+
+	o := QueryOptions{}
+	if options != nil {
+		o = *options
+	}
+	if remaining := time.Until(deadline); o.MaxTime <= 0 || remaining < o.MaxTime {
+		o.MaxTime = remaining
+	}
+	o.deadline = deadline
+	return &o
+}
+
+// apply 在克隆出的 session 上设置读偏好、写关注和 socket 超时
+func (o *QueryOptions) apply(session *mgo.Session) error {
+	if o == nil {
+		return nil
+	}
+
+	// mgo 没有设置 readConcern 的接口，非默认级别直接报错，避免静默降级，需要时使用 mongodriver 仓库
+	if o.ReadConcern != "" && o.ReadConcern != ReadConcern_LOCAL {
+		return errors.New("ERR_DB_READ_CONCERN_UNSUPPORTED")
+	}
+
+	if o.ReadMode != nil {
+		session.SetMode(*o.ReadMode, true)
+	}
+
+	if !o.deadline.IsZero() {
+		remaining := time.Until(o.deadline)
+		if remaining <= 0 {
+			return context.DeadlineExceeded
+		}
+		session.SetSocketTimeout(remaining)
+	}
+
+	if wc := o.WriteConcern; wc != nil {
+		session.SetSafe(&mgo.Safe{
+			W:        wc.W,
+			WMode:    wc.WMode,
+			J:        wc.J,
+			WTimeout: int(wc.WTimeout / time.Millisecond),
+		})
+	}
+	return nil
+}
+
+// query 为查询设置最长执行时间
+func (o *QueryOptions) query(q *mgo.Query) *mgo.Query {
+	// 不足 1ms 时 mgo 会按 0 处理，即不限制
+	if o != nil && o.MaxTime >= time.Millisecond {
+		q.SetMaxTime(o.MaxTime)
+	}
+	return q
+}
+
+// maxTimeExpired 服务端超过 maxTimeMS 时返回的错误码
+const maxTimeExpired = 50
+
+// wrapTimeoutError context 结束或超过 maxTimeMS 导致的失败返回 repository.ContextError
+func wrapTimeoutError(c context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	code := 0
+	switch e := err.(type) {
+	case *mgo.QueryError:
+		code = e.Code
+	case *mgo.LastError:
+		code = e.Code
+	}
+	if code == maxTimeExpired && (c == nil || c.Err() == nil) {
+		return &repository.ContextError{Err: repository.ErrTimeout, Cause: err}
+	}
+
+	return repository.WrapContextError(c, err)
+}
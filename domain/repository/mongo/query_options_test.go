@@ -0,0 +1,62 @@
+package mongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xxxmicro/base/domain/repository"
+	"gopkg.in/mgo.v2"
+)
+
+func TestQueryOptionsFromContext(t *testing.T) {
+	assert.Nil(t, QueryOptionsFromContext(context.Background()))
+
+	c := WithQueryOptions(context.Background(), WithReadMode(mgo.SecondaryPreferred), WithMaxTime(time.Second))
+	c = WithQueryOptions(c, WithWriteConcern(&WriteConcern{WMode: "majority", J: true}), WithMaxTime(2*time.Second))
+
+	options := QueryOptionsFromContext(c)
+	assert.Equal(t, mgo.SecondaryPreferred, *options.ReadMode)
+	assert.Equal(t, 2*time.Second, options.MaxTime)
+	assert.Equal(t, "majority", options.WriteConcern.WMode)
+	assert.True(t, options.WriteConcern.J)
+}
+
+func TestQueryOptionsReadMode(t *testing.T) {
+	// mgo.Eventual 的值为 0，同样需要生效
+	options := QueryOptionsFromContext(WithQueryOptions(context.Background(), WithReadMode(mgo.Eventual)))
+	assert.NotNil(t, options.ReadMode)
+	assert.Equal(t, mgo.Eventual, *options.ReadMode)
+
+	assert.Nil(t, QueryOptionsFromContext(WithQueryOptions(context.Background(), WithMaxTime(time.Second))).ReadMode)
+}
+
+func TestQueryOptionsReadConcern(t *testing.T) {
+	var options *QueryOptions
+	assert.NoError(t, options.apply(nil))
+
+	options = &QueryOptions{ReadConcern: ReadConcern_MAJORITY}
+	assert.EqualError(t, options.apply(nil), "ERR_DB_READ_CONCERN_UNSUPPORTED")
+}
+
+func TestContextOptions(t *testing.T) {
+	assert.Nil(t, contextOptions(context.Background()))
+
+	c, cancel := context.WithTimeout(WithQueryOptions(context.Background(), WithMaxTime(time.Minute)), time.Second)
+	defer cancel()
+
+	options := contextOptions(c)
+	assert.True(t, options.MaxTime <= time.Second)
+	assert.False(t, options.deadline.IsZero())
+	// 不修改 context 中的选项
+	assert.Equal(t, time.Minute, QueryOptionsFromContext(c).MaxTime)
+}
+
+func TestWrapTimeoutError(t *testing.T) {
+	assert.NoError(t, wrapTimeoutError(context.Background(), nil))
+	assert.Equal(t, mgo.ErrNotFound, wrapTimeoutError(context.Background(), mgo.ErrNotFound))
+
+	err := wrapTimeoutError(context.Background(), &mgo.QueryError{Code: maxTimeExpired, Message: "operation exceeded time limit"})
+	assert.True(t, repository.IsTimeout(err))
+}
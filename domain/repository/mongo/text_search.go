@@ -0,0 +1,188 @@
+package mongo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/micro/go-micro/v2/logger"
+	"github.com/xxxmicro/base/database/mongo"
+	"github.com/xxxmicro/base/domain/model"
+	"github.com/xxxmicro/base/domain/repository/mongo/reflect"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// 全文检索的相关度写入该字段，模型可声明 Score float64 `bson:"_score,omitempty"` 接收
+const textScoreField = model.SortProperty_SCORE
+
+var (
+	textIndexCache sync.Map // textIndexKey -> 文本索引字段
+	textWarned     sync.Map // textIndexKey.字段 -> 已提示过退化为正则
+)
+
+// textIndexKey 多个数据源或数据库中可能有同名集合，缓存按连接、数据库和集合区分
+type textIndexKey struct {
+	db         *mongo.DB
+	database   string
+	collection string
+}
+
+func (r *BaseRepository) textIndexKey(collection string) textIndexKey {
+	return textIndexKey{db: r.db, database: r.db.DatabaseName(), collection: collection}
+}
+
+// textIndexFields 集合文本索引覆盖的字段，结果按集合缓存
+func (r *BaseRepository) textIndexFields(collection string) (fields []string, err error) {
+	key := r.textIndexKey(collection)
+	if cached, ok := textIndexCache.Load(key); ok {
+		return cached.([]string), nil
+	}
+
+	err = r.run(collection, nil, func(c *mgo.Collection) error {
+		indexes, err := c.Indexes()
+		if err != nil && !isNamespaceNotFound(err) {
+			return err
+		}
+		fields = textFieldsOf(indexes)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	textIndexCache.Store(key, fields)
+	return
+}
+
+func textFieldsOf(indexes []mgo.Index) []string {
+	fields := []string{}
+	for _, index := range indexes {
+		for _, key := range index.Key {
+			if strings.HasPrefix(key, "$text:") {
+				fields = append(fields, strings.TrimPrefix(key, "$text:"))
+			}
+		}
+	}
+	return fields
+}
+
+// invalidateTextIndex 索引变更后清除缓存
+func (r *BaseRepository) invalidateTextIndex(collection string) {
+	textIndexCache.Delete(r.textIndexKey(collection))
+}
+
+// splitTextFilters 取出顶层 {字段: {"MATCH"|"TEXT": 关键词}} 形式的条件
+func splitTextFilters(filters map[string]interface{}) (texts map[string]model.FilterType, searches map[string]string, rest map[string]interface{}) {
+	texts = map[string]model.FilterType{}
+	searches = map[string]string{}
+	rest = map[string]interface{}{}
+
+	for k, v := range filters {
+		vMap, ok := v.(map[string]interface{})
+		if ok && len(vMap) == 1 {
+			for op, value := range vMap {
+				search, isString := value.(string)
+				filterType := model.FilterType(op)
+				if isString && (filterType == model.FilterType_MATCH || filterType == model.FilterType_TEXT) {
+					texts[k] = filterType
+					searches[k] = search
+				}
+			}
+			if _, ok := texts[k]; ok {
+				continue
+			}
+		}
+		rest[k] = v
+	}
+	return
+}
+
+// buildTextQuery 在 buildQuery 基础上把 MATCH/TEXT 条件转为 $text
+// TEXT 只要集合有文本索引即可，MATCH 需要字段在文本索引中；否则退化为正则，每个字段提示一次
+func (r *BaseRepository) buildTextQuery(ms *reflect.StructInfo, collection string, filters map[string]interface{}) (query bson.M, textActive bool, err error) {
+	texts, searches, rest := splitTextFilters(filters)
+	if len(texts) == 0 {
+		query, err = buildQuery(ms, filters)
+		return
+	}
+
+	query, err = buildQuery(ms, rest)
+	if err != nil {
+		return
+	}
+
+	indexFields, err := r.textIndexFields(collection)
+	if err != nil {
+		return
+	}
+
+	var terms []string
+	for field, filterType := range texts {
+		if _, ok := ms.FieldsMap[field]; !ok {
+			err = errors.New(fmt.Sprintf("ERR_DB_UNKNOWN_FIELD %s", field))
+			return
+		}
+
+		if useTextSearch(filterType, field, indexFields) {
+			terms = append(terms, searches[field])
+			continue
+		}
+
+		warnKey := struct {
+			textIndexKey
+			field string
+		}{r.textIndexKey(collection), field}
+		if _, warned := textWarned.LoadOrStore(warnKey, true); !warned {
+			logger.Logf(logger.WarnLevel, "mongo collection %s has no text index on %s, fallback to $regex", collection, field)
+		}
+		query[field] = bson.M{"$regex": searches[field]}
+	}
+
+	if len(terms) > 0 {
+		query["$text"] = bson.M{"$search": strings.Join(terms, " ")}
+		textActive = true
+	}
+	return
+}
+
+func useTextSearch(filterType model.FilterType, field string, indexFields []string) bool {
+	if len(indexFields) == 0 {
+		return false
+	}
+	if filterType == model.FilterType_TEXT {
+		return true
+	}
+	for _, f := range indexFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// splitScoreSort 取出按相关度排序的条件，相关度只能降序
+func splitScoreSort(sorts []*model.SortSpec) (rest []*model.SortSpec, scoreSort bool) {
+	for _, s := range sorts {
+		if s.Property == model.SortProperty_SCORE {
+			scoreSort = true
+			continue
+		}
+		rest = append(rest, s)
+	}
+	return
+}
+
+// textQuery 全文检索时投影出相关度，按相关度排序放在最前
+func textQuery(q *mgo.Query, textActive bool, scoreSort bool, sorts []string) *mgo.Query {
+	if !textActive {
+		return q.Sort(sorts...)
+	}
+
+	q = q.Select(bson.M{textScoreField: bson.M{"$meta": "textScore"}})
+	if scoreSort {
+		sorts = append([]string{"$textScore:" + textScoreField}, sorts...)
+	}
+	return q.Sort(sorts...)
+}
@@ -0,0 +1,54 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xxxmicro/base/database/mongo"
+	"github.com/xxxmicro/base/domain/model"
+	"gopkg.in/mgo.v2"
+)
+
+func TestSplitTextFilters(t *testing.T) {
+	texts, searches, rest := splitTextFilters(map[string]interface{}{
+		"title": map[string]interface{}{"TEXT": "coffee shop"},
+		"name":  map[string]interface{}{"MATCH": "latte"},
+		"age":   map[string]interface{}{"GT": 18},
+	})
+	assert.Equal(t, map[string]model.FilterType{"title": model.FilterType_TEXT, "name": model.FilterType_MATCH}, texts)
+	assert.Equal(t, "coffee shop", searches["title"])
+	assert.Equal(t, map[string]interface{}{"age": map[string]interface{}{"GT": 18}}, rest)
+}
+
+func TestUseTextSearch(t *testing.T) {
+	fields := textFieldsOf([]mgo.Index{
+		{Key: []string{"no"}},
+		{Key: []string{"$text:title", "$text:desc"}},
+	})
+	assert.Equal(t, []string{"title", "desc"}, fields)
+
+	assert.True(t, useTextSearch(model.FilterType_TEXT, "name", fields))
+	assert.True(t, useTextSearch(model.FilterType_MATCH, "title", fields))
+	assert.False(t, useTextSearch(model.FilterType_MATCH, "name", fields))
+	assert.False(t, useTextSearch(model.FilterType_TEXT, "title", nil))
+
+	rest, scoreSort := splitScoreSort([]*model.SortSpec{{Property: model.SortProperty_SCORE}, {Property: "ctime"}})
+	assert.True(t, scoreSort)
+	assert.Equal(t, 1, len(rest))
+}
+
+func TestTextIndexCacheIsolation(t *testing.T) {
+	shop := NewBaseRepository(mongo.NewDB("shop", nil))
+	report := NewBaseRepository(mongo.NewDB("report", nil))
+
+	textIndexCache.Store(shop.textIndexKey("orders"), []string{"title"})
+	defer shop.invalidateTextIndex("orders")
+
+	fields, err := shop.textIndexFields("orders")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"title"}, fields)
+
+	// 其它数据源的同名集合不能使用该缓存
+	_, ok := textIndexCache.Load(report.textIndexKey("orders"))
+	assert.False(t, ok)
+}
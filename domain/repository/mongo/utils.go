@@ -135,7 +135,8 @@ func buildMongoFilter(field *reflect.StructField, value interface{}) (bson.M, er
 			return bson.M{"$lte": vValue}, nil
 		case model.FilterType_LIKE:
 			return bson.M{"$regex": vValue}, nil
-		case model.FilterType_MATCH:
+		case model.FilterType_MATCH, model.FilterType_TEXT:
+			// 顶层的 MATCH/TEXT 在有文本索引时由 buildTextQuery 转为 $text
 			return bson.M{"$regex": vValue}, nil	
 		case model.FilterType_NOT_LIKE:
 			return bson.M{"$not": bson.M{"$regex": vValue}}, nil
@@ -147,6 +148,10 @@ func buildMongoFilter(field *reflect.StructField, value interface{}) (bson.M, er
 			return bson.M{"$exists": false}, nil
 		case model.FilterType_NOT_NULL:
 			return bson.M{"$exists": true}, nil
+		case model.FilterType_ANY, model.FilterType_ALL, model.FilterType_SIZE, model.FilterType_ELEM_MATCH:
+			return buildArrayFilter(filterType, vValue)
+		case model.FilterType_GEO_NEAR, model.FilterType_GEO_WITHIN_BOX, model.FilterType_GEO_WITHIN_POLYGON:
+			return buildGeoFilter(filterType, vValue)
 		default:
 			return nil, errors.New("ERR_MALFORMED_FILTER_TYPE")
 		}
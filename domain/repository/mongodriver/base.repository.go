@@ -0,0 +1,401 @@
+package mongodriver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/xxxmicro/base/database/mongodriver"
+	"github.com/xxxmicro/base/domain/model"
+	"github.com/xxxmicro/base/domain/repository"
+	bmongo "github.com/xxxmicro/base/domain/repository/mongo"
+	reflect2 "github.com/xxxmicro/base/domain/repository/mongo/reflect"
+	xxxmicro_opentracing "github.com/xxxmicro/base/opentracing"
+	breflect "github.com/xxxmicro/base/reflect"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BaseRepository 基于官方驱动的实现，过滤、排序、游标和集合命名与 mongo.BaseRepository 一致
+// 模型主键使用 primitive.ObjectID 代替 mgo 的 bson.ObjectId
+type BaseRepository struct {
+	db *mongodriver.DB
+}
+
+func NewBaseRepository(db *mongodriver.DB) *BaseRepository {
+	return &BaseRepository{db}
+}
+
+// NewNamedRepository 使用 registry 中名为 name 的数据源
+func NewNamedRepository(registry *mongodriver.Registry, name string) (*BaseRepository, error) {
+	db, err := registry.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewBaseRepository(db), nil
+}
+
+// collection 模型对应的集合，使用 context 中的读偏好、读关注和写关注，见 mongo.WithQueryOptions
+func (r *BaseRepository) collection(c context.Context, m model.Model) (*mongo.Collection, *reflect2.StructInfo, error) {
+	ms, err := reflect2.GetStructInfo(m, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	name := bmongo.TheNamingStrategy.Table(ms.Name)
+	return r.db.Database().Collection(name, collectionOptions(bmongo.QueryOptionsFromContext(c))), ms, nil
+}
+
+// startSpan 记录一次集合操作，见 opentracing.StartDBSpan
+func startSpan(c context.Context, method string, collection string) *xxxmicro_opentracing.DBSpan {
+	return xxxmicro_opentracing.StartDBSpan(c, "mongo", method, collection)
+}
+
+// guard 经过数据源的熔断器执行访问数据库的部分，只有 IsFailure 判断为数据库故障的错误计入熔断
+func (r *BaseRepository) guard(fn func() error) error {
+	done, err := r.db.Breaker().Allow()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	done(IsFailure(err))
+	return err
+}
+
+// wrapError context 结束或超过 maxTimeMS 导致的失败返回 repository.ContextError
+func wrapError(c context.Context, err error) error {
+	if err != nil && mongo.IsTimeout(err) && (c == nil || c.Err() == nil) {
+		return &repository.ContextError{Err: repository.ErrTimeout, Cause: err}
+	}
+	return repository.WrapContextError(c, err)
+}
+
+func (r *BaseRepository) Create(c context.Context, m model.Model) (err error) {
+	defer func() { err = wrapError(c, err) }()
+
+	coll, _, err := r.collection(c, m)
+	if err != nil {
+		return err
+	}
+
+	sp := startSpan(c, "Create", coll.Name())
+	defer func() { sp.Finish(err) }()
+
+	return r.guard(func() error {
+		_, err := coll.InsertOne(c, m)
+		return err
+	})
+}
+
+func (r *BaseRepository) Upsert(c context.Context, m model.Model) (changeInfo *repository.ChangeInfo, err error) {
+	defer func() { err = wrapError(c, err) }()
+
+	coll, _, err := r.collection(c, m)
+	if err != nil {
+		return
+	}
+
+	sp := startSpan(c, "Upsert", coll.Name())
+	sp.SetStatement(m.Unique(), nil)
+	defer func() { sp.Finish(err) }()
+
+	var res *mongo.UpdateResult
+	err = r.guard(func() (err error) {
+		res, err = coll.ReplaceOne(c, m.Unique(), m, options.Replace().SetUpsert(true))
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	changeInfo = &repository.ChangeInfo{
+		Updated:    int(res.ModifiedCount),
+		Matched:    int(res.MatchedCount),
+		UpsertedId: res.UpsertedID,
+	}
+	return
+}
+
+// Update 没有匹配的文档时返回 mongo.ErrNoDocuments，与 mgo 的 ErrNotFound 对应
+func (r *BaseRepository) Update(c context.Context, m model.Model, change interface{}) (err error) {
+	defer func() { err = wrapError(c, err) }()
+
+	coll, _, err := r.collection(c, m)
+	if err != nil {
+		return err
+	}
+
+	sp := startSpan(c, "Update", coll.Name())
+	sp.SetStatement(m.Unique(), nil)
+	defer func() { sp.Finish(err) }()
+
+	var res *mongo.UpdateResult
+	err = r.guard(func() (err error) {
+		res, err = coll.UpdateOne(c, m.Unique(), bson.M{
+			"$set": change,
+		})
+		return
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *BaseRepository) FindOne(c context.Context, m model.Model) (err error) {
+	defer func() { err = wrapError(c, err) }()
+
+	coll, _, err := r.collection(c, m)
+	if err != nil {
+		return err
+	}
+
+	sp := startSpan(c, "FindOne", coll.Name())
+	sp.SetStatement(m.Unique(), nil)
+	defer func() { sp.Finish(err) }()
+
+	opts := options.FindOne()
+	if d := maxTime(c); d > 0 {
+		opts.SetMaxTime(d)
+	}
+	return r.guard(func() error {
+		return coll.FindOne(c, m.Unique(), opts).Decode(m)
+	})
+}
+
+func (r *BaseRepository) Delete(c context.Context, m model.Model) (err error) {
+	defer func() { err = wrapError(c, err) }()
+
+	coll, _, err := r.collection(c, m)
+	if err != nil {
+		return err
+	}
+
+	sp := startSpan(c, "Delete", coll.Name())
+	sp.SetStatement(m.Unique(), nil)
+	defer func() { sp.Finish(err) }()
+
+	var res *mongo.DeleteResult
+	err = r.guard(func() (err error) {
+		res, err = coll.DeleteOne(c, m.Unique())
+		return
+	})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *BaseRepository) Page(c context.Context, m model.Model, query *model.PageQuery, resultPtr interface{}) (total int, pageCount int, err error) {
+	defer func() { err = wrapError(c, err) }()
+
+	coll, ms, err := r.collection(c, m)
+	if err != nil {
+		return
+	}
+
+	sp := startSpan(c, "Page", coll.Name())
+	defer func() { sp.Finish(err) }()
+
+	filters, err := bmongo.BuildQuery(ms, query.Filters)
+	if err != nil {
+		return
+	}
+
+	filters, sortSpecs, err := bmongo.ApplyGeoSort(ms, filters, query.Sort)
+	if err != nil {
+		return
+	}
+
+	sorts, err := bmongo.BuildSort(ms, sortSpecs)
+	if err != nil {
+		return
+	}
+	sp.SetStatement(filters, sorts)
+
+	countOpts := options.Count()
+	if d := maxTime(c); d > 0 {
+		countOpts.SetMaxTime(d)
+	}
+	var count int64
+	err = r.guard(func() (err error) {
+		count, err = coll.CountDocuments(c, bmongo.GeoCountQuery(filters), countOpts)
+		return
+	})
+	if err != nil {
+		return
+	}
+	total = int(count)
+
+	pageSize := query.PageSize
+	if pageSize > 1000 {
+		pageSize = 1000
+	} else if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	pageNo := query.PageNo
+	if pageNo <= 0 {
+		pageNo = 1
+	}
+
+	pageCount = total / pageSize
+	if total%pageSize != 0 {
+		pageCount++
+	}
+
+	opts := options.Find().
+		SetSkip(int64((pageNo - 1) * pageSize)).
+		SetLimit(int64(pageSize))
+	if len(sorts) > 0 {
+		opts.SetSort(sortDocument(sorts...))
+	}
+	if d := maxTime(c); d > 0 {
+		opts.SetMaxTime(d)
+	}
+
+	err = r.guard(func() error {
+		cursor, err := coll.Find(c, filters, opts)
+		if err != nil {
+			return err
+		}
+		return cursor.All(c, resultPtr)
+	})
+	if err != nil {
+		return
+	}
+	sp.SetCount(breflect.SlicePtrLen(resultPtr))
+	return
+}
+
+func (r *BaseRepository) Cursor(c context.Context, query *model.CursorQuery, m model.Model, resultPtr interface{}) (extra *model.CursorExtra, err error) {
+	defer func() { err = wrapError(c, err) }()
+
+	coll, ms, err := r.collection(c, m)
+	if err != nil {
+		return
+	}
+
+	sp := startSpan(c, "Cursor", coll.Name())
+	defer func() { sp.Finish(err) }()
+
+	filters, err := bmongo.BuildQuery(ms, query.Filters)
+	if err != nil {
+		return
+	}
+
+	cursorProp, ok := ms.FieldsMap[query.CursorSort.Property]
+	if !ok {
+		err = errors.New(fmt.Sprintf("cursor prop(%s) not found", query.CursorSort.Property))
+		return
+	}
+
+	cursorFilter, sort, reverse, err := bmongo.CursorFilter(ms, query)
+	if err != nil {
+		return
+	}
+
+	var finalFilters interface{} = filters
+	if cursorFilter != nil {
+		finalFilters = bson.M{"$and": []interface{}{cursorFilter, filters}}
+	}
+
+	size := query.Size
+	if size > 1000 {
+		size = 1000
+	} else if size <= 0 {
+		size = 20
+	}
+
+	sp.SetStatement(finalFilters, sort)
+
+	opts := options.Find().
+		SetLimit(int64(size)).
+		SetSort(sortDocument(sort))
+	if d := maxTime(c); d > 0 {
+		opts.SetMaxTime(d)
+	}
+
+	err = r.guard(func() error {
+		cursor, err := coll.Find(c, finalFilters, opts)
+		if err != nil {
+			return err
+		}
+		return cursor.All(c, resultPtr)
+	})
+	if err != nil {
+		return
+	}
+
+	var minCursor interface{} = nil
+	var maxCursor interface{} = nil
+
+	count := breflect.SlicePtrLen(resultPtr)
+	sp.SetCount(count)
+	if count > 0 {
+		if reverse {
+			breflect.SlicePtrReverse(resultPtr)
+		}
+
+		minCursorModel := breflect.SlicePtrIndexOf(resultPtr, 0)
+		minCursor, err = breflect.GetStructField(minCursorModel, cursorProp.Name)
+		if err != nil {
+			return
+		}
+
+		maxCursorModel := breflect.SlicePtrIndexOf(resultPtr, count-1)
+		maxCursor, err = breflect.GetStructField(maxCursorModel, cursorProp.Name)
+		if err != nil {
+			return
+		}
+	}
+
+	extra = &model.CursorExtra{
+		Direction: query.Direction,
+		Size:      size,
+		HasMore:   count == size,
+		MinCursor: minCursor,
+		MaxCursor: maxCursor,
+	}
+
+	return
+}
+
+// EnsureIndexes 沿用 mgo.Index 的声明，模型迁移时无需修改
+func (r *BaseRepository) EnsureIndexes(m bmongo.Indexed) error {
+	return r.EnsureIndexesContext(context.Background(), m)
+}
+
+// EnsureIndexesContext 同 EnsureIndexes，使用 c 中的 span 和截止时间
+func (r *BaseRepository) EnsureIndexesContext(c context.Context, m bmongo.Indexed) (err error) {
+	defer func() { err = wrapError(c, err) }()
+
+	coll := r.db.Database().Collection(bmongo.TheNamingStrategy.Table(reflect.TypeOf(m).Elem().Name()))
+
+	sp := startSpan(c, "EnsureIndexes", coll.Name())
+	defer func() {
+		sp.SetCount(len(m.Indexes()))
+		sp.Finish(err)
+	}()
+
+	models := make([]mongo.IndexModel, 0, len(m.Indexes()))
+	for _, i := range m.Indexes() {
+		models = append(models, indexModel(i))
+	}
+	if len(models) == 0 {
+		return
+	}
+
+	err = r.guard(func() error {
+		_, err := coll.Indexes().CreateMany(c, models)
+		return err
+	})
+	return
+}
@@ -0,0 +1,35 @@
+package mongodriver
+
+import (
+	"errors"
+
+	"github.com/xxxmicro/base/database/breaker"
+	bmongo "github.com/xxxmicro/base/domain/repository/mongo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// IsFailure 熔断器的失败判断，通用部分见 breaker.IsFailure
+// 官方驱动的网络错误、选择节点失败、client 已断开，以及命令和 write concern 返回的故障错误码计为失败
+func IsFailure(err error) bool {
+	return breaker.IsFailure(err, isDriverFailure)
+}
+
+func isDriverFailure(err error) bool {
+	if mongo.IsTimeout(err) || mongo.IsNetworkError(err) {
+		return true
+	}
+	if errors.Is(err, mongo.ErrClientDisconnected) || errors.As(err, &topology.ServerSelectionError{}) {
+		return true
+	}
+
+	var ce mongo.CommandError
+	if errors.As(err, &ce) {
+		return bmongo.IsFailureCode(int(ce.Code))
+	}
+	var we mongo.WriteException
+	if errors.As(err, &we) && we.WriteConcernError != nil {
+		return bmongo.IsFailureCode(we.WriteConcernError.Code)
+	}
+	return false
+}
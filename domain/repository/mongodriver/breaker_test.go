@@ -0,0 +1,22 @@
+package mongodriver
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+func TestIsFailure(t *testing.T) {
+	assert.False(t, IsFailure(mongo.ErrNoDocuments))
+	assert.False(t, IsFailure(mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000}}}))
+	assert.False(t, IsFailure(mongo.CommandError{Code: 2, Name: "BadValue"}))
+
+	assert.True(t, IsFailure(mongo.CommandError{Code: 189, Name: "PrimarySteppedDown"}))
+	assert.True(t, IsFailure(mongo.CommandError{Labels: []string{"NetworkError"}}))
+	assert.True(t, IsFailure(mongo.WriteException{WriteConcernError: &mongo.WriteConcernError{Code: 64}}))
+	assert.True(t, IsFailure(topology.ServerSelectionError{Wrapped: errors.New("no servers")}))
+	assert.True(t, IsFailure(mongo.ErrClientDisconnected))
+}
@@ -0,0 +1,98 @@
+package mongodriver
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2"
+)
+
+// sortDocument 将 mgo 形式的排序字段(-name 表示降序)转为有序的排序文档
+func sortDocument(fields ...string) bson.D {
+	sort := bson.D{}
+	for _, field := range fields {
+		if field == "" {
+			continue
+		}
+		if strings.HasPrefix(field, "-") {
+			sort = append(sort, bson.E{Key: field[1:], Value: -1})
+		} else {
+			sort = append(sort, bson.E{Key: strings.TrimPrefix(field, "+"), Value: 1})
+		}
+	}
+	return sort
+}
+
+// indexKey 解析 mgo 的索引字段写法: -name、$text:name、$2dsphere:loc、$2d:loc、@loc、$hashed:name
+func indexKey(key string) bson.E {
+	for _, kind := range []string{"text", "2dsphere", "2d", "hashed"} {
+		prefix := "$" + kind + ":"
+		if strings.HasPrefix(key, prefix) {
+			return bson.E{Key: key[len(prefix):], Value: kind}
+		}
+	}
+	if strings.HasPrefix(key, "@") {
+		return bson.E{Key: key[1:], Value: "2d"}
+	}
+	if strings.HasPrefix(key, "-") {
+		return bson.E{Key: key[1:], Value: -1}
+	}
+	return bson.E{Key: strings.TrimPrefix(key, "+"), Value: 1}
+}
+
+func indexModel(i mgo.Index) mongo.IndexModel {
+	keys := bson.D{}
+	for _, key := range i.Key {
+		keys = append(keys, indexKey(key))
+	}
+
+	opts := options.Index()
+	if i.Name != "" {
+		opts.SetName(i.Name)
+	}
+	if i.Unique {
+		opts.SetUnique(true)
+	}
+	if i.Sparse {
+		opts.SetSparse(true)
+	}
+	if i.ExpireAfter > 0 {
+		opts.SetExpireAfterSeconds(int32(i.ExpireAfter.Seconds()))
+	}
+	if i.Bits > 0 {
+		opts.SetBits(int32(i.Bits))
+	}
+	if i.Minf != 0 || i.Maxf != 0 {
+		opts.SetMin(i.Minf).SetMax(i.Maxf)
+	} else if i.Min != 0 || i.Max != 0 {
+		opts.SetMin(float64(i.Min)).SetMax(float64(i.Max))
+	}
+	if i.DefaultLanguage != "" {
+		opts.SetDefaultLanguage(i.DefaultLanguage)
+	}
+	if i.LanguageOverride != "" {
+		opts.SetLanguageOverride(i.LanguageOverride)
+	}
+	if len(i.Weights) > 0 {
+		weights := bson.D{}
+		for field, weight := range i.Weights {
+			weights = append(weights, bson.E{Key: field, Value: weight})
+		}
+		opts.SetWeights(weights)
+	}
+	if i.Collation != nil {
+		opts.SetCollation(&options.Collation{
+			Locale:          i.Collation.Locale,
+			CaseLevel:       i.Collation.CaseLevel,
+			CaseFirst:       i.Collation.CaseFirst,
+			Strength:        i.Collation.Strength,
+			NumericOrdering: i.Collation.NumericOrdering,
+			Alternate:       i.Collation.Alternate,
+			Backwards:       i.Collation.Backwards,
+		})
+	}
+
+	return mongo.IndexModel{Keys: keys, Options: opts}
+}
@@ -0,0 +1,28 @@
+package mongodriver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"gopkg.in/mgo.v2"
+)
+
+func TestSortDocument(t *testing.T) {
+	assert.Equal(t, bson.D{{Key: "ctime", Value: -1}, {Key: "name", Value: 1}}, sortDocument("-ctime", "name"))
+}
+
+func TestIndexModel(t *testing.T) {
+	index := indexModel(mgo.Index{
+		Key:         []string{"-ctime", "$2dsphere:location", "$text:name"},
+		Unique:      true,
+		ExpireAfter: time.Hour,
+	})
+
+	assert.Equal(t, bson.D{
+		{Key: "ctime", Value: -1},
+		{Key: "location", Value: "2dsphere"},
+		{Key: "name", Value: "text"},
+	}, index.Keys)
+}
@@ -0,0 +1,46 @@
+package mongodriver
+
+import (
+	"context"
+	"time"
+
+	"github.com/xxxmicro/base/database/mongodriver"
+	bmongo "github.com/xxxmicro/base/domain/repository/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// collectionOptions 将 context 中的读写选项(见 mongo.WithQueryOptions)转换为集合选项，未设置的项沿用客户端配置
+func collectionOptions(o *bmongo.QueryOptions) *options.CollectionOptions {
+	opts := options.Collection()
+	if o == nil {
+		return opts
+	}
+
+	if o.ReadMode != nil {
+		opts.SetReadPreference(mongodriver.ReadPreference(*o.ReadMode))
+	}
+	if o.ReadConcern != "" {
+		opts.SetReadConcern(&readconcern.ReadConcern{Level: o.ReadConcern})
+	}
+	if wc := o.WriteConcern; wc != nil {
+		concern := &writeconcern.WriteConcern{W: wc.W, WTimeout: wc.WTimeout}
+		if wc.WMode != "" {
+			concern.W = wc.WMode
+		}
+		if wc.J {
+			concern.Journal = &wc.J
+		}
+		opts.SetWriteConcern(concern)
+	}
+	return opts
+}
+
+// maxTime 查询在服务端的最长执行时间，0 表示不限制，截止时间由驱动根据 context 设置
+func maxTime(c context.Context) time.Duration {
+	if o := bmongo.QueryOptionsFromContext(c); o != nil {
+		return o.MaxTime
+	}
+	return 0
+}
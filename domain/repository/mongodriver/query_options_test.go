@@ -0,0 +1,48 @@
+package mongodriver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	bmongo "github.com/xxxmicro/base/domain/repository/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"gopkg.in/mgo.v2"
+)
+
+func TestCollectionOptions(t *testing.T) {
+	opts := collectionOptions(nil)
+	assert.Nil(t, opts.ReadConcern)
+	assert.Nil(t, opts.ReadPreference)
+
+	c := bmongo.WithQueryOptions(context.Background(),
+		bmongo.WithReadMode(mgo.Eventual),
+		bmongo.WithReadConcern(bmongo.ReadConcern_MAJORITY),
+		bmongo.WithWriteConcern(&bmongo.WriteConcern{WMode: "majority", J: true, WTimeout: time.Second}),
+		bmongo.WithMaxTime(2*time.Second),
+	)
+	opts = collectionOptions(bmongo.QueryOptionsFromContext(c))
+	assert.Equal(t, "majority", opts.ReadConcern.Level)
+	assert.Equal(t, readpref.NearestMode, opts.ReadPreference.Mode())
+	assert.Equal(t, "majority", opts.WriteConcern.W)
+	assert.True(t, *opts.WriteConcern.Journal)
+	assert.Equal(t, time.Second, opts.WriteConcern.WTimeout)
+	assert.Equal(t, 2*time.Second, maxTime(c))
+}
+
+func TestCollectionOptionsReadMode(t *testing.T) {
+	modes := map[mgo.Mode]readpref.Mode{
+		mgo.Primary:            readpref.PrimaryMode,
+		mgo.PrimaryPreferred:   readpref.PrimaryPreferredMode,
+		mgo.Secondary:          readpref.SecondaryMode,
+		mgo.SecondaryPreferred: readpref.SecondaryPreferredMode,
+		mgo.Monotonic:          readpref.SecondaryPreferredMode,
+		mgo.Nearest:            readpref.NearestMode,
+	}
+	for mode, want := range modes {
+		c := bmongo.WithQueryOptions(context.Background(), bmongo.WithReadMode(mode))
+		opts := collectionOptions(bmongo.QueryOptionsFromContext(c))
+		assert.Equal(t, want, opts.ReadPreference.Mode(), "mode %d", mode)
+	}
+}
@@ -0,0 +1,250 @@
+package mongodriver
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/xxxmicro/base/domain/model"
+	bmongo "github.com/xxxmicro/base/domain/repository/mongo"
+	"github.com/xxxmicro/base/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	mgobson "gopkg.in/mgo.v2/bson"
+)
+
+type OperationType string
+
+const (
+	OperationType_INSERT  OperationType = "insert"
+	OperationType_UPDATE  OperationType = "update"
+	OperationType_REPLACE OperationType = "replace"
+	OperationType_DELETE  OperationType = "delete"
+)
+
+// ChangeEvent 集合变更事件
+type ChangeEvent struct {
+	OperationType OperationType
+	DocumentKey   bson.M      // {"_id": ...}
+	Document      interface{} // 与模型同类型的指针，delete 事件为空
+	UpdatedFields bson.M      // update 事件修改的字段
+	RemovedFields []string    // update 事件删除的字段
+	ClusterTime   primitive.Timestamp
+}
+
+// ChangeHandler 返回错误时停止监听，已处理事件的 resume token 已保存，重启后从下一个事件继续
+type ChangeHandler func(c context.Context, event *ChangeEvent) error
+
+type WatchOptions struct {
+	Store          store.Store     // 保存 resume token，为空时不保存，重启后只接收新事件
+	Key            string          // resume token 的 key，默认 mongo_watch:<db>.<collection>
+	FullDocument   bool            // update 事件带上完整文档，指定过滤条件时自动开启
+	OperationTypes []OperationType // 默认 insert/update/replace/delete
+}
+
+type WatchOption func(o *WatchOptions)
+
+func WatchStore(s store.Store) WatchOption {
+	return func(o *WatchOptions) {
+		o.Store = s
+	}
+}
+
+func WatchKey(key string) WatchOption {
+	return func(o *WatchOptions) {
+		o.Key = key
+	}
+}
+
+func WatchFullDocument() WatchOption {
+	return func(o *WatchOptions) {
+		o.FullDocument = true
+	}
+}
+
+func WatchOperationTypes(types ...OperationType) WatchOption {
+	return func(o *WatchOptions) {
+		o.OperationTypes = types
+	}
+}
+
+type changeDocument struct {
+	ID                bson.Raw            `bson:"_id"`
+	OperationType     OperationType       `bson:"operationType"`
+	DocumentKey       bson.M              `bson:"documentKey"`
+	FullDocument      bson.Raw            `bson:"fullDocument"`
+	ClusterTime       primitive.Timestamp `bson:"clusterTime"`
+	UpdateDescription struct {
+		UpdatedFields bson.M   `bson:"updatedFields"`
+		RemovedFields []string `bson:"removedFields"`
+	} `bson:"updateDescription"`
+}
+
+// Watch 监听模型集合的变更，阻塞直到 c 结束或出错
+// filters 与 Page 的过滤条件相同，作用于变更后的文档，delete 事件不做过滤
+func (r *BaseRepository) Watch(c context.Context, m model.Model, filters map[string]interface{}, handler ChangeHandler, opts ...WatchOption) (err error) {
+	coll, ms, err := r.collection(c, m)
+	if err != nil {
+		return err
+	}
+
+	// 整个监听一个 span，c 正常结束不记为错误
+	events := 0
+	sp := startSpan(c, "Watch", coll.Name())
+	sp.SetStatement(filters, nil)
+	defer func() {
+		sp.SetCount(events)
+		if err == c.Err() {
+			sp.Finish(nil)
+			return
+		}
+		sp.Finish(err)
+	}()
+
+	options := WatchOptions{
+		Key:            fmt.Sprintf("mongo_watch:%s.%s", r.db.DatabaseName(), coll.Name()),
+		OperationTypes: []OperationType{OperationType_INSERT, OperationType_UPDATE, OperationType_REPLACE, OperationType_DELETE},
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	query, err := bmongo.BuildQuery(ms, filters)
+	if err != nil {
+		return err
+	}
+	if len(query) > 0 {
+		options.FullDocument = true
+	}
+
+	streamOpts := changeStreamOptions(options)
+	if options.Store != nil {
+		token, err := loadResumeToken(options.Store, options.Key)
+		if err != nil {
+			return err
+		}
+		if token != nil {
+			streamOpts.SetResumeAfter(token)
+		}
+	}
+
+	// 只有打开 change stream 经过熔断器，监听期间的错误由调用方重试
+	var stream *mongo.ChangeStream
+	err = r.guard(func() (err error) {
+		stream, err = coll.Watch(c, buildWatchPipeline(query, options.OperationTypes), streamOpts)
+		return
+	})
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+
+	docType := reflect.TypeOf(m).Elem()
+	for stream.Next(c) {
+		var change changeDocument
+		if err = stream.Decode(&change); err != nil {
+			return err
+		}
+
+		event := &ChangeEvent{
+			OperationType: change.OperationType,
+			DocumentKey:   change.DocumentKey,
+			UpdatedFields: change.UpdateDescription.UpdatedFields,
+			RemovedFields: change.UpdateDescription.RemovedFields,
+			ClusterTime:   change.ClusterTime,
+		}
+		if len(change.FullDocument) > 0 {
+			doc := reflect.New(docType).Interface()
+			if err = bson.Unmarshal(change.FullDocument, doc); err != nil {
+				return err
+			}
+			event.Document = doc
+		}
+
+		if err = handler(c, event); err != nil {
+			return err
+		}
+		events++
+
+		if options.Store != nil {
+			if err = saveResumeToken(options.Store, options.Key, stream.ResumeToken()); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = stream.Err(); err != nil {
+		return err
+	}
+	return c.Err()
+}
+
+func changeStreamOptions(o WatchOptions) *options.ChangeStreamOptions {
+	streamOpts := options.ChangeStream()
+	if o.FullDocument {
+		streamOpts.SetFullDocument(options.UpdateLookup)
+	}
+	return streamOpts
+}
+
+// buildWatchPipeline 过滤条件中的字段加上 fullDocument. 前缀
+func buildWatchPipeline(query mgobson.M, types []OperationType) []bson.M {
+	match := bson.M{"operationType": bson.M{"$in": types}}
+	if len(query) > 0 {
+		match["$or"] = []bson.M{
+			{"operationType": OperationType_DELETE},
+			prefixFilter(query, "fullDocument."),
+		}
+	}
+	return []bson.M{{"$match": match}}
+}
+
+func prefixFilter(filter mgobson.M, prefix string) bson.M {
+	prefixed := bson.M{}
+	for k, v := range filter {
+		if !strings.HasPrefix(k, "$") {
+			prefixed[prefix+k] = v
+			continue
+		}
+
+		// $and/$or/$nor 的子条件
+		subFilters, ok := v.([]mgobson.M)
+		if !ok {
+			prefixed[k] = v
+			continue
+		}
+		subPrefixed := make([]bson.M, len(subFilters))
+		for i, sub := range subFilters {
+			subPrefixed[i] = prefixFilter(sub, prefix)
+		}
+		prefixed[k] = subPrefixed
+	}
+	return prefixed
+}
+
+func loadResumeToken(s store.Store, key string) (bson.Raw, error) {
+	record, err := s.Get(key)
+	if err == store.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if record == nil || len(record.Value) == 0 {
+		return nil, nil
+	}
+	return bson.Raw(record.Value), nil
+}
+
+func saveResumeToken(s store.Store, key string, token bson.Raw) error {
+	if len(token) == 0 {
+		return nil
+	}
+	return s.Set(&store.Record{
+		Key:   key,
+		Value: []byte(token),
+	})
+}
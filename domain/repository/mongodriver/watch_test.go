@@ -0,0 +1,43 @@
+package mongodriver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xxxmicro/base/store/memory"
+	"go.mongodb.org/mongo-driver/bson"
+	mgobson "gopkg.in/mgo.v2/bson"
+)
+
+func TestBuildWatchPipeline(t *testing.T) {
+	pipeline := buildWatchPipeline(mgobson.M{
+		"status": mgobson.M{"$eq": 1},
+		"$or":    []mgobson.M{{"age": mgobson.M{"$gt": 18}}},
+	}, []OperationType{OperationType_INSERT, OperationType_DELETE})
+
+	assert.Equal(t, []bson.M{{"$match": bson.M{
+		"operationType": bson.M{"$in": []OperationType{OperationType_INSERT, OperationType_DELETE}},
+		"$or": []bson.M{
+			{"operationType": OperationType_DELETE},
+			{
+				"fullDocument.status": mgobson.M{"$eq": 1},
+				"$or":                 []bson.M{{"fullDocument.age": mgobson.M{"$gt": 18}}},
+			},
+		},
+	}}}, pipeline)
+}
+
+func TestResumeToken(t *testing.T) {
+	s := memory.NewStore()
+
+	token, err := loadResumeToken(s, "mongo_watch:test.users")
+	assert.NoError(t, err)
+	assert.Nil(t, token)
+
+	raw, _ := bson.Marshal(bson.M{"_data": "8263"})
+	assert.NoError(t, saveResumeToken(s, "mongo_watch:test.users", raw))
+
+	token, err = loadResumeToken(s, "mongo_watch:test.users")
+	assert.NoError(t, err)
+	assert.Equal(t, "8263", token.Lookup("_data").StringValue())
+}
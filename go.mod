@@ -19,11 +19,13 @@ require (
 	github.com/patrickmn/go-cache v2.1.0+incompatible
 	github.com/petersunbag/coven v1.0.0
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.1.0
 	github.com/satori/go.uuid v1.2.0
 	github.com/stretchr/testify v1.4.0
 	github.com/uber/jaeger-client-go v2.25.0+incompatible
 	github.com/uber/jaeger-lib v2.2.0+incompatible
 	github.com/xxl-job/go-client v0.0.4
 	github.com/xxxmicro/go-micro-apollo-plugin v1.1.4
+	go.mongodb.org/mongo-driver v1.17.6
 	gopkg.in/mgo.v2 v2.0.0-20190816093944-a6b53ec6cb22
 )
@@ -0,0 +1,120 @@
+package opentracing
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// DBSpan 数据库操作的 span，context 中没有父 span 时不记录
+type DBSpan struct {
+	span opentracing.Span
+}
+
+// StartDBSpan 以 context 中的 span 为父 span 创建数据库操作的 span，名称为 <dbType>.<method> <table>
+// tag 与 gorm 的 tracing 回调保持一致
+func StartDBSpan(c context.Context, dbType string, method string, table string) *DBSpan {
+	if c == nil {
+		return &DBSpan{}
+	}
+	parentSpan := opentracing.SpanFromContext(c)
+	if parentSpan == nil {
+		return &DBSpan{}
+	}
+
+	sp := GlobalTracerWrapper().StartSpan(dbType+"."+method+" "+table, opentracing.ChildOf(parentSpan.Context()))
+	ext.DBType.Set(sp, dbType)
+	sp.SetTag("db.table", table)
+	sp.SetTag("db.method", method)
+	return &DBSpan{span: sp}
+}
+
+// SetStatement 记录查询语句，filter 中的值统一替换为 ?，sort 只包含字段名，原样记录
+func (s *DBSpan) SetStatement(filter interface{}, sort interface{}) {
+	if s.span == nil {
+		return
+	}
+	statement := map[string]interface{}{
+		"filter": Sanitize(filter),
+	}
+	if sort != nil {
+		statement["sort"] = sort
+	}
+	data, err := json.Marshal(statement)
+	if err != nil {
+		return
+	}
+	ext.DBStatement.Set(s.span, string(data))
+}
+
+func (s *DBSpan) SetTag(key string, value interface{}) {
+	if s.span == nil {
+		return
+	}
+	s.span.SetTag(key, value)
+}
+
+func (s *DBSpan) SetCount(count int) {
+	if s.span == nil {
+		return
+	}
+	s.span.SetTag("db.count", count)
+}
+
+// Finish 结束 span 并原样返回 err，方便 return sp.Finish(err)
+func (s *DBSpan) Finish(err error) error {
+	if s.span == nil {
+		return err
+	}
+	ext.Error.Set(s.span, err != nil)
+	s.span.SetTag("db.err", err != nil)
+	if err != nil {
+		s.span.LogFields(log.Error(err))
+	}
+	s.span.Finish()
+	return err
+}
+
+// Sanitize 保留查询结构和字段名，将所有值替换为 ?，避免敏感数据进入 trace
+func Sanitize(statement interface{}) interface{} {
+	return sanitize(reflect.ValueOf(statement))
+}
+
+func sanitize(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return sanitize(v.Elem())
+	case reflect.Map:
+		result := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, ok := iter.Key().Interface().(string)
+			if !ok {
+				continue
+			}
+			result[key] = sanitize(iter.Value())
+		}
+		return result
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return "?"
+		}
+		result := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			result = append(result, sanitize(v.Index(i)))
+		}
+		return result
+	}
+	return "?"
+}
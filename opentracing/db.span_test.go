@@ -0,0 +1,54 @@
+package opentracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitize(t *testing.T) {
+	filter := map[string]interface{}{
+		"name": "吕布",
+		"$and": []map[string]interface{}{
+			{"age": map[string]interface{}{"$gt": 18}},
+			{"tags": map[string]interface{}{"$in": []string{"a", "b"}}},
+		},
+	}
+
+	data, err := json.Marshal(Sanitize(filter))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"$and":[{"age":{"$gt":"?"}},{"tags":{"$in":["?","?"]}}],"name":"?"}`, string(data))
+}
+
+func TestDBSpan(t *testing.T) {
+	tracer := mocktracer.New()
+	GlobalTracerWrapper().Wrap(tracer)
+	defer GlobalTracerWrapper().Wrap(opentracing.NoopTracer{})
+
+	// 没有父 span 时不记录
+	sp := StartDBSpan(context.Background(), "mongo", "FindOne", "users")
+	sp.SetStatement(map[string]interface{}{"_id": "1"}, nil)
+	assert.NoError(t, sp.Finish(nil))
+	assert.Equal(t, 0, len(tracer.FinishedSpans()))
+
+	parent := tracer.StartSpan("handler")
+	c := opentracing.ContextWithSpan(context.Background(), parent)
+
+	sp = StartDBSpan(c, "mongo", "Page", "users")
+	sp.SetStatement(map[string]interface{}{"name": "吕布"}, []string{"-ctime"})
+	sp.SetCount(3)
+	failure := errors.New("boom")
+	assert.Equal(t, failure, sp.Finish(failure))
+
+	spans := tracer.FinishedSpans()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, "mongo.Page users", spans[0].OperationName)
+	assert.Equal(t, `{"filter":{"name":"?"},"sort":["-ctime"]}`, spans[0].Tag("db.statement"))
+	assert.Equal(t, 3, spans[0].Tag("db.count"))
+	assert.Equal(t, true, spans[0].Tag("error"))
+}
@@ -1,29 +1,105 @@
 package cache
 
 import(
+	"errors"
+	"fmt"
 	"github.com/xxxmicro/base/store"
 	"github.com/xxxmicro/base/store/redis"
+	"github.com/xxxmicro/base/database/datasource"
+	"github.com/xxxmicro/base/database/pool"
+	bredis "github.com/xxxmicro/base/database/redis"
 	"github.com/micro/go-micro/v2/config"
 )
 
-func NewCacheProvider(config config.Config) Cache {
+func NewCacheProvider(config config.Config) (Cache, error) {
+	return NewCache(config, datasource.Default)
+}
+
+// NewCache 按数据源名称创建缓存，default 读取 redis 下的配置，其它读取 redis.sources.<name>
+func NewCache(config config.Config, name string) (Cache, error) {
 	t := config.Get("cache", "type").String("redis")
 	switch t {
 		case "redis":
-			return newRedisCache(config)
+			return newRedisCache(config, name)
 	}
 	
-	return newRedisCache(config)
+	return newRedisCache(config, name)
 }
 
-func newRedisCache(config config.Config) Cache {
-	addrs := config.Get("redis", "addrs").StringSlice(nil)
+// newRedisCache 连接池读取 <path>.pool 和 <path>.tls 配置，非法配置在启动时返回错误
+func newRedisCache(config config.Config, name string) (Cache, error) {
+	path := datasource.Path("redis", name)
+	get := func(key string) []string {
+		return append(append([]string{}, path...), key)
+	}
+
+	addrs := config.Get(get("addrs")...).StringSlice(nil)
+
+	poolOptions, err := pool.Config(config, bredis.DefaultPoolOptions(), path...)
+	if err != nil {
+		return nil, err
+	}
 
 	options := make([]store.Option, 0)
 	options = append(options, store.Addrs(addrs...))
+	options = append(options, store.Password(config.Get(get("password")...).String("")))
+	options = append(options, redis.WithPool(poolOptions))
+	if name != datasource.Default {
+		options = append(options, redis.WithName(name))
+	}
 
 	store := NewStore(redis.NewStore(options...))
-	store.Init()
+	if err := store.Init(); err != nil {
+		return nil, err
+	}
+
+	return store.(Cache), nil
+}
+
+// Registry 按名称获取配置的缓存，例如 redis.sources.session 和 redis.sources.rank
+type Registry struct {
+	names  []string
+	caches map[string]Cache
+}
 
-	return store.(Cache)
-}
\ No newline at end of file
+// NewRegistryProvider 创建所有配置的缓存，任一失败时关闭已创建的缓存并返回错误
+func NewRegistryProvider(config config.Config) (*Registry, error) {
+	names := datasource.Names(config, "redis", "addrs")
+	if len(names) == 0 {
+		return nil, errors.New("no redis source configured")
+	}
+
+	r := &Registry{names: names, caches: make(map[string]Cache, len(names))}
+	for _, name := range names {
+		c, err := NewCache(config, name)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("redis source %s: %w", name, err)
+		}
+		r.caches[name] = c
+	}
+	return r, nil
+}
+
+// Get 名称未配置时返回 datasource.ErrNotFound
+func (r *Registry) Get(name string) (Cache, error) {
+	c, ok := r.caches[name]
+	if !ok {
+		return nil, fmt.Errorf("%w redis %s", datasource.ErrNotFound, name)
+	}
+	return c, nil
+}
+
+func (r *Registry) Names() []string {
+	return r.names
+}
+
+func (r *Registry) Close() error {
+	var err error
+	for _, c := range r.caches {
+		if e := c.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
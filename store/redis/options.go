@@ -0,0 +1,30 @@
+package redis
+
+import (
+	"context"
+	"github.com/xxxmicro/base/database/pool"
+	"github.com/xxxmicro/base/store"
+)
+
+type poolKey struct{}
+type nameKey struct{}
+
+// WithPool 连接池配置，未设置时使用 database/redis.DefaultPoolOptions
+func WithPool(o pool.Options) store.Option {
+	return func(opts *store.Options) {
+		if opts.Context == nil {
+			opts.Context = context.Background()
+		}
+		opts.Context = context.WithValue(opts.Context, poolKey{}, o)
+	}
+}
+
+// WithName 数据源名称，用于区分连接池指标，见 database/redis.RegisterPool
+func WithName(name string) store.Option {
+	return func(opts *store.Options) {
+		if opts.Context == nil {
+			opts.Context = context.Background()
+		}
+		opts.Context = context.WithValue(opts.Context, nameKey{}, name)
+	}
+}
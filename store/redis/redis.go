@@ -1,11 +1,14 @@
 package redis
 
 import(
+	"context"
 	"time"
 	"strings"
 	"path/filepath"
 	"errors"
 	"github.com/xxxmicro/base/store"
+	"github.com/xxxmicro/base/database/pool"
+	bredis "github.com/xxxmicro/base/database/redis"
 	"github.com/garyburd/redigo/redis"
 	"encoding/json"
 )
@@ -44,6 +47,7 @@ func (m *redisStore) Init(opts ...store.Option) error {
 		return err
 	}
 	m.r = r
+	bredis.RegisterPool(m.poolName(), r)
 	return nil
 }
 
@@ -62,35 +66,31 @@ func (m *redisStore) prefix(database, table string) string {
 	return filepath.Join(database, table)
 }
 
+func (m *redisStore) poolName() string {
+	if m.options.Context != nil {
+		if name, ok := m.options.Context.Value(nameKey{}).(string); ok {
+			return "store:" + name + ":" + m.prefix("", "")
+		}
+	}
+	return "store:" + m.prefix("", "")
+}
+
 func (m *redisStore) connect() (*redis.Pool, error) {
 	if len(m.options.Addrs) == 0 {
 		m.options.Addrs = []string{":6379"}
 	}
 
-	pool := &redis.Pool{
-		MaxIdle:     3,
-		IdleTimeout: 240 * time.Second,
-		Dial: func() (redis.Conn, error) {
-						c, err := redis.Dial("tcp", strings.Join(m.options.Addrs, ","))
-						if err != nil {
-										return nil, err
-						}
-
-						if m.options.Password != "" {
-										if _, err := c.Do("AUTH", m.options.Password); err != nil {
-														c.Close()
-														return nil, err
-										}
-						}
-						return c, err
-		},
-		TestOnBorrow: func(c redis.Conn, t time.Time) error {
-						_, err := c.Do("PING")
-						return err
-		},
+	poolOptions := bredis.DefaultPoolOptions()
+	if m.options.Context != nil {
+		if o, ok := m.options.Context.Value(poolKey{}).(pool.Options); ok {
+			poolOptions = o
+		}
+	}
+	if err := poolOptions.Validate(); err != nil {
+		return nil, err
 	}
 
-	return pool, nil
+	return bredis.NewPool(strings.Join(m.options.Addrs, ","), m.options.Password, poolOptions), nil
 }
 
 func (m *redisStore) String() string {
@@ -98,6 +98,10 @@ func (m *redisStore) String() string {
 }
 
 func (m *redisStore) Get(key string, opts ...store.ReadOption) (*store.Record, error) {
+	return m.GetContext(context.Background(), key, opts...)
+}
+
+func (m *redisStore) GetContext(ctx context.Context, key string, opts ...store.ReadOption) (*store.Record, error) {
 	readOpts := store.ReadOptions{}
 	for _, o := range opts {
 		o(&readOpts)
@@ -108,7 +112,11 @@ func (m *redisStore) Get(key string, opts ...store.ReadOption) (*store.Record, e
 
 	c := m.r.Get()
 	defer c.Close()
-	data, err := redis.Bytes(c.Do("GET", key))
+	data, err := redis.Bytes(bredis.Do(ctx, c, "store", prefix, "GET", key))
+	if err == redis.ErrNil {
+		// 与其它 store 一致，key 不存在时返回 store.ErrNotFound
+		return nil, store.ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -147,6 +155,10 @@ func (m *redisStore) Get(key string, opts ...store.ReadOption) (*store.Record, e
 }
 
 func (m *redisStore) Set(record *store.Record, opts ...store.WriteOption) error {
+	return m.SetContext(context.Background(), record, opts...)
+}
+
+func (m *redisStore) SetContext(ctx context.Context, record *store.Record, opts ...store.WriteOption) error {
 	writeOpts := store.WriteOptions{}
 	for _, o := range opts {
 		o(&writeOpts)
@@ -191,18 +203,23 @@ func (m *redisStore) Set(record *store.Record, opts ...store.WriteOption) error
 	}
 
 	if record.Expiry > 0 {
-		_, err = c.Do("SETEX", key, record.Expiry.Seconds(), data)
+		_, err = bredis.Do(ctx, c, "store", prefix, "SETEX", key, record.Expiry.Seconds(), data)
 	} else {
-		_, err = c.Do("SET", key, data)
+		_, err = bredis.Do(ctx, c, "store", prefix, "SET", key, data)
 	}
 	return err
 }
 
 func (m *redisStore) Close() error {
+	bredis.UnregisterPool(m.poolName())
 	return m.r.Close()
 }
 
 func (m *redisStore) Delete(key string, opts ...store.DeleteOption) error {
+	return m.DeleteContext(context.Background(), key, opts...)
+}
+
+func (m *redisStore) DeleteContext(ctx context.Context, key string, opts ...store.DeleteOption) error {
 	deleteOptions := store.DeleteOptions{}
 	for _, o := range opts {
 		o(&deleteOptions)
@@ -214,7 +231,7 @@ func (m *redisStore) Delete(key string, opts ...store.DeleteOption) error {
 	c := m.r.Get()
 	defer c.Close()
 
-	_, err := c.Do("DEL", key)
+	_, err := bredis.Do(ctx, c, "store", prefix, "DEL", key)
 	return err
 }
 
@@ -1,11 +1,13 @@
 package redis
 
 import(
+	"context"
 	"testing"
 	"time"
 	"log"
 	"github.com/xxxmicro/base/store"
 	"github.com/stretchr/testify/assert"
+	"github.com/garyburd/redigo/redis"
 )
 
 func TestBasic(t *testing.T) {
@@ -30,4 +32,33 @@ func TestBasic(t *testing.T) {
 
 	r, err = s.Get(record.Key)
 	assert.Error(t, err, "Expected no records in redis store")
-}
\ No newline at end of file
+}
+
+// nilConn 所有命令都返回空回复，相当于 key 不存在
+type nilConn struct {
+	redis.Conn
+}
+
+func (nilConn) Do(command string, args ...interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+func (nilConn) Err() error {
+	return nil
+}
+
+func (nilConn) Close() error {
+	return nil
+}
+
+func TestGetNotFound(t *testing.T) {
+	s := &redisStore{r: &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return nilConn{}, nil
+		},
+	}}
+
+	r, err := s.GetContext(context.Background(), "missing")
+	assert.Nil(t, r)
+	assert.Equal(t, store.ErrNotFound, err)
+}
@@ -1,6 +1,7 @@
 package store
 
 import(
+	"context"
 	"errors"
 	"time"
 )
@@ -21,6 +22,14 @@ type Store interface {
 	String() string
 }
 
+// ContextStore 支持 context 的 Store，命令作为 context 中 span 的子 span 记录
+type ContextStore interface {
+	Store
+	GetContext(c context.Context, key string, opts ...ReadOption) (*Record, error)
+	SetContext(c context.Context, r *Record, opts ...WriteOption) error
+	DeleteContext(c context.Context, key string, opts ...DeleteOption) error
+}
+
 type Record struct {
 	Key string `json:"key"`
 	Value []byte `json:"value"`